@@ -4,16 +4,23 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
 	"homecloud-auth-service/config"
+	"homecloud-auth-service/internal/audit"
+	"homecloud-auth-service/internal/events"
+	"homecloud-auth-service/internal/interfaces"
 	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/mail"
+	"homecloud-auth-service/internal/models"
 	"homecloud-auth-service/internal/repository"
 	"homecloud-auth-service/internal/security"
 	"homecloud-auth-service/internal/service"
+	"homecloud-auth-service/internal/tracing"
 	"homecloud-auth-service/internal/transport/grpc/authServer"
 	"homecloud-auth-service/internal/transport/grpc/dbClient"
 	"homecloud-auth-service/internal/transport/grpc/fileClient"
@@ -43,6 +50,9 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logBase.Error(ctx, "HTTP server shutdown failed", zap.Error(err))
 	}
+	if err := tracing.Shutdown(shutdownCtx); err != nil {
+		logBase.Error(ctx, "Tracing shutdown failed", zap.Error(err))
+	}
 	logBase.Info(ctx, "Servers exited gracefully")
 }
 
@@ -58,9 +68,14 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 	}
 	ctx = logger.CtxWWithLogger(ctx, logBase)
 
+	// Трассировка включается до создания клиентов, чтобы их вызовы попадали в спаны
+	if err := tracing.Init(ctx, cfg.Tracing); err != nil {
+		return nil, nil, fmt.Errorf("failed to init tracing: %w", err)
+	}
+
 	// Создаём gRPC dbClient
 	fmt.Printf("Creating DB Manager client connection to %s:%d...\n", cfg.DbManager.Host, cfg.DbManager.Port)
-	dbClient, err := dbClient.NewDBServiceClient(cfg.DbManager.Host, cfg.DbManager.Port)
+	dbClient, err := dbClient.NewDBServiceClient(ctx, cfg.DbManager.Host, cfg.DbManager.Port)
 	if err != nil {
 		fmt.Printf("Failed to create DB Manager client: %v\n", err)
 		return nil, nil, fmt.Errorf("failed to create dbClient: %w", err)
@@ -74,7 +89,7 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 	// Создаём gRPC клиент для файлового сервиса
 	fmt.Printf("Creating File Service client connection to %s:%d...\n", cfg.FileService.Host, cfg.FileService.Port)
 	var fileServiceClient fileClient.FileServiceClient
-	realClient, err := fileClient.NewFileServiceClient(cfg.FileService.Host, cfg.FileService.Port)
+	realClient, err := fileClient.NewFileServiceClient(ctx, cfg.FileService.Host, cfg.FileService.Port)
 	if err != nil {
 		fmt.Printf("⚠Failed to create file service client, using mock client for testing: %v\n", err)
 		logBase.Info(ctx, "Failed to create file service client, using mock client for testing", zap.Error(err))
@@ -97,24 +112,323 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 		logBase.Info(ctx, "File service client created successfully")
 	}
 
+	// Создаём набор ключей подписи (для HS256 не нужен)
+	var keyRing *security.KeyRing
+	var keyManager interfaces.KeyManager
+	if cfg.Jwt.Algorithm != "" && cfg.Jwt.Algorithm != security.AlgorithmHS256 {
+		keyRing, err = security.NewKeyRing(cfg.Jwt.Algorithm, cfg.Jwt.KeysDir, cfg.Jwt.Expiration)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create key ring: %w", err)
+		}
+		keyManager = keyRing
+		if cfg.Jwt.RotationInterval > 0 {
+			go keyRing.RunRotation(ctx, cfg.Jwt.RotationInterval)
+		}
+		fmt.Printf("Signing key ring initialized (%s, active kid %s)\n", cfg.Jwt.Algorithm, keyRing.Active().ID)
+	}
+
 	// Создаём security
+	passwordResetExpiration := cfg.PasswordReset.Expiration
+	if passwordResetExpiration <= 0 {
+		passwordResetExpiration = 30 * time.Minute
+	}
 	fmt.Printf("Initializing security service...\n")
 	securityService := security.NewSecurity(
 		cfg.Jwt.SecretKey,
+		keyRing,
 		cfg.Jwt.Expiration,
+		cfg.Jwt.RefreshExpiration,
 		cfg.Verification.SecretKey,
 		cfg.Verification.Expiration,
+		cfg.PasswordReset.SecretKey,
+		passwordResetExpiration,
 	)
 	fmt.Printf("Security service initialized\n")
 
 	// Создаём сервис пользователей
 	fmt.Printf("Initializing user service...\n")
-	userService := service.NewUserService(userRepo, securityService, fileServiceClient)
+	refreshTokenRepo := repository.NewMemoryRefreshTokenRepository()
+
+	// Создаём хранилище отозванных токенов
+	var revocationStore interfaces.TokenRevocationStore
+	switch cfg.Revocation.Storage {
+	case "file":
+		revocationStore, err = repository.NewFileTokenRevocationStore(cfg.Revocation.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create revocation store: %w", err)
+		}
+	default:
+		revocationStore = repository.NewMemoryTokenRevocationStore()
+	}
+	fmt.Printf("Token revocation store initialized (%s)\n", cfg.Revocation.Storage)
+
+	// Создаём хранилище сессий
+	sessionRepo := repository.NewMemorySessionRepository()
+
+	// Создаём хранилище секретов второго фактора
+	var twoFactorRepo interfaces.TwoFactorRepository
+	switch cfg.TwoFactor.Storage {
+	case "file":
+		twoFactorRepo, err = repository.NewFileTwoFactorRepository(cfg.TwoFactor.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create two-factor repository: %w", err)
+		}
+	default:
+		twoFactorRepo = repository.NewMemoryTwoFactorRepository()
+	}
+	challengeExpiration := cfg.TwoFactor.ChallengeExpiration
+	if challengeExpiration <= 0 {
+		challengeExpiration = 5 * time.Minute
+	}
+	twoFactor, err := security.NewTwoFactor(cfg.TwoFactor.Issuer, cfg.TwoFactor.ChallengeSecret, cfg.TwoFactor.EncryptionKey, challengeExpiration)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create two-factor service: %w", err)
+	}
+	fmt.Printf("Two-factor repository initialized (%s)\n", cfg.TwoFactor.Storage)
+
+	// Создаём хранилище passkey
+	var webAuthnRepo interfaces.WebAuthnRepository
+	switch cfg.WebAuthn.Storage {
+	case "file":
+		webAuthnRepo, err = repository.NewFileWebAuthnRepository(cfg.WebAuthn.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create webauthn repository: %w", err)
+		}
+	default:
+		webAuthnRepo = repository.NewMemoryWebAuthnRepository()
+	}
+	webAuthn := security.NewWebAuthn(cfg.WebAuthn.RPID, cfg.WebAuthn.RPName, cfg.WebAuthn.Origins)
+	fmt.Printf("WebAuthn repository initialized (%s, rp_id=%s)\n", cfg.WebAuthn.Storage, cfg.WebAuthn.RPID)
+
+	// Политика паролей
+	var passwordDictionary []string
+	if cfg.PasswordPolicy.CommonPasswords {
+		passwordDictionary = security.CommonPasswords()
+	}
+	if cfg.PasswordPolicy.DictionaryFile != "" {
+		words, err := security.LoadPasswordDictionary(cfg.PasswordPolicy.DictionaryFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		passwordDictionary = append(passwordDictionary, words...)
+	}
+	passwordPolicy := security.NewPasswordPolicy(models.PasswordPolicy{
+		MinLength:           cfg.PasswordPolicy.MinLength,
+		MaxLength:           cfg.PasswordPolicy.MaxLength,
+		MinCharacterClasses: cfg.PasswordPolicy.MinCharacterClasses,
+		MinEntropyBits:      cfg.PasswordPolicy.MinEntropyBits,
+		MaxRepeatedChars:    cfg.PasswordPolicy.MaxRepeatedChars,
+		ForbidUserInfo:      cfg.PasswordPolicy.ForbidUserInfo,
+		ForbiddenSubstrings: cfg.PasswordPolicy.ForbiddenSubstrings,
+	}, passwordDictionary)
+	fmt.Printf("Password policy initialized (min_length=%d, %d dictionary entries)\n",
+		passwordPolicy.Rules().MinLength, len(passwordDictionary))
+
+	// Создаём отправку писем
+	var mailer interfaces.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(cfg.Mail.SMTP.Host, cfg.Mail.SMTP.Port, cfg.Mail.SMTP.Username, cfg.Mail.SMTP.Password,
+			cfg.Mail.From, cfg.Mail.SMTP.Security, cfg.Mail.SMTP.Timeout)
+	case "file":
+		mailer, err = mail.NewFileMailer(cfg.Mail.OutboxDir, cfg.Mail.From)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create mail outbox: %w", err)
+		}
+	default:
+		mailer = mail.NewLogMailer()
+	}
+	fmt.Printf("Mailer initialized (%s)\n", cfg.Mail.Driver)
+
+	// Лимит повторной отправки письма для верификации
+	resendLimit, resendWindow := cfg.Verification.ResendLimit, cfg.Verification.ResendWindow
+	if resendLimit <= 0 {
+		resendLimit = 3
+	}
+	if resendWindow <= 0 {
+		resendWindow = time.Hour
+	}
+	resendLimiter := repository.NewMemoryRateLimiter(resendLimit, resendWindow)
+
+	// Создаём хранилище использованных токенов верификации
+	var verificationNonces interfaces.NonceStore
+	switch cfg.Verification.Storage {
+	case "file":
+		verificationNonces, err = repository.NewFileNonceStore(cfg.Verification.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create verification nonce store: %w", err)
+		}
+	default:
+		verificationNonces = repository.NewMemoryNonceStore()
+	}
+
+	// Создаём очередь директорий, оставшихся от неудачных регистраций
+	var orphanDirectories interfaces.OrphanDirectoryRepository
+	switch cfg.Registration.Storage {
+	case "file":
+		orphanDirectories, err = repository.NewFileOrphanDirectoryRepository(cfg.Registration.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create orphan directory repository: %w", err)
+		}
+	default:
+		orphanDirectories = repository.NewMemoryOrphanDirectoryRepository()
+	}
+
+	// Создаём outbox событий и приемники
+	var outbox interfaces.OutboxRepository
+	switch cfg.Events.Storage {
+	case "file":
+		outbox, err = repository.NewFileOutboxRepository(cfg.Events.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create outbox: %w", err)
+		}
+	default:
+		outbox = repository.NewMemoryOutboxRepository()
+	}
+	var eventSinks []interfaces.EventSink
+	sinkNames := make(map[string]bool)
+	for _, sinkCfg := range cfg.Events.Sinks {
+		// Доставка учитывается по имени приемника
+		if sinkCfg.Name == "" || sinkNames[sinkCfg.Name] {
+			return nil, nil, fmt.Errorf("event sink name %q is empty or not unique", sinkCfg.Name)
+		}
+		sinkNames[sinkCfg.Name] = true
+
+		var sink interfaces.EventSink
+		switch sinkCfg.Type {
+		case "grpc":
+			sink, err = events.NewGRPCSink(sinkCfg.Name, sinkCfg.Address, sinkCfg.Timeout)
+		case "http":
+			sink = events.NewHTTPSink(sinkCfg.Name, sinkCfg.URL, sinkCfg.Timeout)
+		case "file":
+			sink, err = events.NewFileSink(sinkCfg.Name, sinkCfg.Path)
+		default:
+			err = fmt.Errorf("unknown type %q", sinkCfg.Type)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create event sink %s: %w", sinkCfg.Name, err)
+		}
+		eventSinks = append(eventSinks, sink)
+	}
+
+	// Вебхуки получают события из outbox через отдельный приемник
+	var webhookRepo interfaces.WebhookRepository
+	switch cfg.Webhooks.Storage {
+	case "file":
+		webhookRepo, err = repository.NewFileWebhookRepository(cfg.Webhooks.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create webhook repository: %w", err)
+		}
+	default:
+		webhookRepo = repository.NewMemoryWebhookRepository()
+	}
+	if sinkNames[events.WebhookSinkName] {
+		return nil, nil, fmt.Errorf("event sink name %q is reserved for webhooks", events.WebhookSinkName)
+	}
+	eventSinks = append(eventSinks, events.NewWebhookSink(webhookRepo))
+	// Лента для WatchUserEvents тоже наполняется из outbox
+	var feedRepo interfaces.EventFeedRepository
+	switch cfg.Events.Feed.Storage {
+	case "file":
+		feedRepo, err = repository.NewFileEventFeedRepository(cfg.Events.Feed.FilePath, cfg.Events.Feed.Retention)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create event feed: %w", err)
+		}
+	default:
+		feedRepo = repository.NewMemoryEventFeedRepository(cfg.Events.Feed.Retention)
+	}
+	if sinkNames[events.FeedSinkName] {
+		return nil, nil, fmt.Errorf("event sink name %q is reserved for the event feed", events.FeedSinkName)
+	}
+	feed := events.NewFeed(feedRepo)
+	eventSinks = append(eventSinks, feed)
+
+	deliveryInterval := cfg.Webhooks.DeliveryInterval
+	if deliveryInterval <= 0 {
+		deliveryInterval = time.Second
+	}
+	deliverer := events.NewWebhookDeliverer(webhookRepo, cfg.Webhooks.MaxAttempts, cfg.Webhooks.RetryBaseDelay, cfg.Webhooks.RetryMaxDelay, cfg.Webhooks.Timeout, logBase)
+	go deliverer.Run(ctx, deliveryInterval)
+
+	dispatchInterval := cfg.Events.DispatchInterval
+	if dispatchInterval <= 0 {
+		dispatchInterval = time.Second
+	}
+	dispatcher := events.NewDispatcher(outbox, eventSinks, cfg.Events.RetryBaseDelay, cfg.Events.RetryMaxDelay, logBase)
+	go dispatcher.Run(ctx, dispatchInterval)
+	fmt.Printf("Event outbox initialized (%s, %d sinks)\n", cfg.Events.Storage, len(eventSinks))
+
+	// Журнал аудита
+	var auditRepo interfaces.AuditRepository
+	switch cfg.Audit.Storage {
+	case "file":
+		auditRepo, err = repository.NewFileAuditRepository(cfg.Audit.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create audit repository: %w", err)
+		}
+	default:
+		auditRepo = repository.NewMemoryAuditRepository()
+	}
+	var auditSinks []interfaces.AuditSink
+	for _, sinkCfg := range cfg.Audit.Sinks {
+		switch sinkCfg.Type {
+		case "stdout":
+			auditSinks = append(auditSinks, audit.NewWriterSink(os.Stdout))
+		case "file":
+			sink, err := audit.NewFileSink(sinkCfg.Path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create audit sink: %w", err)
+			}
+			auditSinks = append(auditSinks, sink)
+		default:
+			return nil, nil, fmt.Errorf("unknown audit sink type %q", sinkCfg.Type)
+		}
+	}
+	auditLogger := audit.NewLogger(auditRepo, auditSinks...)
+
+	// Периодическая очистка истекших токенов и сессий
+	cleanupInterval := cfg.Revocation.CleanupInterval
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	go repository.RunCleanup(ctx, cleanupInterval,
+		revocationStore.EvictExpired,
+		refreshTokenRepo.DeleteExpired,
+		sessionRepo.DeleteExpired,
+		webAuthnRepo.DeleteExpiredChallenges,
+		resendLimiter.DeleteExpired,
+		verificationNonces.DeleteExpired,
+		feedRepo.DeleteExpired,
+	)
+
+	userService := service.NewUserService(userRepo, securityService, fileServiceClient,
+		service.WithRefreshTokenRepository(refreshTokenRepo),
+		service.WithTokenRevocationStore(revocationStore),
+		service.WithSessionRepository(sessionRepo),
+		service.WithTwoFactorRepository(twoFactorRepo),
+		service.WithTwoFactor(twoFactor),
+		service.WithWebAuthnRepository(webAuthnRepo),
+		service.WithWebAuthn(webAuthn),
+		service.WithMailer(mailer),
+		service.WithVerificationURL(cfg.Verification.URL),
+		service.WithVerificationResendLimiter(resendLimiter),
+		service.WithVerificationNonceStore(verificationNonces),
+		service.WithPasswordResetURL(cfg.PasswordReset.URL),
+		service.WithEmailChangeURLs(cfg.EmailChange.ConfirmURL, cfg.EmailChange.RevertURL),
+		service.WithOrphanDirectoryRepository(orphanDirectories),
+		service.WithRetry(cfg.Registration.RetryAttempts, cfg.Registration.RetryDelay),
+		service.WithOutbox(outbox),
+		service.WithAuditLogger(auditLogger),
+		service.WithPasswordPolicy(passwordPolicy),
+	)
+	go repository.RunCleanup(ctx, cleanupInterval, userService.RetryOrphanDirectories)
 	fmt.Printf("User service initialized\n")
 
 	// Создаём gRPC сервер (фоново, ошибки логируем, но не блокируем HTTP)
 	fmt.Printf("Starting gRPC auth server on port %d...\n", cfg.Grpc.Port)
-	grpcSrv := authServer.NewAuthServer(&ctx, userService, securityService, &cfg.Grpc)
+	// Транспорты работают с сервисом через обертку, открывающую спан на каждый вызов
+	tracedUsers := service.NewTracedUserService(userService)
+	grpcSrv := authServer.NewAuthServer(&ctx, tracedUsers, securityService, feed, &cfg.Grpc)
 	go func() {
 		if err := grpcSrv.StartAuthServer(); err != nil {
 			logBase.Error(ctx, "Failed to start gRPC server", zap.Error(err))
@@ -123,15 +437,21 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 
 	// Создаём HTTP хэндлер и роутер
 	fmt.Printf("Setting up HTTP handlers and routes...\n")
-	handler := api.NewHandler(userService)
-	router := api.SetupRoutes(handler)
+	handler := api.NewHandler(tracedUsers, keyManager, service.NewWebhookService(webhookRepo, auditLogger), auditLogger)
+	proxies, err := api.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+	router := api.SetupRoutes(handler, proxies)
 	fmt.Printf("HTTP handlers and routes configured\n")
 
 	// HTTP-сервер
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	srv := &http.Server{
 		Addr:    addr,
-		Handler: router,
+		Handler: api.RequestLogMiddleware(router),
+		// Запросы получают логгер из базового контекста; отмена ctx не обрывает их при остановке
+		BaseContext: func(net.Listener) context.Context { return context.WithoutCancel(ctx) },
 	}
 
 	fmt.Printf("Starting HTTP server on %s...\n", addr)
@@ -14,26 +14,181 @@ type LoggerConfig struct {
 	zap.Config `yaml:",inline"`
 }
 
-func (lc *LoggerConfig) Build() (*zap.Logger, error) {
-	return lc.Config.Build()
+func (lc *LoggerConfig) Build(opts ...zap.Option) (*zap.Logger, error) {
+	return lc.Config.Build(opts...)
 }
 
 // ServerConfig - конфигурация HTTP сервера
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host           string   `yaml:"host"`
+	Port           int      `yaml:"port"`
+	TrustedProxies []string `yaml:"trusted_proxies"` // адреса и подсети CIDR прокси, которым верим X-Forwarded-For
 }
 
 // JwtConfig - конфигурация JWT токенов
 type JwtConfig struct {
-	SecretKey  string        `yaml:"secret_key"`
-	Expiration time.Duration `yaml:"expiration"`
+	SecretKey         string        `yaml:"secret_key"`         // используется только с algorithm: HS256
+	Algorithm         string        `yaml:"algorithm"`          // HS256 | RS256 | EdDSA
+	KeysDir           string        `yaml:"keys_dir"`           // каталог набора ключей, пусто - ключи в памяти
+	RotationInterval  time.Duration `yaml:"rotation_interval"`  // 0 - только ручная ротация
+	Expiration        time.Duration `yaml:"expiration"`         // время жизни access токена
+	RefreshExpiration time.Duration `yaml:"refresh_expiration"` // время жизни refresh токена
 }
 
 // VerificationConfig - конфигурация токенов верификации
 type VerificationConfig struct {
 	SecretKey  string        `yaml:"secret_key"`
 	Expiration time.Duration `yaml:"expiration"`
+	URL        string        `yaml:"url"` // адрес подтверждения, токен добавляется параметром token
+
+	// Повторная отправка письма: не больше resend_limit писем за resend_window
+	// на пользователя и на адрес
+	ResendLimit  int           `yaml:"resend_limit"`
+	ResendWindow time.Duration `yaml:"resend_window"`
+
+	// Использованные токены (токен действует один раз)
+	Storage  string `yaml:"storage"`   // memory | file
+	FilePath string `yaml:"file_path"` // для storage: file
+}
+
+// MailConfig - конфигурация отправки писем
+type MailConfig struct {
+	Driver    string     `yaml:"driver"`     // log | file | smtp
+	From      string     `yaml:"from"`       // адрес отправителя
+	OutboxDir string     `yaml:"outbox_dir"` // для driver: file
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig - параметры SMTP сервера
+type SMTPConfig struct {
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	Security string        `yaml:"security"` // starttls | tls | none
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// RevocationConfig - конфигурация хранилища отозванных токенов
+type RevocationConfig struct {
+	Storage         string        `yaml:"storage"`   // memory | file
+	FilePath        string        `yaml:"file_path"` // для storage: file
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+}
+
+// PasswordResetConfig - конфигурация сброса пароля
+type PasswordResetConfig struct {
+	SecretKey  string        `yaml:"secret_key"`
+	Expiration time.Duration `yaml:"expiration"`
+	URL        string        `yaml:"url"` // страница веб-интерфейса, токен добавляется параметром token
+}
+
+// PasswordPolicyConfig - требования к паролям при регистрации, смене и сбросе пароля
+type PasswordPolicyConfig struct {
+	MinLength           int      `yaml:"min_length"`            // по умолчанию 8
+	MaxLength           int      `yaml:"max_length"`            // по умолчанию и не больше 72 - предел bcrypt
+	MinCharacterClasses int      `yaml:"min_character_classes"` // из строчных, заглавных, цифр и прочих; 0 - не проверять
+	MinEntropyBits      float64  `yaml:"min_entropy_bits"`      // 0 - не проверять
+	MaxRepeatedChars    int      `yaml:"max_repeated_chars"`    // одинаковых символов подряд, 0 - без ограничения
+	ForbidUserInfo      bool     `yaml:"forbid_user_info"`      // пароль не содержит username и email
+	ForbiddenSubstrings []string `yaml:"forbidden_substrings"`  // например, название сервиса
+	CommonPasswords     bool     `yaml:"common_passwords"`      // встроенный список распространенных паролей
+	DictionaryFile      string   `yaml:"dictionary_file"`       // дополнительный список, по паролю в строке
+}
+
+// RegistrationConfig - регистрация: создание домашней директории и пользователя
+type RegistrationConfig struct {
+	RetryAttempts int           `yaml:"retry_attempts"` // попыток на вызов файлового сервиса и БД
+	RetryDelay    time.Duration `yaml:"retry_delay"`    // пауза перед повтором, удваивается
+	// Директории неудачных регистраций, которые удаляются в фоне
+	Storage  string `yaml:"storage"`   // memory | file
+	FilePath string `yaml:"file_path"` // для storage: file
+}
+
+// EmailChangeConfig - ссылки из писем смены email, токен добавляется параметром token
+type EmailChangeConfig struct {
+	ConfirmURL string `yaml:"confirm_url"` // подтверждение нового адреса
+	RevertURL  string `yaml:"revert_url"`  // отмена смены со старого адреса
+}
+
+// TwoFactorConfig - конфигурация двухфакторной аутентификации
+type TwoFactorConfig struct {
+	Issuer              string        `yaml:"issuer"`               // имя сервиса в приложении-аутентификаторе
+	ChallengeSecret     string        `yaml:"challenge_secret"`     // подпись MFA challenge токенов
+	ChallengeExpiration time.Duration `yaml:"challenge_expiration"` // время на ввод второго фактора
+	EncryptionKey       string        `yaml:"encryption_key"`       // шифрование TOTP секретов
+	Storage             string        `yaml:"storage"`              // memory | file
+	FilePath            string        `yaml:"file_path"`            // для storage: file
+}
+
+// WebAuthnConfig - конфигурация входа по passkey
+type WebAuthnConfig struct {
+	RPID     string   `yaml:"rp_id"`     // домен, к которому привязываются ключи
+	RPName   string   `yaml:"rp_name"`   // название сервиса в диалоге браузера
+	Origins  []string `yaml:"origins"`   // допустимые origin веб-интерфейса
+	Storage  string   `yaml:"storage"`   // memory | file
+	FilePath string   `yaml:"file_path"` // для storage: file
+}
+
+// EventsConfig - outbox событий пользователей и их доставка другим сервисам
+type EventsConfig struct {
+	Storage          string            `yaml:"storage"`           // memory | file
+	FilePath         string            `yaml:"file_path"`         // для storage: file
+	DispatchInterval time.Duration     `yaml:"dispatch_interval"` // период доставки
+	RetryBaseDelay   time.Duration     `yaml:"retry_base_delay"`  // пауза после первой неудачи, удваивается
+	RetryMaxDelay    time.Duration     `yaml:"retry_max_delay"`
+	Sinks            []EventSinkConfig `yaml:"sinks"`
+	Feed             EventFeedConfig   `yaml:"feed"`
+}
+
+// EventFeedConfig - лента изменений для gRPC WatchUserEvents
+type EventFeedConfig struct {
+	Storage   string        `yaml:"storage"`   // memory | file; memory - курсоры сбрасываются при перезапуске
+	FilePath  string        `yaml:"file_path"` // для storage: file
+	Retention time.Duration `yaml:"retention"` // клиент, отставший сильнее, перечитывает данные целиком
+}
+
+// EventSinkConfig - приемник событий
+type EventSinkConfig struct {
+	Name    string        `yaml:"name"`    // уникальное имя, по нему учитывается доставка
+	Type    string        `yaml:"type"`    // grpc | http | file
+	Address string        `yaml:"address"` // для type: grpc, host:port
+	URL     string        `yaml:"url"`     // для type: http
+	Path    string        `yaml:"path"`    // для type: file
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// WebhooksConfig - подписанные вебхуки для внешних интеграций
+type WebhooksConfig struct {
+	Storage          string        `yaml:"storage"`           // memory | file
+	FilePath         string        `yaml:"file_path"`         // для storage: file
+	DeliveryInterval time.Duration `yaml:"delivery_interval"` // период отправки
+	MaxAttempts      int           `yaml:"max_attempts"`      // после них доставка попадает в dead letter
+	RetryBaseDelay   time.Duration `yaml:"retry_base_delay"`  // пауза после первой неудачи, удваивается
+	RetryMaxDelay    time.Duration `yaml:"retry_max_delay"`
+	Timeout          time.Duration `yaml:"timeout"`
+}
+
+// AuditConfig - журнал аудита действий, связанных с безопасностью
+type AuditConfig struct {
+	Storage  string            `yaml:"storage"`   // memory | file
+	FilePath string            `yaml:"file_path"` // для storage: file, файл только дополняется
+	Sinks    []AuditSinkConfig `yaml:"sinks"`     // копии журнала
+}
+
+// AuditSinkConfig - дополнительный приемник журнала аудита
+type AuditSinkConfig struct {
+	Type string `yaml:"type"` // stdout | file
+	Path string `yaml:"path"` // для type: file
+}
+
+// TracingConfig - экспорт трассировок OpenTelemetry
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // otlp | stdout | none
+	Endpoint    string  `yaml:"endpoint"`     // для exporter: otlp, host:port коллектора (gRPC)
+	Insecure    bool    `yaml:"insecure"`     // без TLS до коллектора
+	ServiceName string  `yaml:"service_name"` // по умолчанию homecloud-auth-service
+	SampleRatio float64 `yaml:"sample_ratio"` // доля новых трасс, 0 - все; решение родителя соблюдается
 }
 
 // GrpcConfig - конфигурация gRPC клиента для БД
@@ -56,13 +211,25 @@ type DbManagerConfig struct {
 
 // Config - основная конфигурация приложения
 type Config struct {
-	Server       ServerConfig       `yaml:"server"`
-	Jwt          JwtConfig          `yaml:"jwt"`
-	Verification VerificationConfig `yaml:"verification"`
-	Logger       LoggerConfig       `yaml:"logger"`
-	Grpc         GrpcConfig         `yaml:"grpc"`
-	FileService  FileServiceConfig  `yaml:"file_service"`
-	DbManager    DbManagerConfig    `yaml:"dbmanager"`
+	Server         ServerConfig         `yaml:"server"`
+	Jwt            JwtConfig            `yaml:"jwt"`
+	Verification   VerificationConfig   `yaml:"verification"`
+	Registration   RegistrationConfig   `yaml:"registration"`
+	Revocation     RevocationConfig     `yaml:"revocation"`
+	PasswordReset  PasswordResetConfig  `yaml:"password_reset"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password_policy"`
+	EmailChange    EmailChangeConfig    `yaml:"email_change"`
+	Mail           MailConfig           `yaml:"mail"`
+	TwoFactor      TwoFactorConfig      `yaml:"two_factor"`
+	WebAuthn       WebAuthnConfig       `yaml:"webauthn"`
+	Events         EventsConfig         `yaml:"events"`
+	Webhooks       WebhooksConfig       `yaml:"webhooks"`
+	Audit          AuditConfig          `yaml:"audit"`
+	Tracing        TracingConfig        `yaml:"tracing"`
+	Logger         LoggerConfig         `yaml:"logger"`
+	Grpc           GrpcConfig           `yaml:"grpc"`
+	FileService    FileServiceConfig    `yaml:"file_service"`
+	DbManager      DbManagerConfig      `yaml:"dbmanager"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
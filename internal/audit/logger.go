@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+)
+
+// Logger ведет журнал аудита: нумерует записи, связывает их цепочкой хешей,
+// сохраняет в хранилище и копирует в дополнительные приемники
+type Logger struct {
+	repo  interfaces.AuditRepository
+	sinks []interfaces.AuditSink
+
+	mu       sync.Mutex // записи добавляются строго по одной, иначе цепочка разветвится
+	loaded   bool
+	lastSeq  uint64
+	lastHash string
+}
+
+// NewLogger создает журнал. Конец цепочки читается из хранилища при первой записи.
+func NewLogger(repo interfaces.AuditRepository, sinks ...interfaces.AuditSink) *Logger {
+	return &Logger{repo: repo, sinks: sinks}
+}
+
+func (l *Logger) Record(ctx context.Context, record *models.AuditRecord) {
+	client := models.ClientInfoFromContext(ctx)
+	if record.ActorID == uuid.Nil {
+		record.ActorID = client.ActorID
+	}
+	record.IP = client.IP
+	record.UserAgent = client.UserAgent
+	record.RequestID = client.RequestID
+	record.ID = uuid.New()
+	record.Timestamp = time.Now().UTC()
+
+	if err := l.append(ctx, record); err != nil {
+		metrics.AuditWriteFailuresTotal.WithLabelValues(metrics.AuditStageStore).Inc()
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to write audit record",
+			zap.String("action", record.Action),
+			zap.String("target_user_id", record.TargetUserID.String()),
+			zap.Error(err),
+		)
+		return
+	}
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, record); err != nil {
+			metrics.AuditWriteFailuresTotal.WithLabelValues(metrics.AuditStageSink).Inc()
+			logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to copy audit record to sink",
+				zap.Uint64("seq", record.Seq),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func (l *Logger) append(ctx context.Context, record *models.AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.loaded {
+		last, err := l.repo.Last(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read end of audit chain: %w", err)
+		}
+		if last != nil {
+			l.lastSeq, l.lastHash = last.Seq, last.Hash
+		}
+		l.loaded = true
+	}
+
+	record.Seq = l.lastSeq + 1
+	record.PrevHash = l.lastHash
+	hash, err := Hash(record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	if err := l.repo.Append(ctx, record); err != nil {
+		return err
+	}
+	l.lastSeq, l.lastHash = record.Seq, record.Hash
+	return nil
+}
+
+func (l *Logger) Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditRecord, error) {
+	records, err := l.repo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	return records, nil
+}
+
+func (l *Logger) Verify(ctx context.Context) (int, error) {
+	records, err := l.repo.Query(ctx, models.AuditFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return VerifyChain(records)
+}
+
+// Hash - SHA-256 от JSON записи без поля Hash. PrevHash входит в хеш и связывает
+// запись с предыдущей.
+func Hash(record *models.AuditRecord) (string, error) {
+	unsigned := *record
+	unsigned.Hash = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyChain проверяет записи, идущие подряд с начала журнала. Возвращает число
+// записей до первой нарушенной и ошибку с ее номером.
+func VerifyChain(records []*models.AuditRecord) (int, error) {
+	prevHash := ""
+	for i, record := range records {
+		if record.Seq != uint64(i+1) {
+			return i, fmt.Errorf("audit record %d is missing", i+1)
+		}
+		if record.PrevHash != prevHash {
+			return i, fmt.Errorf("audit record %d does not follow the previous one", record.Seq)
+		}
+		hash, err := Hash(record)
+		if err != nil {
+			return i, err
+		}
+		if hash != record.Hash {
+			return i, fmt.Errorf("audit record %d was modified", record.Seq)
+		}
+		prevHash = record.Hash
+	}
+	return len(records), nil
+}
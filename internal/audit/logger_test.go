@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+)
+
+func TestLoggerChainsRecordsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	repo, err := repository.NewFileAuditRepository(path)
+	if err != nil {
+		t.Fatalf("Failed to create audit repository: %v", err)
+	}
+
+	var copied bytes.Buffer
+	logger := NewLogger(repo, NewWriterSink(&copied))
+	userID, adminID := uuid.New(), uuid.New()
+	ctx := models.WithClientInfo(context.Background(), models.ClientInfo{
+		IP:        "10.0.0.5",
+		UserAgent: "curl/8.0",
+		RequestID: "req-1",
+		ActorID:   adminID,
+	})
+
+	logger.Record(ctx, &models.AuditRecord{Action: models.AuditAdminUserUpdate, TargetUserID: userID, Outcome: models.AuditSuccess})
+	logger.Record(context.Background(), &models.AuditRecord{Action: models.AuditLogin, TargetUserID: userID, Outcome: models.AuditFailure, Reason: "invalid password"})
+
+	if lines := strings.Count(copied.String(), "\n"); lines != 2 {
+		t.Errorf("Expected both records in the sink, got %d", lines)
+	}
+
+	// Новый процесс продолжает цепочку с конца файла
+	reloaded, err := repository.NewFileAuditRepository(path)
+	if err != nil {
+		t.Fatalf("Failed to reload audit repository: %v", err)
+	}
+	logger = NewLogger(reloaded)
+	logger.Record(ctx, &models.AuditRecord{Action: models.AuditLogout, TargetUserID: userID, Outcome: models.AuditSuccess})
+
+	if n, err := logger.Verify(ctx); err != nil || n != 3 {
+		t.Fatalf("Expected a valid chain of 3 records, got %d, %v", n, err)
+	}
+
+	records, _ := logger.Query(ctx, models.AuditFilter{UserID: adminID})
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records by admin, got %d", len(records))
+	}
+	first := records[0]
+	if first.IP != "10.0.0.5" || first.UserAgent != "curl/8.0" || first.RequestID != "req-1" {
+		t.Errorf("Expected client info from context, got %+v", first)
+	}
+	if records[1].Seq != 3 || records[1].PrevHash == "" {
+		t.Errorf("Expected third record to continue the chain, got seq %d", records[1].Seq)
+	}
+}
+
+func TestVerifyChainDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryAuditRepository()
+	logger := NewLogger(repo)
+	for i := 0; i < 3; i++ {
+		logger.Record(ctx, &models.AuditRecord{Action: models.AuditLogin, TargetUserID: uuid.New(), Outcome: models.AuditSuccess})
+	}
+	records, _ := repo.Query(ctx, models.AuditFilter{})
+
+	records[1].Outcome = models.AuditFailure
+	if n, err := VerifyChain(records); err == nil || n != 1 {
+		t.Errorf("Expected modified record to break the chain, got %d, %v", n, err)
+	}
+
+	records, _ = repo.Query(ctx, models.AuditFilter{})
+	if n, err := VerifyChain(append(records[:1], records[2:]...)); err == nil || n != 1 {
+		t.Errorf("Expected removed record to break the chain, got %d, %v", n, err)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(ctx context.Context, record *models.AuditRecord) error {
+	return errors.New("sink unavailable")
+}
+
+func TestLoggerReportsSinkFailures(t *testing.T) {
+	repo, err := repository.NewFileAuditRepository(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("Failed to create audit repository: %v", err)
+	}
+
+	var out bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&out), zapcore.DebugLevel)
+	ctx := logger.CtxWWithLogger(context.Background(), logger.FromZap(zap.New(core)))
+	failures := testutil.ToFloat64(metrics.AuditWriteFailuresTotal.WithLabelValues(metrics.AuditStageSink))
+
+	NewLogger(repo, failingSink{}).Record(ctx, &models.AuditRecord{Action: models.AuditLogout, TargetUserID: uuid.New(), Outcome: models.AuditSuccess})
+
+	if got := testutil.ToFloat64(metrics.AuditWriteFailuresTotal.WithLabelValues(metrics.AuditStageSink)); got != failures+1 {
+		t.Errorf("Expected sink failure to be counted, got %v", got-failures)
+	}
+	if !strings.Contains(out.String(), `"msg":"Failed to copy audit record to sink"`) || !strings.Contains(out.String(), "sink unavailable") {
+		t.Errorf("Expected sink failure in the log, got %s", out.String())
+	}
+}
@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// WriterSink пишет записи аудита в поток по JSON объекту на строку
+type WriterSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewWriterSink создает приемник, например, для os.Stdout, откуда журнал забирает сборщик логов
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(ctx context.Context, record *models.AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+// NewFileSink открывает файл на дописывание. Копия журнала на другом томе
+// позволяет восстановить его при потере основного файла.
+func NewFileSink(path string) (*WriterSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit sink directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit sink file: %w", err)
+	}
+	return NewWriterSink(file), nil
+}
@@ -3,6 +3,7 @@ package errdefs
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -12,6 +13,8 @@ var (
 	ErrConflict     = errors.New("conflict")
 	ErrUnauthorized = errors.New("unauthorized")
 	ErrInternal     = errors.New("internal server error")
+	ErrForbidden    = errors.New("permission denied")
+	ErrUnavailable  = errors.New("service unavailable")
 
 	// ошибки пакета security
 	ErrGetHashPswd 	= errors.New("error hashing password")
@@ -25,8 +28,46 @@ var (
 	ErrExpiredToken = errors.New("token expired")
 	ErrInvalidToken = errors.New("invalid token")
 	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrTokenReused = errors.New("refresh token reuse detected")
+	ErrMFARequired = errors.New("second factor required")
+	ErrInvalidMFACode = errors.New("invalid second factor code")
+	ErrWebAuthnFailed = errors.New("webauthn verification failed")
+	ErrRateLimited = errors.New("too many requests")
+	ErrVerificationTokenExpired = errors.New("verification token expired")
+	ErrVerificationTokenUsed = errors.New("verification token already used")
+	ErrVerificationEmailMismatch = errors.New("verification token was issued for another email")
+	ErrCursorExpired = errors.New("cursor expired, resync required")
+	ErrAccountDisabled = errors.New("account is locked or inactive")
 )
 
+// MFARequiredError - пароль верный, но для входа нужен второй фактор.
+// ChallengeToken предъявляется вместе с кодом на втором шаге входа.
+type MFARequiredError struct {
+	ChallengeToken string
+	Methods        []string
+}
+
+func (e *MFARequiredError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFARequiredError) Is(target error) bool {
+	return target == ErrMFARequired
+}
+
+// RateLimitError - лимит попыток исчерпан, следующая попытка возможна через RetryAfter
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
 // Просто обертка, лучше в var добавить новую ошибку и использовать её
 func New(text string) error {
 	return errors.New(text)
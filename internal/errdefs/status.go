@@ -0,0 +1,232 @@
+package errdefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PublicError - ошибка, текст которой можно показать клиенту как есть.
+// Kind задает категорию ответа (код gRPC и HTTP статус), Code - стабильный машинный код.
+type PublicError struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+func (e *PublicError) Error() string {
+	return e.Message
+}
+
+func (e *PublicError) Unwrap() error {
+	return e.Kind
+}
+
+// Public создает ошибку категории kind с машинным кодом и текстом для клиента
+func Public(kind error, code, format string, args ...interface{}) error {
+	return &PublicError{Kind: kind, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// FieldViolation - нарушение в конкретном поле запроса
+type FieldViolation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError - запрос не прошел проверку, Fields перечисляет все нарушения
+type ValidationError struct {
+	Fields []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// InvalidField создает ошибку проверки одного поля
+func InvalidField(field, code, format string, args ...interface{}) error {
+	return &ValidationError{Fields: []FieldViolation{{Field: field, Code: code, Message: fmt.Sprintf(format, args...)}}}
+}
+
+// CodeValidationFailed - машинный код ошибки проверки полей запроса
+const CodeValidationFailed = "request.validation_failed"
+
+// Правило трансляции: категория ошибки, коды ответа и безопасный текст по умолчанию
+type translation struct {
+	kind    error
+	code    codes.Code
+	status  int
+	errCode string
+	message string
+}
+
+// Единая таблица трансляции доменных ошибок в ответы gRPC и HTTP.
+// Порядок важен: побеждает первое совпадение.
+var translations = []translation{
+	{ErrInvalidInput, codes.InvalidArgument, http.StatusBadRequest, "request.invalid", "invalid input"},
+	{ErrInvalidCredentials, codes.Unauthenticated, http.StatusUnauthorized, "auth.invalid_credentials", "invalid email or password"},
+	{ErrInvalidMFACode, codes.Unauthenticated, http.StatusUnauthorized, "auth.invalid_mfa_code", "invalid second factor code"},
+	{ErrMFARequired, codes.Unauthenticated, http.StatusUnauthorized, "auth.mfa_required", "second factor required"},
+	{ErrWebAuthnFailed, codes.Unauthenticated, http.StatusUnauthorized, "auth.passkey_failed", "passkey verification failed"},
+	{ErrExpiredToken, codes.Unauthenticated, http.StatusUnauthorized, "auth.token_expired", "token expired"},
+	{ErrInvalidToken, codes.Unauthenticated, http.StatusUnauthorized, "auth.invalid_token", "invalid token"},
+	{ErrTokenReused, codes.Unauthenticated, http.StatusUnauthorized, "auth.invalid_token", "invalid token"},
+	{ErrUnauthorized, codes.Unauthenticated, http.StatusUnauthorized, "auth.unauthorized", "unauthorized"},
+	{ErrAccountDisabled, codes.PermissionDenied, http.StatusForbidden, "auth.account_disabled", "account is locked or inactive"},
+	{ErrForbidden, codes.PermissionDenied, http.StatusForbidden, "auth.forbidden", "permission denied"},
+	{ErrNotFound, codes.NotFound, http.StatusNotFound, "resource.not_found", "not found"},
+	{ErrVerificationTokenExpired, codes.FailedPrecondition, http.StatusGone, "auth.link_expired", "link expired"},
+	{ErrVerificationTokenUsed, codes.FailedPrecondition, http.StatusConflict, "auth.link_used", "link was already used"},
+	{ErrVerificationEmailMismatch, codes.FailedPrecondition, http.StatusConflict, "auth.link_email_mismatch", "link was issued for another email"},
+	{ErrConflict, codes.AlreadyExists, http.StatusConflict, "resource.conflict", "already exists"},
+	{ErrRateLimited, codes.ResourceExhausted, http.StatusTooManyRequests, "request.rate_limited", "too many requests"},
+	{ErrCursorExpired, codes.OutOfRange, http.StatusGone, "events.cursor_expired", "cursor expired, resync required"},
+	{ErrUnavailable, codes.Unavailable, http.StatusServiceUnavailable, "service.unavailable", "service temporarily unavailable"},
+	{ErrDB, codes.Unavailable, http.StatusServiceUnavailable, "service.unavailable", "service temporarily unavailable"},
+	{context.DeadlineExceeded, codes.Unavailable, http.StatusServiceUnavailable, "service.unavailable", "service temporarily unavailable"},
+	{context.Canceled, codes.Canceled, 499, "request.canceled", "request canceled"},
+}
+
+var internalTranslation = translation{ErrInternal, codes.Internal, http.StatusInternalServerError, "service.internal", "internal server error"}
+
+// Ответы соседних сервисов (DB manager, file service), которые имеют смысл для клиента.
+// Остальные коды - наша внутренняя проблема.
+var downstreamKinds = map[codes.Code]error{
+	codes.NotFound:         ErrNotFound,
+	codes.Unavailable:      ErrUnavailable,
+	codes.DeadlineExceeded: ErrUnavailable,
+}
+
+func translate(err error) translation {
+	for _, t := range translations {
+		if errors.Is(err, t.kind) {
+			return t
+		}
+	}
+	var downstream interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &downstream) {
+		if kind, ok := downstreamKinds[downstream.GRPCStatus().Code()]; ok {
+			return translate(kind)
+		}
+	}
+	return internalTranslation
+}
+
+// GRPCCode возвращает код gRPC для ошибки
+func GRPCCode(err error) codes.Code {
+	return translate(err).code
+}
+
+// HTTPStatus возвращает HTTP статус для ошибки
+func HTTPStatus(err error) int {
+	return translate(err).status
+}
+
+// PublicMessage возвращает текст, который можно отдать клиенту: сообщение PublicError
+// или текст категории. Подробности внутренних ошибок клиенту не попадают.
+func PublicMessage(err error) string {
+	t := translate(err)
+	if invalid, ok := validationError(err, t); ok {
+		return invalid.Error()
+	}
+	if public, ok := publicError(err, t); ok {
+		return public.Message
+	}
+	return t.message
+}
+
+// Code возвращает стабильный машинный код ошибки, например auth.email_taken
+func Code(err error) string {
+	t := translate(err)
+	if _, ok := validationError(err, t); ok {
+		return CodeValidationFailed
+	}
+	if public, ok := publicError(err, t); ok && public.Code != "" {
+		return public.Code
+	}
+	return t.errCode
+}
+
+// Fields возвращает нарушения по полям для ошибки проверки запроса
+func Fields(err error) []FieldViolation {
+	if invalid, ok := validationError(err, translate(err)); ok {
+		return invalid.Fields
+	}
+	return nil
+}
+
+// Публичные подробности учитываются, только если они относятся к выбранной категории
+func publicError(err error, t translation) (*PublicError, bool) {
+	var public *PublicError
+	if errors.As(err, &public) && errors.Is(public.Kind, t.kind) {
+		return public, true
+	}
+	return nil, false
+}
+
+func validationError(err error, t translation) (*ValidationError, bool) {
+	var invalid *ValidationError
+	if t.kind == ErrInvalidInput && errors.As(err, &invalid) {
+		return invalid, true
+	}
+	return nil, false
+}
+
+// Домен машинных кодов в ErrorInfo
+const errorDomain = "homecloud-auth-service"
+
+// GRPCStatus переводит ошибку в статус gRPC с безопасным текстом. В деталях передаются
+// машинный код (ErrorInfo) и нарушения по полям (BadRequest)
+func GRPCStatus(err error) *status.Status {
+	st := status.New(GRPCCode(err), PublicMessage(err))
+	info := &errdetails.ErrorInfo{Reason: Code(err), Domain: errorDomain}
+	fields := Fields(err)
+	if len(fields) == 0 {
+		if detailed, detailsErr := st.WithDetails(info); detailsErr == nil {
+			return detailed
+		}
+		return st
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Reason:      field.Code,
+			Description: field.Message,
+		})
+	}
+	if detailed, detailsErr := st.WithDetails(info, badRequest); detailsErr == nil {
+		return detailed
+	}
+	return st
+}
+
+// IsNotFound - объект не найден локально или в соседнем сервисе
+func IsNotFound(err error) bool {
+	return translate(err).code == codes.NotFound
+}
+
+// IsUnavailable - хранилище или соседний сервис временно недоступны
+func IsUnavailable(err error) bool {
+	return translate(err).code == codes.Unavailable
+}
+
+// IsServerError - ошибка на стороне сервиса (5xx), а не клиента
+func IsServerError(err error) bool {
+	return HTTPStatus(err) >= http.StatusInternalServerError
+}
@@ -0,0 +1,104 @@
+package errdefs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTranslation(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		status  int
+		message string
+	}{
+		{"invalid input", InvalidField("email", "invalid_format", "invalid email format"), codes.InvalidArgument, http.StatusBadRequest, "invalid email format"},
+		{"conflict", fmt.Errorf("register: %w", Public(ErrConflict, "auth.email_taken", "email already exists")), codes.AlreadyExists, http.StatusConflict, "email already exists"},
+		{"credentials", ErrInvalidCredentials, codes.Unauthenticated, http.StatusUnauthorized, "invalid email or password"},
+		{"expired token", fmt.Errorf("invalid token: %w", ErrExpiredToken), codes.Unauthenticated, http.StatusUnauthorized, "token expired"},
+		{"disabled", ErrAccountDisabled, codes.PermissionDenied, http.StatusForbidden, "account is locked or inactive"},
+		{"not found", fmt.Errorf("session: %w", ErrNotFound), codes.NotFound, http.StatusNotFound, "not found"},
+		{"rate limited", &RateLimitError{}, codes.ResourceExhausted, http.StatusTooManyRequests, "too many requests"},
+		{"db", fmt.Errorf("failed to check email: %w", ErrDB), codes.Unavailable, http.StatusServiceUnavailable, "service temporarily unavailable"},
+		{"downstream unavailable", fmt.Errorf("failed to get user: %w", status.Error(codes.Unavailable, "dial tcp 10.0.0.5:50051: connection refused")), codes.Unavailable, http.StatusServiceUnavailable, "service temporarily unavailable"},
+		{"downstream not found", status.Error(codes.NotFound, "no rows in result set"), codes.NotFound, http.StatusNotFound, "not found"},
+		{"downstream internal", status.Error(codes.InvalidArgument, "pq: syntax error"), codes.Internal, http.StatusInternalServerError, "internal server error"},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), codes.Unavailable, http.StatusServiceUnavailable, "service temporarily unavailable"},
+		{"unknown", fmt.Errorf("failed to hash password: bcrypt: cost 99 out of range"), codes.Internal, http.StatusInternalServerError, "internal server error"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := GRPCCode(tc.err); code != tc.code {
+				t.Errorf("GRPCCode = %s, want %s", code, tc.code)
+			}
+			if got := HTTPStatus(tc.err); got != tc.status {
+				t.Errorf("HTTPStatus = %d, want %d", got, tc.status)
+			}
+			if got := PublicMessage(tc.err); got != tc.message {
+				t.Errorf("PublicMessage = %q, want %q", got, tc.message)
+			}
+		})
+	}
+}
+
+func TestPublicMessageDoesNotLeakInternals(t *testing.T) {
+	err := fmt.Errorf("failed to store session: %w", fmt.Errorf("pq: duplicate key value violates unique constraint %q: %w", "sessions_pkey", ErrConflict))
+
+	st := GRPCStatus(err)
+	if st.Code() != codes.AlreadyExists {
+		t.Fatalf("code = %s, want AlreadyExists", st.Code())
+	}
+	if strings.Contains(st.Message(), "pq") || strings.Contains(st.Message(), "sessions_pkey") {
+		t.Fatalf("status message leaks internals: %q", st.Message())
+	}
+}
+
+func TestPublicErrorKeepsKind(t *testing.T) {
+	err := Public(ErrInvalidInput, "webhooks.unknown_event", "unknown event type %q", "user.exploded")
+	if !Is(err, ErrInvalidInput) {
+		t.Fatal("public error must match its kind")
+	}
+	if err.Error() != `unknown event type "user.exploded"` {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestGRPCStatusCarriesDetails(t *testing.T) {
+	err := &ValidationError{Fields: []FieldViolation{
+		{Field: "email", Code: "invalid_format", Message: "email must be a valid email address"},
+	}}
+
+	st := GRPCStatus(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("code = %s", st.Code())
+	}
+
+	var info *errdetails.ErrorInfo
+	var badRequest *errdetails.BadRequest
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = d
+		case *errdetails.BadRequest:
+			badRequest = d
+		}
+	}
+	if info == nil || info.GetReason() != CodeValidationFailed {
+		t.Fatalf("unexpected error info: %v", info)
+	}
+	if badRequest == nil || len(badRequest.GetFieldViolations()) != 1 {
+		t.Fatalf("unexpected bad request: %v", badRequest)
+	}
+	if violation := badRequest.GetFieldViolations()[0]; violation.GetField() != "email" || violation.GetReason() != "invalid_format" {
+		t.Fatalf("unexpected violation: %v", violation)
+	}
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+)
+
+// Dispatcher доставляет события из outbox во все приемники. Событие удаляется из outbox
+// после доставки во все приемники; неудачные приемники повторяются с растущей паузой.
+type Dispatcher struct {
+	outbox    interfaces.OutboxRepository
+	sinks     []interfaces.EventSink
+	batchSize int
+	baseDelay time.Duration // пауза после первой неудачи, удваивается
+	maxDelay  time.Duration
+	lg        *logger.Logger
+}
+
+// NewDispatcher создает диспетчер. Неположительные паузы заменяются значениями по умолчанию,
+// ошибки фонового цикла пишутся в lg.
+func NewDispatcher(outbox interfaces.OutboxRepository, sinks []interfaces.EventSink, baseDelay, maxDelay time.Duration, lg *logger.Logger) *Dispatcher {
+	if baseDelay <= 0 {
+		baseDelay = 5 * time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = 10 * time.Minute
+	}
+	return &Dispatcher{
+		outbox:    outbox,
+		sinks:     sinks,
+		batchSize: 100,
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
+		lg:        lg,
+	}
+}
+
+// Run периодически доставляет события до отмены ctx
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := d.DispatchDue(ctx, now); err != nil {
+				d.lg.Error(ctx, "Failed to dispatch user events", zap.Error(err))
+			}
+		}
+	}
+}
+
+// DispatchDue доставляет события, у которых подошло время попытки.
+// Возвращает число событий, доставленных во все приемники.
+func (d *Dispatcher) DispatchDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := d.outbox.ListDue(ctx, now, d.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list outbox: %w", err)
+	}
+
+	completed := 0
+	for _, record := range due {
+		var lastErr error
+		for _, sink := range d.sinks {
+			if record.IsDeliveredTo(sink.Name()) {
+				continue
+			}
+			if err := sink.Deliver(ctx, &record.Event); err != nil {
+				lastErr = fmt.Errorf("%s: %w", sink.Name(), err)
+				continue
+			}
+			record.Delivered = append(record.Delivered, sink.Name())
+		}
+
+		if lastErr == nil {
+			if err := d.outbox.Delete(ctx, record.Event.ID); err != nil {
+				return completed, fmt.Errorf("failed to delete delivered event %s: %w", record.Event.ID, err)
+			}
+			completed++
+			continue
+		}
+
+		record.LastError = lastErr.Error()
+		record.NextAttemptAt = now.Add(d.backoff(record.Attempts))
+		record.Attempts++
+		if err := d.outbox.Update(ctx, record); err != nil {
+			return completed, fmt.Errorf("failed to update event %s: %w", record.Event.ID, err)
+		}
+	}
+	return completed, nil
+}
+
+// backoff - пауза перед следующей попыткой после attempts неудачных
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.baseDelay
+	for i := 0; i < attempts && delay < d.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxDelay)
+}
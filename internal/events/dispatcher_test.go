@@ -0,0 +1,156 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+)
+
+// flakySink не принимает первые failures событий
+type flakySink struct {
+	name     string
+	failures int
+	received []uuid.UUID
+}
+
+func (s *flakySink) Name() string { return s.name }
+
+func (s *flakySink) Deliver(ctx context.Context, event *models.UserEvent) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("receiver is down")
+	}
+	s.received = append(s.received, event.ID)
+	return nil
+}
+
+func newEvent(eventType string) *models.UserEvent {
+	return &models.UserEvent{ID: uuid.New(), Type: eventType, UserID: uuid.New(), OccurredAt: time.Now()}
+}
+
+func TestDispatcherRetriesFailedSinkOnly(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepository()
+	stable := &flakySink{name: "stable"}
+	flaky := &flakySink{name: "flaky", failures: 2}
+	dispatcher := NewDispatcher(outbox, []interfaces.EventSink{stable, flaky}, time.Second, 3*time.Second, logger.Nop())
+
+	event := newEvent(models.EventUserRegistered)
+	if err := outbox.Append(ctx, event); err != nil {
+		t.Fatalf("Failed to append event: %v", err)
+	}
+
+	now := time.Now()
+	if n, err := dispatcher.DispatchDue(ctx, now); err != nil || n != 0 {
+		t.Fatalf("Expected no completed events on failure, got %d, %v", n, err)
+	}
+
+	// До истечения паузы событие не повторяется
+	if n, _ := dispatcher.DispatchDue(ctx, now.Add(500*time.Millisecond)); n != 0 || len(stable.received) != 1 {
+		t.Fatalf("Expected event to wait for backoff, completed %d", n)
+	}
+
+	// Вторая неудача удваивает паузу
+	dispatcher.DispatchDue(ctx, now.Add(time.Second))
+	due, _ := outbox.ListDue(ctx, now.Add(2*time.Second), 10)
+	if len(due) != 0 {
+		t.Fatalf("Expected backoff to double after second failure")
+	}
+
+	if n, err := dispatcher.DispatchDue(ctx, now.Add(3*time.Second)); err != nil || n != 1 {
+		t.Fatalf("Expected event to be delivered, got %d, %v", n, err)
+	}
+	if len(stable.received) != 1 || len(flaky.received) != 1 {
+		t.Errorf("Expected each sink to get the event once, got %d and %d", len(stable.received), len(flaky.received))
+	}
+	if due, _ := outbox.ListDue(ctx, now.Add(time.Hour), 10); len(due) != 0 {
+		t.Errorf("Expected delivered event to leave the outbox")
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	dispatcher := NewDispatcher(repository.NewMemoryOutboxRepository(), nil, time.Second, 10*time.Second, logger.Nop())
+	for attempts, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		if got := dispatcher.backoff(attempts); got != expected {
+			t.Errorf("backoff(%d) = %v, expected %v", attempts, got, expected)
+		}
+	}
+	if got := dispatcher.backoff(100); got != 10*time.Second {
+		t.Errorf("Expected backoff to stay at max, got %v", got)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received models.UserEvent
+	var idempotencyKey string
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink("hook", server.URL, time.Second)
+	event := newEvent(models.EventUserLocked)
+
+	if err := sink.Deliver(context.Background(), event); err == nil {
+		t.Fatal("Expected non-2xx response to fail delivery")
+	}
+
+	status = http.StatusNoContent
+	if err := sink.Deliver(context.Background(), event); err != nil {
+		t.Fatalf("Failed to deliver: %v", err)
+	}
+	if received.ID != event.ID || received.Type != models.EventUserLocked || idempotencyKey != event.ID.String() {
+		t.Errorf("Unexpected request: %+v, key %q", received, idempotencyKey)
+	}
+}
+
+func TestFileSinkAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "events.jsonl")
+	sink, err := NewFileSink("log", path)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	first, second := newEvent(models.EventUserRegistered), newEvent(models.EventEmailVerified)
+	for _, event := range []*models.UserEvent{first, second} {
+		if err := sink.Deliver(context.Background(), event); err != nil {
+			t.Fatalf("Failed to deliver: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open events file: %v", err)
+	}
+	defer file.Close()
+
+	var ids []uuid.UUID
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event models.UserEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, event.ID)
+	}
+	if len(ids) != 2 || ids[0] != first.ID || ids[1] != second.ID {
+		t.Errorf("Unexpected events in file: %v", ids)
+	}
+}
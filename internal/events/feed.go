@@ -0,0 +1,135 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/models"
+)
+
+// FeedSinkName - имя приемника ленты WatchUserEvents в диспетчере outbox
+const FeedSinkName = "watch"
+
+// feedKinds - события, меняющие данные профиля, которые кэшируют другие сервисы.
+// Остальные события (вход, смена пароля) в ленту не попадают.
+var feedKinds = map[string]string{
+	models.EventUserRegistered:  models.UserChangeCreated,
+	models.EventEmailVerified:   models.UserChangeUpdated,
+	models.EventEmailChanged:    models.UserChangeUpdated,
+	models.EventUsernameChanged: models.UserChangeUpdated,
+	models.EventUserEnabled:     models.UserChangeUpdated,
+	models.EventUserDisabled:    models.UserChangeDisabled,
+	models.EventQuotaChanged:    models.UserChangeQuotaChanged,
+}
+
+// Feed - лента изменений пользователей. Получает события из outbox как приемник
+// и раздает их подписчикам WatchUserEvents.
+type Feed struct {
+	repo      interfaces.EventFeedRepository
+	batchSize int
+
+	mu      sync.Mutex
+	changed chan struct{} // закрывается и заменяется при каждой новой записи
+}
+
+// NewFeed создает ленту поверх хранилища
+func NewFeed(repo interfaces.EventFeedRepository) *Feed {
+	return &Feed{
+		repo:      repo,
+		batchSize: 100,
+		changed:   make(chan struct{}),
+	}
+}
+
+func (f *Feed) Name() string {
+	return FeedSinkName
+}
+
+func (f *Feed) Deliver(ctx context.Context, event *models.UserEvent) error {
+	kind, ok := feedKinds[event.Type]
+	if !ok {
+		return nil
+	}
+	if _, err := f.repo.Append(ctx, kind, event); err != nil {
+		return fmt.Errorf("failed to append event to feed: %w", err)
+	}
+
+	f.mu.Lock()
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.mu.Unlock()
+	return nil
+}
+
+// Watch отправляет записи ленты после cursor, затем ждет новые
+func (f *Feed) Watch(ctx context.Context, cursor string, send func(cursor string, entry *models.FeedEntry) error) error {
+	state, err := f.repo.State(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read feed state: %w", err)
+	}
+
+	seq := state.LastSeq
+	if cursor != "" {
+		seq, err = parseCursor(cursor, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		// Канал берется до чтения, чтобы не пропустить запись между чтением и ожиданием
+		f.mu.Lock()
+		changed := f.changed
+		f.mu.Unlock()
+
+		entries, err := f.repo.ListAfter(ctx, seq, f.batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read feed: %w", err)
+		}
+		// Клиент отстал сильнее, чем хранится лента: часть записей уже удалена
+		if len(entries) > 0 && entries[0].Seq > seq+1 {
+			return fmt.Errorf("feed entries after %d were removed: %w", seq, errdefs.ErrCursorExpired)
+		}
+		for _, entry := range entries {
+			if err := send(formatCursor(state.Epoch, entry.Seq), entry); err != nil {
+				return err
+			}
+			seq = entry.Seq
+		}
+		if len(entries) == f.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Курсор - "<эпоха>:<номер записи>". Эпоха отличает ленты разных запусков
+// без файлового хранилища, где нумерация начинается заново.
+func formatCursor(epoch string, seq uint64) string {
+	return epoch + ":" + strconv.FormatUint(seq, 10)
+}
+
+func parseCursor(cursor string, state models.FeedState) (uint64, error) {
+	epoch, rawSeq, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return 0, fmt.Errorf("%w: malformed cursor", errdefs.ErrInvalidInput)
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed cursor", errdefs.ErrInvalidInput)
+	}
+	if epoch != state.Epoch || seq > state.LastSeq || seq+1 < state.FirstSeq {
+		return 0, errdefs.ErrCursorExpired
+	}
+	return seq, nil
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+)
+
+type watchedEntry struct {
+	cursor string
+	entry  *models.FeedEntry
+}
+
+// watch запускает Watch в фоне и возвращает канал полученных записей
+func watch(t *testing.T, ctx context.Context, feed *Feed, cursor string) (<-chan watchedEntry, <-chan error) {
+	t.Helper()
+	received := make(chan watchedEntry, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- feed.Watch(ctx, cursor, func(cursor string, entry *models.FeedEntry) error {
+			received <- watchedEntry{cursor: cursor, entry: entry}
+			return nil
+		})
+	}()
+	return received, done
+}
+
+func next(t *testing.T, received <-chan watchedEntry) watchedEntry {
+	t.Helper()
+	select {
+	case got := <-received:
+		return got
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for feed entry")
+		return watchedEntry{}
+	}
+}
+
+func TestFeedWatchResumesFromCursor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := repository.NewMemoryEventFeedRepository(time.Hour)
+	feed := NewFeed(repo)
+
+	// Вход не меняет профиль и в ленту не попадает
+	if err := feed.Deliver(ctx, newEvent(models.EventLoginNewDevice)); err != nil {
+		t.Fatalf("Failed to deliver event: %v", err)
+	}
+	state, _ := repo.State(ctx)
+	if state.LastSeq != 0 {
+		t.Fatalf("Expected login event to be skipped, feed has %d entries", state.LastSeq)
+	}
+
+	received, _ := watch(t, ctx, feed, formatCursor(state.Epoch, state.LastSeq))
+	created := newEvent(models.EventUserRegistered)
+	feed.Deliver(ctx, created)
+	first := next(t, received)
+	if first.entry.Kind != models.UserChangeCreated || first.entry.Event.ID != created.ID {
+		t.Fatalf("Unexpected first entry: %+v", first.entry)
+	}
+
+	quota := newEvent(models.EventQuotaChanged)
+	disabled := newEvent(models.EventUserDisabled)
+	feed.Deliver(ctx, quota)
+	feed.Deliver(ctx, disabled)
+	next(t, received)
+	next(t, received)
+
+	// Переподключение с курсора первой записи продолжает с второй
+	resumed, _ := watch(t, ctx, feed, first.cursor)
+	if got := next(t, resumed); got.entry.Kind != models.UserChangeQuotaChanged {
+		t.Errorf("Expected quota change after cursor, got %s", got.entry.Kind)
+	}
+	if got := next(t, resumed); got.entry.Kind != models.UserChangeDisabled {
+		t.Errorf("Expected disable after quota change, got %s", got.entry.Kind)
+	}
+}
+
+func TestFeedWatchRejectsStaleCursor(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryEventFeedRepository(time.Minute)
+	feed := NewFeed(repo)
+
+	old := newEvent(models.EventUserRegistered)
+	old.OccurredAt = time.Now().Add(-time.Hour)
+	feed.Deliver(ctx, old)
+	feed.Deliver(ctx, newEvent(models.EventUsernameChanged))
+	state, _ := repo.State(ctx)
+	repo.DeleteExpired(ctx, time.Now())
+
+	send := func(string, *models.FeedEntry) error { return nil }
+	// Курсор до удаленной записи
+	err := feed.Watch(ctx, formatCursor(state.Epoch, 0), send)
+	if !errors.Is(err, errdefs.ErrCursorExpired) {
+		t.Errorf("Expected expired cursor, got %v", err)
+	}
+	// Курсор другого запуска сервиса
+	err = feed.Watch(ctx, formatCursor("other-epoch", 1), send)
+	if !errors.Is(err, errdefs.ErrCursorExpired) {
+		t.Errorf("Expected expired cursor for another epoch, got %v", err)
+	}
+	err = feed.Watch(ctx, "garbage", send)
+	if !errors.Is(err, errdefs.ErrInvalidInput) {
+		t.Errorf("Expected invalid cursor, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// FileSink дописывает события в файл, по одному JSON объекту на строку
+type FileSink struct {
+	name string
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSink создает приемник и каталог для файла
+func NewFileSink(name, path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create events directory: %w", err)
+	}
+	return &FileSink{name: name, path: path}, nil
+}
+
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+func (s *FileSink) Deliver(ctx context.Context, event *models.UserEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open events file: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	// Событие удаляется из outbox после Deliver, поэтому оно должно быть на диске
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync events file: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"homecloud-auth-service/internal/models"
+	pb "homecloud-auth-service/internal/transport/grpc/protos"
+)
+
+// GRPCSink вызывает UserEventReceiver.PushUserEvent сервиса-потребителя
+type GRPCSink struct {
+	name    string
+	conn    *grpc.ClientConn
+	client  pb.UserEventReceiverClient
+	timeout time.Duration
+}
+
+// NewGRPCSink создает приемник. Соединение устанавливается при первом вызове.
+func NewGRPCSink(name, address string, timeout time.Duration) (*GRPCSink, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event receiver client: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &GRPCSink{
+		name:    name,
+		conn:    conn,
+		client:  pb.NewUserEventReceiverClient(conn),
+		timeout: timeout,
+	}, nil
+}
+
+func (s *GRPCSink) Name() string {
+	return s.name
+}
+
+func (s *GRPCSink) Deliver(ctx context.Context, event *models.UserEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.client.PushUserEvent(ctx, EventToProto(event))
+	if err != nil {
+		return fmt.Errorf("failed to push event: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает соединение с получателем
+func (s *GRPCSink) Close() error {
+	return s.conn.Close()
+}
+
+// EventToProto преобразует событие в сообщение gRPC
+func EventToProto(event *models.UserEvent) *pb.UserEvent {
+	return &pb.UserEvent{
+		Id:         event.ID.String(),
+		Type:       event.Type,
+		UserId:     event.UserID.String(),
+		Data:       event.Data,
+		OccurredAt: event.OccurredAt.Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// HTTPSink отправляет события POST запросом с JSON телом. Успех - любой 2xx ответ.
+// Заголовок Idempotency-Key содержит ID события для отбрасывания дубликатов.
+type HTTPSink struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink создает приемник. timeout <= 0 - 10 секунд.
+func NewHTTPSink(name, url string, timeout time.Duration) *HTTPSink {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &HTTPSink{
+		name:   name,
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSink) Name() string {
+	return s.name
+}
+
+func (s *HTTPSink) Deliver(ctx context.Context, event *models.UserEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID.String())
+	req.Header.Set("X-HomeCloud-Event", event.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return nil
+}
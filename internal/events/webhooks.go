@@ -0,0 +1,239 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+)
+
+// Заголовки запросов вебхуков
+const (
+	WebhookSignatureHeader = "X-HomeCloud-Signature"
+	WebhookEventHeader     = "X-HomeCloud-Event"
+	WebhookDeliveryHeader  = "X-HomeCloud-Delivery"
+)
+
+// WebhookSinkName - имя приемника вебхуков в диспетчере outbox
+const WebhookSinkName = "webhooks"
+
+// SignPayload подписывает тело запроса: HMAC-SHA256 от "<timestamp>.<body>".
+// Результат - значение заголовка X-HomeCloud-Signature вида "t=<unix>,v1=<hex>".
+// Метка времени входит в подпись, чтобы получатель мог отбрасывать старые повторы.
+func SignPayload(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSink раскладывает событие из outbox на доставки подписанным на него вебхукам.
+// Сама отправка выполняется WebhookDeliverer, поэтому медленный получатель не задерживает outbox.
+type WebhookSink struct {
+	webhooks interfaces.WebhookRepository
+}
+
+// NewWebhookSink создает приемник
+func NewWebhookSink(webhooks interfaces.WebhookRepository) *WebhookSink {
+	return &WebhookSink{webhooks: webhooks}
+}
+
+func (s *WebhookSink) Name() string {
+	return WebhookSinkName
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, event *models.UserEvent) error {
+	subscriptions, err := s.webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(event.Type) {
+			continue
+		}
+		// ID доставки выводится из подписки и события: повтор Deliver после
+		// частичного сбоя не создаст дубликат
+		delivery := &models.WebhookDelivery{
+			ID:             uuid.NewSHA1(subscription.ID, event.ID[:]),
+			SubscriptionID: subscription.ID,
+			Event:          *event,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		err := s.webhooks.CreateDelivery(ctx, delivery)
+		if err != nil && !errdefs.Is(err, errdefs.ErrConflict) {
+			return fmt.Errorf("failed to queue webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+// WebhookDeliverer отправляет ожидающие доставки вебхуков. Неудачные попытки
+// повторяются с растущей паузой; после maxAttempts доставка попадает в dead letter
+// и повторяется только вручную.
+type WebhookDeliverer struct {
+	webhooks    interfaces.WebhookRepository
+	client      *http.Client
+	batchSize   int
+	maxAttempts int
+	baseDelay   time.Duration // пауза после первой неудачи, удваивается
+	maxDelay    time.Duration
+	lg          *logger.Logger
+}
+
+// NewWebhookDeliverer создает отправщик. Неположительные параметры заменяются значениями по умолчанию,
+// ошибки фонового цикла пишутся в lg.
+func NewWebhookDeliverer(webhooks interfaces.WebhookRepository, maxAttempts int, baseDelay, maxDelay, timeout time.Duration, lg *logger.Logger) *WebhookDeliverer {
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	if baseDelay <= 0 {
+		baseDelay = 10 * time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = time.Hour
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &WebhookDeliverer{
+		webhooks:    webhooks,
+		client:      &http.Client{Timeout: timeout},
+		batchSize:   100,
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		lg:          lg,
+	}
+}
+
+// Run периодически отправляет вебхуки до отмены ctx
+func (d *WebhookDeliverer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := d.DeliverDue(ctx, now); err != nil {
+				d.lg.Error(ctx, "Failed to deliver webhooks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// DeliverDue отправляет доставки, у которых подошло время попытки.
+// Возвращает число успешно доставленных вебхуков.
+func (d *WebhookDeliverer) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := d.webhooks.ListDueDeliveries(ctx, now, d.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	delivered := 0
+	for _, delivery := range due {
+		subscription, err := d.webhooks.GetSubscription(ctx, delivery.SubscriptionID)
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			// Подписку удалили после постановки в очередь
+			d.webhooks.DeleteDelivery(ctx, delivery.ID)
+			continue
+		}
+		if err != nil {
+			return delivered, fmt.Errorf("failed to get webhook subscription %s: %w", delivery.SubscriptionID, err)
+		}
+
+		sendErr := d.send(ctx, subscription, delivery, now)
+		if sendErr == nil {
+			if err := d.webhooks.DeleteDelivery(ctx, delivery.ID); err != nil {
+				return delivered, fmt.Errorf("failed to delete webhook delivery %s: %w", delivery.ID, err)
+			}
+			delivered++
+			continue
+		}
+
+		delivery.Attempts++
+		delivery.LastError = sendErr.Error()
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = models.WebhookDeliveryDead
+			// URL подписки не логируется: в query могут быть учетные данные получателя
+			d.lg.Error(ctx, "Webhook delivery moved to dead letters",
+				zap.String("delivery_id", delivery.ID.String()),
+				zap.String("subscription_id", subscription.ID.String()),
+				zap.Error(sendErr),
+			)
+		} else {
+			delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts - 1))
+		}
+		if err := d.webhooks.UpdateDelivery(ctx, delivery); err != nil {
+			return delivered, fmt.Errorf("failed to update webhook delivery %s: %w", delivery.ID, err)
+		}
+	}
+	return delivered, nil
+}
+
+func (d *WebhookDeliverer) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery, now time.Time) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignPayload(subscription.Secret, now, body))
+	req.Header.Set(WebhookEventHeader, delivery.Event.Type)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set("Idempotency-Key", delivery.Event.ID.String())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// Ошибка клиента содержит URL подписки; она попадает в лог и last_error доставки
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// backoff - пауза перед следующей попыткой после attempts+1 неудачных
+func (d *WebhookDeliverer) backoff(attempts int) time.Duration {
+	delay := d.baseDelay
+	for i := 0; i < attempts && delay < d.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxDelay)
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+)
+
+// webhookReceiver - получатель, отвечающий статусами из statuses по очереди
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestSignPayload(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	signature := SignPayload("secret", timestamp, []byte(`{"id":"1"}`))
+
+	// HMAC-SHA256("secret", `1700000000.{"id":"1"}`)
+	if !strings.HasPrefix(signature, "t=1700000000,v1=") {
+		t.Fatalf("Unexpected signature format: %s", signature)
+	}
+	if signature != SignPayload("secret", timestamp, []byte(`{"id":"1"}`)) {
+		t.Errorf("Expected signature to be deterministic")
+	}
+	if signature == SignPayload("other", timestamp, []byte(`{"id":"1"}`)) {
+		t.Errorf("Expected signature to depend on the secret")
+	}
+	if signature == SignPayload("secret", timestamp.Add(time.Second), []byte(`{"id":"1"}`)) {
+		t.Errorf("Expected signature to depend on the timestamp")
+	}
+}
+
+func TestWebhookSinkFiltersSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryWebhookRepository()
+	all := &models.WebhookSubscription{ID: uuid.New(), URL: "http://all.example.com"}
+	locks := &models.WebhookSubscription{ID: uuid.New(), URL: "http://locks.example.com", Events: []string{models.EventUserLocked}}
+	repo.CreateSubscription(ctx, all)
+	repo.CreateSubscription(ctx, locks)
+
+	sink := NewWebhookSink(repo)
+	event := newEvent(models.EventLoginNewDevice)
+	if err := sink.Deliver(ctx, event); err != nil {
+		t.Fatalf("Failed to deliver event: %v", err)
+	}
+	// Повторная доставка того же события из outbox не создает дубликат
+	if err := sink.Deliver(ctx, event); err != nil {
+		t.Fatalf("Failed to redeliver event: %v", err)
+	}
+
+	due, _ := repo.ListDueDeliveries(ctx, time.Now(), 10)
+	if len(due) != 1 || due[0].SubscriptionID != all.ID {
+		t.Fatalf("Expected a single delivery for the unfiltered subscription, got %d", len(due))
+	}
+}
+
+func TestWebhookDelivererRetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	subscription := &models.WebhookSubscription{ID: uuid.New(), URL: server.URL, Secret: "secret"}
+	repo.CreateSubscription(ctx, subscription)
+	if err := NewWebhookSink(repo).Deliver(ctx, newEvent(models.EventUserLocked)); err != nil {
+		t.Fatalf("Failed to queue webhook: %v", err)
+	}
+
+	deliverer := NewWebhookDeliverer(repo, 2, time.Second, time.Minute, time.Second, logger.Nop())
+	now := time.Now()
+	if n, err := deliverer.DeliverDue(ctx, now); err != nil || n != 0 {
+		t.Fatalf("Expected first attempt to fail, got %d, %v", n, err)
+	}
+	if due, _ := repo.ListDueDeliveries(ctx, now.Add(500*time.Millisecond), 10); len(due) != 0 {
+		t.Fatalf("Expected delivery to wait for backoff")
+	}
+
+	// Вторая неудача исчерпывает попытки
+	if n, err := deliverer.DeliverDue(ctx, now.Add(time.Second)); err != nil || n != 0 {
+		t.Fatalf("Expected second attempt to fail, got %d, %v", n, err)
+	}
+	dead, _ := repo.ListDeliveriesByStatus(ctx, models.WebhookDeliveryDead)
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].LastError == "" {
+		t.Fatalf("Expected delivery in dead letters, got %+v", dead)
+	}
+	if n, _ := deliverer.DeliverDue(ctx, now.Add(time.Hour)); n != 0 || len(receiver.requests) != 2 {
+		t.Fatalf("Expected dead letter not to be retried automatically")
+	}
+
+	// Ручной повтор
+	dead[0].Status = models.WebhookDeliveryPending
+	dead[0].Attempts = 0
+	dead[0].NextAttemptAt = now
+	repo.UpdateDelivery(ctx, dead[0])
+	if n, err := deliverer.DeliverDue(ctx, now.Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("Expected replayed delivery to succeed, got %d, %v", n, err)
+	}
+
+	last := receiver.requests[len(receiver.requests)-1]
+	body := receiver.bodies[len(receiver.bodies)-1]
+	timestamp, _ := strconv.ParseInt(strings.TrimPrefix(strings.Split(last.Header.Get(WebhookSignatureHeader), ",")[0], "t="), 10, 64)
+	if last.Header.Get(WebhookSignatureHeader) != SignPayload("secret", time.Unix(timestamp, 0), body) {
+		t.Errorf("Expected valid signature, got %q", last.Header.Get(WebhookSignatureHeader))
+	}
+	if last.Header.Get(WebhookEventHeader) != models.EventUserLocked || last.Header.Get(WebhookDeliveryHeader) != dead[0].ID.String() {
+		t.Errorf("Unexpected webhook headers: %v", last.Header)
+	}
+	if remaining, _ := repo.ListDeliveriesByStatus(ctx, models.WebhookDeliveryDead); len(remaining) != 0 {
+		t.Errorf("Expected delivered webhook to be removed")
+	}
+}
+
+func TestWebhookDeadLetterLogOmitsSubscriptionURL(t *testing.T) {
+	ctx := context.Background()
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	subscription := &models.WebhookSubscription{ID: uuid.New(), URL: "http://" + address + "/hook?token=receiver-secret", Secret: "secret"}
+	repo.CreateSubscription(ctx, subscription)
+	if err := NewWebhookSink(repo).Deliver(ctx, newEvent(models.EventUserLocked)); err != nil {
+		t.Fatalf("Failed to queue webhook: %v", err)
+	}
+
+	var out bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&out), zapcore.DebugLevel)
+	deliverer := NewWebhookDeliverer(repo, 1, time.Second, time.Minute, time.Second, logger.FromZap(zap.New(core)))
+	if _, err := deliverer.DeliverDue(ctx, time.Now()); err != nil {
+		t.Fatalf("DeliverDue failed: %v", err)
+	}
+
+	dead, _ := repo.ListDeliveriesByStatus(ctx, models.WebhookDeliveryDead)
+	if len(dead) != 1 {
+		t.Fatalf("Expected delivery in dead letters, got %+v", dead)
+	}
+	if strings.Contains(out.String(), "receiver-secret") || strings.Contains(dead[0].LastError, "receiver-secret") {
+		t.Errorf("Subscription URL leaked: log %s, last error %q", out.String(), dead[0].LastError)
+	}
+	if !strings.Contains(out.String(), subscription.ID.String()) {
+		t.Errorf("Expected subscription ID in the log, got %s", out.String())
+	}
+}
@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"context"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// AuditSink - дополнительный приемник записей аудита (stdout для сборщика логов, файл на другом томе)
+type AuditSink interface {
+	Write(ctx context.Context, record *models.AuditRecord) error
+}
+
+// AuditRepository - основное хранилище журнала аудита, только добавление
+type AuditRepository interface {
+	Append(ctx context.Context, record *models.AuditRecord) error
+	// Last возвращает последнюю запись или nil, если журнал пуст
+	Last(ctx context.Context) (*models.AuditRecord, error)
+	// Query возвращает записи по возрастанию Seq
+	Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditRecord, error)
+}
+
+// AuditLogger - запись действий в журнал аудита и его просмотр
+type AuditLogger interface {
+	// Record дополняет запись сведениями о клиенте из контекста и звеньями цепочки.
+	// Ошибки записи не прерывают действие и только логируются.
+	Record(ctx context.Context, record *models.AuditRecord)
+	Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditRecord, error)
+	// Verify проверяет цепочку хешей всего журнала и возвращает число проверенных записей
+	Verify(ctx context.Context) (int, error)
+}
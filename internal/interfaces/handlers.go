@@ -7,8 +7,39 @@ import (
 type AuthHandler interface {
 	Register(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
+	LoginMFA(w http.ResponseWriter, r *http.Request)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	ForgotPassword(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
 	GetProfile(w http.ResponseWriter, r *http.Request)
 	Logout(w http.ResponseWriter, r *http.Request)
+	LogoutAll(w http.ResponseWriter, r *http.Request)
+	ListSessions(w http.ResponseWriter, r *http.Request)
+	RevokeSession(w http.ResponseWriter, r *http.Request)
+	EnrollTOTP(w http.ResponseWriter, r *http.Request)
+	ConfirmTOTP(w http.ResponseWriter, r *http.Request)
+	DisableTOTP(w http.ResponseWriter, r *http.Request)
+	RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request)
+	BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request)
+	FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request)
+	BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request)
+	FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request)
+	ListWebAuthnCredentials(w http.ResponseWriter, r *http.Request)
+	DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request)
 	UpdateProfile(w http.ResponseWriter, r *http.Request)
 	VerifyEmail(w http.ResponseWriter, r *http.Request)
+	ResendVerification(w http.ResponseWriter, r *http.Request)
+	ChangeEmail(w http.ResponseWriter, r *http.Request)
+	ConfirmEmailChange(w http.ResponseWriter, r *http.Request)
+	RevertEmailChange(w http.ResponseWriter, r *http.Request)
+	JWKS(w http.ResponseWriter, r *http.Request)
+	RotateSigningKey(w http.ResponseWriter, r *http.Request)
+	AdminUpdateUser(w http.ResponseWriter, r *http.Request)
+	ListAuditRecords(w http.ResponseWriter, r *http.Request)
+	VerifyAuditLog(w http.ResponseWriter, r *http.Request)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	ListWebhooks(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	ListWebhookDeadLetters(w http.ResponseWriter, r *http.Request)
+	ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request)
 } 
\ No newline at end of file
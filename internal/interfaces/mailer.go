@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// Mailer - доставка писем пользователям
+type Mailer interface {
+	Send(ctx context.Context, message *models.EmailMessage) error
+}
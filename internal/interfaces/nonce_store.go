@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+// NonceStore - использованные одноразовые токены (например, верификации email).
+// Запись нужна только до истечения срока действия токена.
+type NonceStore interface {
+	// Consume помечает nonce использованным. false - nonce уже был использован.
+	Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
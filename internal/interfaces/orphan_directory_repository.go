@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// OrphanDirectoryRepository - директории, которые не удалось удалить при откате регистрации
+type OrphanDirectoryRepository interface {
+	// Save создает или обновляет запись по UserID
+	Save(ctx context.Context, directory *models.OrphanDirectory) error
+	// ListDue возвращает записи, у которых подошло время следующей попытки
+	ListDue(ctx context.Context, now time.Time) ([]*models.OrphanDirectory, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
@@ -0,0 +1,47 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// OutboxRepository - события, ожидающие доставки. Запись удаляется только после
+// доставки во все приемники (at-least-once).
+type OutboxRepository interface {
+	Append(ctx context.Context, event *models.UserEvent) error
+	// ListDue возвращает до limit записей, у которых подошло время попытки, в порядке возникновения
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*models.OutboxRecord, error)
+	Update(ctx context.Context, record *models.OutboxRecord) error
+	Delete(ctx context.Context, eventID uuid.UUID) error
+}
+
+// EventSink - приемник событий (gRPC, HTTP, файл). Deliver должен быть идемпотентным
+// по ID события: при сбоях событие доставляется повторно.
+type EventSink interface {
+	Name() string
+	Deliver(ctx context.Context, event *models.UserEvent) error
+}
+
+// EventFeedRepository - лента изменений пользователей для WatchUserEvents.
+// Хранит события за период retention; курсор клиента - номер последнего полученного события.
+type EventFeedRepository interface {
+	// Append добавляет событие в конец ленты. Повторное событие с тем же ID не дублируется:
+	// возвращается уже записанная запись.
+	Append(ctx context.Context, kind string, event *models.UserEvent) (*models.FeedEntry, error)
+	// ListAfter возвращает до limit записей с Seq > seq по возрастанию
+	ListAfter(ctx context.Context, seq uint64, limit int) ([]*models.FeedEntry, error)
+	State(ctx context.Context) (models.FeedState, error)
+	// DeleteExpired удаляет записи старше retention
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// EventWatcher - подписка на ленту изменений с продолжением с курсора
+type EventWatcher interface {
+	// Watch отправляет записи после cursor, затем новые по мере появления, пока send
+	// не вернет ошибку или не отменится ctx. Пустой cursor - только новые записи.
+	// Устаревший курсор - errdefs.ErrCursorExpired: клиент перечитывает данные целиком.
+	Watch(ctx context.Context, cursor string, send func(cursor string, entry *models.FeedEntry) error) error
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimiter ограничивает частоту действий по ключу (пользователь, email, IP)
+type RateLimiter interface {
+	// Allow учитывает попытку. Если лимит исчерпан, попытка не учитывается
+	// и возвращается время до следующей разрешенной попытки.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkUsed атомарно помечает токен использованным; false - токен уже был использован ранее
+	MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	// RevokeAllForUser отзывает семейства пользователя, начатые раньше issuedBefore
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, issuedBefore time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
@@ -1,7 +1,10 @@
 package interfaces
 
 import (
+	"time"
+
 	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
 	"homecloud-auth-service/internal/security"
 )
 
@@ -11,12 +14,69 @@ type Security interface {
 	ComparePassword(hashedPassword, password string) error
 	
 	// Работа с токенами
-	GenerateToken(userID uuid.UUID) (string, error)
+	GenerateToken(userID uuid.UUID, sessionID uuid.UUID) (string, error)
 	ValidateToken(tokenString string) (*security.TokenClaims, error)
-	RefreshToken(tokenString string) (string, error)
-	InvalidateToken(tokenString string) error
+	AccessTokenExpiration() time.Duration
+	
+	// Refresh токены
+	GenerateRefreshToken() (string, error)
+	HashRefreshToken(token string) string
+	RefreshTokenExpiration() time.Duration
 	
 	// Генерация токенов верификации
-	GenerateVerificationToken(userID uuid.UUID) (string, error)
-	ValidateVerificationToken(tokenString string) (uuid.UUID, error)
-} 
\ No newline at end of file
+	GenerateVerificationToken(userID uuid.UUID, email string) (string, error)
+	ValidateVerificationToken(tokenString string) (*security.VerificationClaims, error)
+	
+	// Токены смены email
+	GenerateEmailChangeToken(userID uuid.UUID, oldEmail, newEmail string) (string, error)
+	GenerateEmailRevertToken(userID uuid.UUID, oldEmail, newEmail string) (string, error)
+	ValidateEmailChangeToken(tokenString string) (*security.EmailChangeClaims, error)
+	ValidateEmailRevertToken(tokenString string) (*security.EmailChangeClaims, error)
+	
+	// Токены сброса пароля
+	GeneratePasswordResetToken(userID uuid.UUID, passwordHash string) (string, error)
+	ValidatePasswordResetToken(tokenString string) (*security.PasswordResetClaims, error)
+	PasswordFingerprint(passwordHash string) string
+}
+
+// KeyManager - набор асимметричных ключей подписи access токенов
+type KeyManager interface {
+	JWKS() security.JWKSet
+	Rotate() (*security.SigningKey, error)
+}
+
+// TwoFactor - проверка второго фактора и MFA challenge токены
+type TwoFactor interface {
+	// TOTP
+	GenerateTOTPSecret() (string, error)
+	TOTPProvisioningURI(account, secret string) string
+	ValidateTOTPCode(secret, code string, now time.Time) (int64, bool)
+
+	// Резервные коды
+	GenerateRecoveryCodes(n int) ([]string, error)
+	HashRecoveryCode(code string) string
+
+	// Токен между проверкой пароля и второго фактора
+	GenerateChallengeToken(userID uuid.UUID) (string, error)
+	ValidateChallengeToken(tokenString string) (*security.ChallengeClaims, error)
+
+	// Шифрование секретов перед сохранением
+	EncryptSecret(plain string) (string, error)
+	DecryptSecret(encrypted string) (string, error)
+}
+
+// WebAuthn - проверка церемоний регистрации и входа по passkey
+type WebAuthn interface {
+	RPID() string
+	RPName() string
+	NewChallenge() (string, error)
+	VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*security.AttestedCredential, error)
+	VerifyAssertion(challenge string, publicKey []byte, algorithm int64, storedSignCount uint32, clientDataJSON, authenticatorData, signature []byte) (signCount uint32, userVerified bool, err error)
+}
+
+// PasswordPolicy - требования к паролям пользователей
+type PasswordPolicy interface {
+	// Check возвращает *errdefs.ValidationError по полю field; identity - username и email владельца
+	Check(field, password string, identity ...string) error
+	Rules() models.PasswordPolicy
+}
@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	// Touch обновляет время последней активности и срок действия сессии
+	Touch(ctx context.Context, id uuid.UUID, lastSeenAt time.Time, expiresAt time.Time) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+	// RevokeAllForUser отзывает сессии пользователя, начатые раньше createdBefore
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, createdBefore time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
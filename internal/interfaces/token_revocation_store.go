@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenRevocationStore - список отозванных access токенов.
+// Записи нужны только до истечения срока действия токена, после чего удаляются.
+type TokenRevocationStore interface {
+	// RevokeToken отзывает один токен по его token_id
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	// RevokeUserTokens отзывает все токены пользователя, выданные раньше issuedBefore.
+	// issuedBefore сравнивается с iat с точностью до миллисекунд.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, issuedBefore time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+	EvictExpired(ctx context.Context, now time.Time) (int, error)
+}
@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+type TwoFactorRepository interface {
+	// SaveTOTP сохраняет секрет, заменяя предыдущий секрет пользователя
+	SaveTOTP(ctx context.Context, secret *models.TOTPSecret) error
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPSecret, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, confirmedAt time.Time) error
+	// UseTOTPStep атомарно запоминает шаг использованного кода.
+	// Возвращает false, если код этого или более позднего шага уже принимался.
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+
+	// ReplaceRecoveryCodes заменяет набор резервных кодов пользователя (хеши)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error
+	// UseRecoveryCode удаляет код из набора. Возвращает false, если такого кода нет.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
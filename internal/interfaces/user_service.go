@@ -2,16 +2,47 @@ package interfaces
 
 import (
 	"context"
+	"time"
+
 	"github.com/google/uuid"
 	"homecloud-auth-service/internal/models"
 )
 
 type UserService interface {
 	// Аутентификация
-	Register(ctx context.Context, email, username, password string) (*models.User, string, error)
-	Login(ctx context.Context, email, password string) (*models.User, string, error)
+	Register(ctx context.Context, email, username, password string) (*models.User, error)
+	// Login возвращает *errdefs.MFARequiredError, если у пользователя включена 2FA
+	Login(ctx context.Context, email, password string) (*models.User, *models.TokenPair, error)
+	LoginMFA(ctx context.Context, challengeToken, method, code string) (*models.User, *models.TokenPair, error)
 	ValidateToken(ctx context.Context, token string) (*models.User, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*models.TokenPair, error)
 	Logout(ctx context.Context, token string) error
+	LogoutAll(ctx context.Context, userID uuid.UUID, before time.Time) error
+	
+	// Сброс забытого пароля
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	// Требования к паролю для подсказок клиенту
+	PasswordPolicy() models.PasswordPolicy
+	
+	// Сессии
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	
+	// Двухфакторная аутентификация
+	EnrollTOTP(ctx context.Context, userID uuid.UUID) (secret string, otpauthURI string, err error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) error
+	DisableTOTP(ctx context.Context, userID uuid.UUID, password string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, password string) ([]string, error)
+	RecoveryCodesRemaining(ctx context.Context, userID uuid.UUID) (int, error)
+	
+	// Passkey (WebAuthn)
+	BeginWebAuthnRegistration(ctx context.Context, userID uuid.UUID) (*models.WebAuthnRegisterBeginResponse, error)
+	FinishWebAuthnRegistration(ctx context.Context, userID, challengeID uuid.UUID, name, password string, credential *models.AttestationCredential) (*models.WebAuthnCredential, error)
+	BeginWebAuthnLogin(ctx context.Context, email, mfaToken string) (*models.WebAuthnLoginBeginResponse, error)
+	FinishWebAuthnLogin(ctx context.Context, challengeID uuid.UUID, assertion *models.AssertionCredential) (*models.User, *models.TokenPair, error)
+	ListWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error)
+	DeleteWebAuthnCredential(ctx context.Context, userID uuid.UUID, credentialID, password string) error
 	
 	// Профиль пользователя
 	GetUserProfile(ctx context.Context, userID uuid.UUID) (*models.User, error)
@@ -20,10 +51,19 @@ type UserService interface {
 	// Верификация email
 	VerifyEmail(ctx context.Context, token string) error
 	SendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	// ResendVerificationEmail возвращает *errdefs.RateLimitError при превышении лимита
+	ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	
+	// Смена email: подтверждение с нового адреса, отмена со старого
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, password, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	RevertEmailChange(ctx context.Context, token string) error
 	
 	// Управление аккаунтом
 	UpdateStorageUsage(ctx context.Context, userID uuid.UUID, usedSpace int64) error
 	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	// Администрирование: блокировка аккаунта завершает все его сессии; nil - поле не меняется
+	AdminUpdateUser(ctx context.Context, userID uuid.UUID, isActive *bool, storageQuota *int64) error
 }
 
 type AuthService interface {
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+type WebAuthnRepository interface {
+	// Ключи пользователей
+	CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error
+	GetCredential(ctx context.Context, id string) (*models.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error)
+	// UpdateCredentialUsage сохраняет новый счетчик подписей после успешного входа
+	UpdateCredentialUsage(ctx context.Context, id string, signCount uint32, usedAt time.Time) error
+	DeleteCredential(ctx context.Context, id string) error
+
+	// Незавершенные церемонии
+	SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error
+	// ConsumeChallenge возвращает и удаляет challenge, повторно его получить нельзя
+	ConsumeChallenge(ctx context.Context, id uuid.UUID) (*models.WebAuthnChallenge, error)
+	DeleteExpiredChallenges(ctx context.Context, before time.Time) (int, error)
+}
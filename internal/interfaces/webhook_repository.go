@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// WebhookRepository - подписки на вебхуки и очередь их доставки
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	// DeleteSubscription удаляет подписку вместе с ее доставками
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// CreateDelivery возвращает ErrConflict, если доставка с таким ID уже есть
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	// ListDueDeliveries - ожидающие доставки, у которых подошло время попытки
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	ListDeliveriesByStatus(ctx context.Context, status string) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	DeleteDelivery(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// WebhookService - управление подписками на вебхуки (только для администраторов)
+type WebhookService interface {
+	// CreateSubscription генерирует секрет, если он не задан
+	CreateSubscription(ctx context.Context, req *models.CreateWebhookRequest) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// Доставки, исчерпавшие попытки, и их повторная отправка
+	ListDeadLetters(ctx context.Context) ([]*models.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, id uuid.UUID) error
+}
@@ -25,7 +25,8 @@ func New(cfg *config.Config) (*Logger, error) {
 	cfg.Logger.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	cfg.Logger.EncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
 
-	logger, err := cfg.Logger.Build()
+	// Все записи проходят через слой редактирования: пароли, хеши и токены не попадают в вывод
+	logger, err := cfg.Logger.Build(zap.WrapCore(NewRedactingCore))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create logger: %w", err)
 	}
@@ -33,13 +34,27 @@ func New(cfg *config.Config) (*Logger, error) {
 	return &Logger{l: logger}, nil
 }
 
+// FromZap оборачивает готовый zap.Logger, добавляя редактирование полей
+func FromZap(l *zap.Logger) *Logger {
+	return &Logger{l: l.WithOptions(zap.WrapCore(NewRedactingCore))}
+}
+
+// Nop возвращает логгер, который ничего не пишет
+func Nop() *Logger {
+	return &Logger{l: zap.NewNop()}
+}
+
 func CtxWWithLogger(ctx context.Context, lg *Logger) context.Context {
 	ctx = context.WithValue(ctx, LoggerKey, lg)
 	return ctx
 }
 
+// GetLoggerFromCtx возвращает логгер из контекста; без него - Nop, чтобы вызовы вне запроса не падали
 func GetLoggerFromCtx(ctx context.Context) *Logger {
-	return ctx.Value(LoggerKey).(*Logger)
+	if lg, ok := ctx.Value(LoggerKey).(*Logger); ok && lg != nil {
+		return lg
+	}
+	return Nop()
 }
 
 func (l *Logger) Info(ctx context.Context, msg string, fields ...zap.Field) {
@@ -58,6 +73,14 @@ func (l *Logger) Debug(ctx context.Context, msg string, fields ...zap.Field) {
 	l.l.Debug(msg, fields...)
 }
 
+func (l *Logger) Warn(ctx context.Context, msg string, fields ...zap.Field) {
+	if ctx.Value(RequestID) != nil {
+		fields = append(fields, zap.String(RequestID, ctx.Value(RequestID).(string)))
+	}	
+
+	l.l.Warn(msg, fields...)
+}
+
 func (l *Logger) Error(ctx context.Context, msg string, fields ...zap.Field) {
 	if ctx.Value(RequestID) != nil {
 		fields = append(fields, zap.String(RequestID, ctx.Value(RequestID).(string)))
@@ -0,0 +1,232 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted подставляется вместо скрытых значений
+const Redacted = "[REDACTED]"
+
+// Фрагменты имен полей, значения которых никогда не попадают в лог.
+// Имя сравнивается без регистра, "_" и "-": PasswordHash, password_hash и refresh-token совпадают
+var sensitiveKeyParts = []string{
+	"password",
+	"passwd",
+	"hash",
+	"token",
+	"secret",
+	"authorization",
+	"cookie",
+	"otp",
+	"recoverycode",
+	"privatekey",
+	"apikey",
+	"signature",
+}
+
+// Secret - строка, которая выводится в лог только как [REDACTED]
+type Secret string
+
+func (Secret) String() string { return Redacted }
+
+func (Secret) GoString() string { return Redacted }
+
+func (Secret) MarshalJSON() ([]byte, error) { return json.Marshal(Redacted) }
+
+func (Secret) MarshalText() ([]byte, error) { return []byte(Redacted), nil }
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// IsSensitiveKey сообщает, скрывается ли поле с таким именем
+func IsSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(key))
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactingCore скрывает чувствительные поля до того, как их увидит энкодер
+type redactingCore struct {
+	zapcore.Core
+}
+
+// NewRedactingCore оборачивает core: поля с чувствительными именами и значения типа Secret
+// заменяются на [REDACTED], вложенные структуры и map проходят ту же проверку по полям
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	if _, ok := core.(*redactingCore); ok {
+		return core
+	}
+	return &redactingCore{Core: core}
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(RedactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, RedactFields(fields))
+}
+
+// RedactFields возвращает копию полей со скрытыми значениями
+func RedactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = redactField(field)
+	}
+	return redacted
+}
+
+func redactField(field zapcore.Field) zapcore.Field {
+	if IsSensitiveKey(field.Key) {
+		return zap.String(field.Key, Redacted)
+	}
+	switch field.Type {
+	case zapcore.StringerType, zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		if field.Interface == nil {
+			return field
+		}
+		if _, ok := field.Interface.(Secret); ok {
+			return zap.String(field.Key, Redacted)
+		}
+		if field.Type == zapcore.StringerType {
+			return field
+		}
+		return zap.Any(field.Key, Redact(field.Interface))
+	}
+	return field
+}
+
+// Redact превращает значение в структуру из map и срезов, в которой чувствительные
+// поля скрыты. Имена полей структур берутся из тегов json, как при обычной сериализации
+func Redact(value any) any {
+	return redactValue(reflect.ValueOf(value), 0)
+}
+
+// Ограничение глубины защищает от циклических ссылок
+const maxRedactDepth = 16
+
+func redactValue(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxRedactDepth {
+		return "..."
+	}
+	if v.Type() == secretType {
+		return Redacted
+	}
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case error:
+			return value.Error()
+		case fmt.Stringer:
+			if v.Kind() != reflect.Struct && v.Kind() != reflect.Pointer {
+				return value.String()
+			}
+		case json.Marshaler, zapcore.ObjectMarshaler:
+			if v.Kind() != reflect.Struct && v.Kind() != reflect.Pointer {
+				return value
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), depth+1)
+	case reflect.Struct:
+		if isOpaqueStruct(v) {
+			return v.Interface()
+		}
+		out := make(map[string]any, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, skip := jsonFieldName(field)
+			if skip {
+				continue
+			}
+			if IsSensitiveKey(field.Name) || IsSensitiveKey(name) {
+				out[name] = Redacted
+				continue
+			}
+			out[name] = redactValue(v.Field(i), depth+1)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if IsSensitiveKey(key) {
+				out[key] = Redacted
+				continue
+			}
+			out[key] = redactValue(iter.Value(), depth+1)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Произвольные байты могут быть ключом или токеном
+			return Redacted
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redactValue(v.Index(i), depth+1)
+		}
+		return out
+	}
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return nil
+}
+
+// Структуры без экспортируемых полей (time.Time и т.п.) сериализуются сами
+func isOpaqueStruct(v reflect.Value) bool {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return v.CanInterface()
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, false
+	}
+	return field.Name, false
+}
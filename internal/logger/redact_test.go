@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	plainPassword = "correct horse battery staple"
+	passwordHash  = "$2a$12$abcdefghijklmnopqrstuv"
+	refreshToken  = "rt.eyJhbGciOiJSUzI1NiJ9.payload"
+)
+
+// Логгер с JSON выводом в буфер, как в продакшене
+func newBufferLogger(buf *bytes.Buffer) *Logger {
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.DebugLevel)
+	return FromZap(zap.New(core))
+}
+
+func assertNoSecrets(t *testing.T, output string) {
+	t.Helper()
+	for _, secret := range []string{plainPassword, passwordHash, refreshToken} {
+		if strings.Contains(output, secret) {
+			t.Fatalf("log output leaks %q:\n%s", secret, output)
+		}
+	}
+}
+
+func TestSensitiveFieldNamesAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	lg := newBufferLogger(&buf)
+	ctx := context.Background()
+
+	lg.Debug(ctx, "login",
+		zap.String("email", "user@example.com"),
+		zap.String("password", plainPassword),
+		zap.String("PasswordHash", passwordHash),
+		zap.String("refresh-token", refreshToken),
+	)
+	lg.Info(ctx, "headers", zap.Any("headers", map[string]string{
+		"Authorization": "Bearer " + refreshToken,
+		"User-Agent":    "HomeCloud-iOS",
+	}))
+
+	output := buf.String()
+	assertNoSecrets(t, output)
+	if !strings.Contains(output, "user@example.com") || !strings.Contains(output, "HomeCloud-iOS") {
+		t.Fatalf("non-sensitive fields must be kept:\n%s", output)
+	}
+	if strings.Count(output, Redacted) != 4 {
+		t.Fatalf("expected 4 redacted values:\n%s", output)
+	}
+}
+
+func TestSecretTypeIsRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	lg := newBufferLogger(&buf)
+	ctx := context.Background()
+
+	lg.Info(ctx, "secret values",
+		zap.Any("value", Secret(plainPassword)),
+		zap.Stringer("stringer", Secret(refreshToken)),
+	)
+
+	assertNoSecrets(t, buf.String())
+}
+
+func TestNestedStructsAreRedacted(t *testing.T) {
+	type credentials struct {
+		Login    string `json:"login"`
+		Password string `json:"pwd"`
+		Key      Secret `json:"key"`
+	}
+	type request struct {
+		Credentials *credentials      `json:"credentials"`
+		Tokens      []string          `json:"tokens"`
+		Extra       map[string]Secret `json:"extra"`
+		Raw         []byte            `json:"raw"`
+	}
+
+	var buf bytes.Buffer
+	lg := newBufferLogger(&buf)
+	lg.Info(context.Background(), "request", zap.Any("request", request{
+		Credentials: &credentials{Login: "user", Password: plainPassword, Key: Secret(passwordHash)},
+		Tokens:      []string{refreshToken},
+		Extra:       map[string]Secret{"note": Secret(refreshToken)},
+		Raw:         []byte(plainPassword),
+	}))
+
+	output := buf.String()
+	assertNoSecrets(t, output)
+	if !strings.Contains(output, `"login":"user"`) {
+		t.Fatalf("non-sensitive nested fields must be kept:\n%s", output)
+	}
+}
+
+func TestWithFieldsAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	lg := newBufferLogger(&buf)
+	child := &Logger{l: lg.l.With(zap.String("access_token", refreshToken))}
+
+	child.Error(context.Background(), "failed", zap.Error(context.Canceled))
+
+	assertNoSecrets(t, buf.String())
+}
+
+func TestLoggerFromContextFallsBackToNop(t *testing.T) {
+	lg := GetLoggerFromCtx(context.Background())
+	if lg == nil {
+		t.Fatal("expected nop logger")
+	}
+	lg.Info(context.Background(), "dropped", zap.String("password", plainPassword))
+}
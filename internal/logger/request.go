@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader - заголовок HTTP (и ключ метаданных gRPC в нижнем регистре) с ID запроса
+const RequestIDHeader = "X-Request-ID"
+
+// Максимальная длина ID запроса, принятого от клиента
+const maxRequestIDLength = 128
+
+type accessInfoKey struct{}
+
+// AccessInfo - данные для строки access-лога, которые становятся известны только внутри обработчика
+type AccessInfo struct {
+	mu     sync.Mutex
+	userID string
+}
+
+// NewRequestID генерирует ID запроса
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// RequestIDOrNew возвращает ID запроса от клиента, если он допустим, иначе новый.
+// Допустимы до 128 символов из букв, цифр и "-_.:", чтобы ID нельзя было использовать для инъекций в логи
+func RequestIDOrNew(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		return NewRequestID()
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return NewRequestID()
+		}
+	}
+	return id
+}
+
+// WithRequestID сохраняет ID запроса в контексте; методы Logger добавляют его к каждой записи
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, RequestID, id)
+}
+
+// RequestIDFromCtx возвращает ID запроса или пустую строку
+func RequestIDFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(RequestID).(string)
+	return id
+}
+
+// WithAccessInfo добавляет в контекст запроса изменяемые данные для access-лога
+func WithAccessInfo(ctx context.Context) (context.Context, *AccessInfo) {
+	info := &AccessInfo{}
+	return context.WithValue(ctx, accessInfoKey{}, info), info
+}
+
+// SetAccessUserID запоминает пользователя запроса для access-лога
+func SetAccessUserID(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(accessInfoKey{}).(*AccessInfo); ok {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+}
+
+// UserID возвращает пользователя запроса, если он был определен
+func (a *AccessInfo) UserID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.userID
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDOrNew(t *testing.T) {
+	if got := RequestIDOrNew("req-42.a:b_c"); got != "req-42.a:b_c" {
+		t.Fatalf("valid client id must be kept, got %q", got)
+	}
+	for _, id := range []string{"", "bad id", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		got := RequestIDOrNew(id)
+		if got == id || got == "" {
+			t.Fatalf("id %q must be replaced, got %q", id, got)
+		}
+	}
+}
+
+func TestRequestIDIsAddedToRecords(t *testing.T) {
+	var buf bytes.Buffer
+	lg := newBufferLogger(&buf)
+	ctx, access := WithAccessInfo(WithRequestID(context.Background(), "req-7"))
+	SetAccessUserID(ctx, "user-1")
+
+	lg.Info(ctx, "request")
+
+	if !strings.Contains(buf.String(), `"RequestID":"req-7"`) {
+		t.Fatalf("request id missing:\n%s", buf.String())
+	}
+	if access.UserID() != "user-1" {
+		t.Fatalf("unexpected access user id %q", access.UserID())
+	}
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// FileMailer складывает письма в каталог outbox в виде .eml файлов.
+// Для разработки: письма можно открыть любым почтовым клиентом.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer создает FileMailer и каталог dir
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, message *models.EmailMessage) error {
+	now := time.Now()
+	data, err := buildMessage(m.from, message, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString()[:8])
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return nil
+}
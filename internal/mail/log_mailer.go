@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+)
+
+// LogMailer не отправляет письма, а пишет в лог адресата, тему и шаблон. Для разработки и тестов.
+// Тело письма не логируется: в нем ссылки с действующими токенами.
+type LogMailer struct{}
+
+// NewLogMailer создает новый экземпляр LogMailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message *models.EmailMessage) error {
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "mail not sent: log mailer",
+		zap.String("to", message.To),
+		zap.String("subject", message.Subject),
+		zap.String("template", message.Template),
+	)
+	return nil
+}
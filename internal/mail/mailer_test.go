@@ -0,0 +1,263 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+)
+
+// smtpStandIn - минимальный SMTP сервер для тестов: принимает одно письмо
+type smtpStandIn struct {
+	listener net.Listener
+	done     chan struct{}
+
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := &smtpStandIn{listener: listener, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP stand-in")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH PLAIN"):
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+			s.auth = string(decoded)
+			reply("235 Authentication successful")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = line[len("MAIL FROM:"):]
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = append(s.to, line[len("RCPT TO:"):])
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data = data.String()
+			reply("250 OK: queued")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	message, err := Render(TemplatePasswordReset, "user@example.com", TemplateData{
+		Username: "<alice>",
+		Link:     "https://cloud.example.com/reset?token=abc&x=1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if message.Subject != "Сброс пароля HomeCloud" {
+		t.Errorf("Unexpected subject %q", message.Subject)
+	}
+	if !strings.Contains(message.Text, "<alice>") || !strings.Contains(message.Text, "token=abc&x=1") {
+		t.Errorf("Text part is missing data: %q", message.Text)
+	}
+	// В HTML данные экранируются
+	if !strings.Contains(message.HTML, "&lt;alice&gt;") || strings.Contains(message.HTML, "<alice>") {
+		t.Errorf("HTML part is not escaped: %q", message.HTML)
+	}
+
+	alert, err := Render(TemplateSecurityAlert, "user@example.com", TemplateData{
+		Event: "Пароль аккаунта был изменен.",
+		Time:  time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		IP:    "203.0.113.7",
+	})
+	if err != nil {
+		t.Fatalf("Failed to render alert: %v", err)
+	}
+	if !strings.Contains(alert.Text, "01.05.2024 10:30") || !strings.Contains(alert.Text, "203.0.113.7") {
+		t.Errorf("Alert is missing details: %q", alert.Text)
+	}
+
+	if _, err := Render("unknown", "user@example.com", TemplateData{}); err == nil {
+		t.Error("Expected unknown template to fail")
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newSMTPStandIn(t)
+	mailer := NewSMTPMailer("127.0.0.1", server.port(), "mailer", "secret", "HomeCloud <noreply@example.com>", SMTPSecurityNone, time.Second)
+
+	message, err := Render(TemplateVerification, "user@example.com", TemplateData{Username: "alice", Link: "https://cloud.example.com/verify?token=abc"})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if err := mailer.Send(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	<-server.done
+
+	if server.auth != "\x00mailer\x00secret" {
+		t.Errorf("Unexpected auth %q", server.auth)
+	}
+	if server.from != "<noreply@example.com>" || len(server.to) != 1 || server.to[0] != "<user@example.com>" {
+		t.Errorf("Unexpected envelope from=%s to=%v", server.from, server.to)
+	}
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("Failed to parse delivered message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != message.Subject {
+		t.Errorf("Expected subject %q, got %q", message.Subject, subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %q (%v)", mediaType, err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		if !strings.Contains(string(body), "https://cloud.example.com/verify?token=abc") {
+			t.Errorf("Part %s is missing the link", part.Header.Get("Content-Type"))
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Errorf("Unexpected parts %v", types)
+	}
+}
+
+func TestSMTPMailerConnectionRefused(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	mailer := NewSMTPMailer("127.0.0.1", port, "", "", "noreply@example.com", SMTPSecurityNone, time.Second)
+	err := mailer.Send(context.Background(), &models.EmailMessage{To: "user@example.com", Subject: "Test", Text: "test"})
+	if err == nil {
+		t.Error("Expected send to a closed port to fail")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer, err := NewFileMailer(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create file mailer: %v", err)
+	}
+
+	err = mailer.Send(context.Background(), &models.EmailMessage{To: "user@example.com", Subject: "Тест", Text: "Привет"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one message in outbox, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("Failed to parse outbox message: %v", err)
+	}
+	if parsed.Header.Get("To") != "<user@example.com>" {
+		t.Errorf("Unexpected To header %q", parsed.Header.Get("To"))
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if string(body) != "Привет" {
+		t.Errorf("Unexpected body %q", body)
+	}
+}
+
+func TestLogMailerOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	ctx := logger.CtxWWithLogger(context.Background(), logger.FromZap(zap.New(core)))
+
+	message, err := Render(TemplatePasswordReset, "user@example.com", TemplateData{Link: "https://example.com/reset?token=secret-token"})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if err := NewLogMailer().Send(ctx, message); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "secret-token") {
+		t.Errorf("Log mailer leaked the link: %s", out)
+	}
+	if !strings.Contains(out, `"template":"password_reset"`) || !strings.Contains(out, `"to":"user@example.com"`) {
+		t.Errorf("Unexpected log line: %s", out)
+	}
+}
@@ -0,0 +1,128 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// Режимы шифрования соединения с SMTP сервером
+const (
+	SMTPSecurityStartTLS = "starttls" // STARTTLS, если сервер его предлагает (порт 587)
+	SMTPSecurityTLS      = "tls"      // TLS с момента подключения (порт 465)
+	SMTPSecurityNone     = "none"     // без шифрования, только для локальных серверов
+)
+
+// SMTPMailer отправляет письма через SMTP сервер
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	security string
+	timeout  time.Duration
+}
+
+// NewSMTPMailer создает SMTPMailer. Пустой username - без аутентификации.
+func NewSMTPMailer(host string, port int, username, password, from, security string, timeout time.Duration) *SMTPMailer {
+	if security == "" {
+		security = SMTPSecurityStartTLS
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+		security: security,
+		timeout:  timeout,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message *models.EmailMessage) error {
+	data, err := buildMessage(m.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	sender, _ := parseAddress(m.from)
+	recipient, _ := parseAddress(message.To)
+	if err := client.Mail(sender); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(recipient); err != nil {
+		return fmt.Errorf("smtp RCPT TO failed: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial подключается к серверу и включает шифрование согласно настройке security
+func (m *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	dialer := &net.Dialer{Timeout: m.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+
+	// Все обращения к серверу ограничены общим сроком
+	deadline := time.Now().Add(m.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	tlsConfig := &tls.Config{ServerName: m.host}
+	if m.security == SMTPSecurityTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake failed: %w", err)
+	}
+
+	if m.security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+			}
+		}
+	}
+	return client, nil
+}
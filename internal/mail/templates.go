@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// Шаблоны писем. Для каждого есть текстовая (<name>.txt, с блоком subject) и HTML версия.
+const (
+	TemplateVerification  = "verification"
+	TemplatePasswordReset = "password_reset"
+	TemplateSecurityAlert = "security_alert"
+	TemplateEmailChange   = "email_change"
+	TemplateEmailChanged  = "email_changed"
+)
+
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+// emailTemplate - разобранные текстовая и HTML версии одного письма.
+// Файлы разбираются по отдельности: блок subject в каждом свой.
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = mustParseTemplates(TemplateVerification, TemplatePasswordReset, TemplateSecurityAlert, TemplateEmailChange, TemplateEmailChanged)
+
+func mustParseTemplates(names ...string) map[string]emailTemplate {
+	parsed := make(map[string]emailTemplate, len(names))
+	for _, name := range names {
+		parsed[name] = emailTemplate{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/"+name+".txt")),
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/"+name+".html")),
+		}
+	}
+	return parsed
+}
+
+// TemplateData - данные для подстановки в шаблон письма
+type TemplateData struct {
+	Username string
+	Link     string // ссылка подтверждения, сброса или отмены
+	NewEmail string // для писем о смене email
+
+	// Для security_alert и email_changed
+	Event     string
+	Time      time.Time
+	IP        string
+	UserAgent string
+}
+
+// Render собирает письмо для адресата to из шаблона name
+func Render(name, to string, data TemplateData) (*models.EmailMessage, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %w", name, err)
+	}
+
+	return &models.EmailMessage{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		Template: name,
+		Text:     text.String(),
+		HTML:     html.String(),
+	}, nil
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "homecloud_auth"
+
+// Исходы входа для LoginsTotal
+const (
+	LoginSuccess         = "success"
+	LoginBadPassword     = "bad_password"
+	LoginLocked          = "locked"
+	LoginInactive        = "inactive"
+	LoginUnknownUser     = "unknown_user"
+	LoginBadSecondFactor = "bad_second_factor"
+	LoginBadPasskey      = "bad_passkey"
+)
+
+// Результаты для счетчиков с меткой result
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Этапы записи журнала аудита для AuditWriteFailuresTotal
+const (
+	AuditStageStore = "store"
+	AuditStageSink  = "sink"
+)
+
+// Registry - реестр метрик сервиса, отдается на /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	RegistrationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "User registrations by result.",
+	}, []string{"result"})
+
+	LoginsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by outcome.",
+	}, []string{"outcome"})
+
+	TokenValidationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_validations_total",
+		Help:      "Access token validations by result.",
+	}, []string{"result"})
+
+	LockoutsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lockouts_total",
+		Help:      "Accounts locked after repeated failed logins.",
+	})
+
+	VerificationAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_verification_attempts_total",
+		Help:      "Email verification attempts by result.",
+	}, []string{"result"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	GRPCRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Incoming gRPC call latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// Стоимость bcrypt 12 дает сотни миллисекунд, поэтому корзины сдвинуты вверх
+	BcryptDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Password hashing and comparison latency.",
+		Buckets:   []float64{0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 2},
+	}, []string{"operation"})
+
+	OutboundInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbound_requests_in_flight",
+		Help:      "Outbound gRPC calls awaiting a response.",
+	}, []string{"service", "rpc"})
+
+	OutboundDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "outbound_request_duration_seconds",
+		Help:      "Outbound gRPC call latency to the DB manager and file service.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "rpc", "code"})
+
+	AuditWriteFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_write_failures_total",
+		Help:      "Audit records that failed to reach the store or a sink.",
+	}, []string{"stage"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RegistrationsTotal,
+		LoginsTotal,
+		TokenValidationsTotal,
+		LockoutsTotal,
+		VerificationAttemptsTotal,
+		HTTPRequestDuration,
+		GRPCRequestDuration,
+		BcryptDuration,
+		OutboundInFlight,
+		OutboundDuration,
+		AuditWriteFailuresTotal,
+	)
+}
+
+// Handler отдает метрики в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Result переводит ошибку в значение метки result
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// ObserveBcrypt записывает время операции bcrypt, начатой в start
+func ObserveBcrypt(operation string, start time.Time) {
+	BcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ClientInterceptor измеряет исходящие вызовы к сервису service по RPC и коду ответа
+func ClientInterceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		inFlight := OutboundInFlight.WithLabelValues(service, method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		OutboundDuration.WithLabelValues(service, method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientInterceptorRecordsRPCAndCode(t *testing.T) {
+	interceptor := ClientInterceptor("db_manager")
+	method := "/db.DBService/GetUserByID"
+
+	err := interceptor(context.Background(), method, nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			if got := testutil.ToFloat64(OutboundInFlight.WithLabelValues("db_manager", method)); got != 1 {
+				t.Errorf("in-flight gauge during call = %v", got)
+			}
+			return status.Error(codes.NotFound, "missing")
+		})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("interceptor must return the call error, got %v", err)
+	}
+
+	if got := testutil.ToFloat64(OutboundInFlight.WithLabelValues("db_manager", method)); got != 0 {
+		t.Errorf("in-flight gauge after call = %v", got)
+	}
+	if got := testutil.CollectAndCount(OutboundDuration, namespace+"_outbound_request_duration_seconds"); got != 1 {
+		t.Errorf("expected one outbound series, got %d", got)
+	}
+	expected := `outbound_request_duration_seconds_count{code="NotFound",rpc="/db.DBService/GetUserByID",service="db_manager"} 1`
+	if body := scrape(t); !strings.Contains(body, expected) {
+		t.Errorf("metrics output missing %q", expected)
+	}
+}
+
+func TestHandlerExposesServiceMetrics(t *testing.T) {
+	LoginsTotal.WithLabelValues(LoginBadPassword).Inc()
+	LockoutsTotal.Inc()
+
+	body := scrape(t)
+	for _, name := range []string{
+		namespace + `_logins_total{outcome="bad_password"}`,
+		namespace + "_lockouts_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("metrics output missing %s", name)
+		}
+	}
+}
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
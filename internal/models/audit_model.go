@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Действия, попадающие в журнал аудита
+const (
+	AuditLogin             = "login"
+	AuditLockout           = "account.lockout"
+	AuditLogout            = "logout"
+	AuditLogoutAll         = "logout.all"
+	AuditSessionRevoke     = "session.revoke"
+	AuditPasswordChange    = "password.change"
+	AuditPasswordReset     = "password.reset"
+	AuditUsernameChange    = "username.change"
+	AuditEmailVerify       = "email.verify"
+	AuditEmailChange       = "email.change"
+	AuditEmailChangeRevert = "email.change_revert"
+	AuditAdminUserUpdate   = "admin.user.update"
+	AuditAdminKeyRotate    = "admin.key.rotate"
+	AuditAdminWebhook      = "admin.webhook"
+)
+
+// Результат действия
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditRecord - запись журнала аудита. Записи связаны в цепочку: Hash считается
+// от записи вместе с PrevHash, поэтому изменение или удаление записи обнаруживается
+// при проверке цепочки.
+type AuditRecord struct {
+	Seq          uint64            `json:"seq"`
+	ID           uuid.UUID         `json:"id"`
+	Timestamp    time.Time         `json:"timestamp"`
+	ActorID      uuid.UUID         `json:"actor_id"` // uuid.Nil - неаутентифицированный клиент
+	TargetUserID uuid.UUID         `json:"target_user_id"`
+	Action       string            `json:"action"`
+	Outcome      string            `json:"outcome"`
+	Reason       string            `json:"reason,omitempty"`
+	Details      map[string]string `json:"details,omitempty"`
+	IP           string            `json:"ip,omitempty"`
+	UserAgent    string            `json:"user_agent,omitempty"`
+	RequestID    string            `json:"request_id,omitempty"`
+	PrevHash     string            `json:"prev_hash"`
+	Hash         string            `json:"hash"`
+}
+
+// AuditFilter - выборка журнала. Пустые поля не ограничивают выборку.
+type AuditFilter struct {
+	UserID uuid.UUID // совпадает с ActorID или TargetUserID
+	From   time.Time // включительно
+	To     time.Time // не включительно
+	Limit  int
+}
+
+// Matches - подходит ли запись под фильтр (без учета Limit)
+func (f *AuditFilter) Matches(record *AuditRecord) bool {
+	if f.UserID != uuid.Nil && record.ActorID != f.UserID && record.TargetUserID != f.UserID {
+		return false
+	}
+	if !f.From.IsZero() && record.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !record.Timestamp.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+type AuditListResponse struct {
+	Records []*AuditRecord `json:"records"`
+}
+
+type AuditVerifyResponse struct {
+	Valid    bool   `json:"valid"`
+	Verified int    `json:"verified"` // число записей, прошедших проверку
+	Error    string `json:"error,omitempty"`
+}
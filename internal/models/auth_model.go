@@ -8,19 +8,61 @@ import (
 // Запросы
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Username string `json:"username" validate:"required,min=3,max=50"`
-	Password string `json:"password" validate:"required,min=6"`
+	Username string `json:"username" validate:"required,min=3,max=50,username"`
+	Password string `json:"password" validate:"required"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"device_name,omitempty" validate:"omitempty,max=100"`
+}
+
+type MFALoginRequest struct {
+	MFAToken   string `json:"mfa_token" validate:"required"`
+	Method     string `json:"method,omitempty" validate:"omitempty,oneof=totp recovery_code"` // по умолчанию totp
+	Code       string `json:"code" validate:"required"`
+	DeviceName string `json:"device_name,omitempty" validate:"omitempty,max=100"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type RegenerateRecoveryCodesRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutAllRequest struct {
+	Before *time.Time `json:"before,omitempty"` // по умолчанию - текущее время
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 }
 
 type UpdateProfileRequest struct {
-	Username    *string `json:"username,omitempty" validate:"omitempty,min=3,max=50"`
+	Username    *string `json:"username,omitempty" validate:"omitempty,min=3,max=50,username"`
 	OldPassword *string `json:"old_password,omitempty"`
-	NewPassword *string `json:"new_password,omitempty" validate:"omitempty,min=6"`
+	NewPassword *string `json:"new_password,omitempty"`
 }
 
 // Ответы
@@ -32,8 +74,21 @@ type RegisterResponse struct {
 }
 
 type LoginResponse struct {
-	Token string     `json:"token"`
-	User  *UserInfo  `json:"user"`
+	Token        string    `json:"token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"` // время жизни access токена в секундах
+	User         *UserInfo `json:"user,omitempty"`
+
+	// Заполняются вместо токенов, если у пользователя включена 2FA
+	MFARequired bool     `json:"mfa_required,omitempty"`
+	MFAToken    string   `json:"mfa_token,omitempty"`
+	MFAMethods  []string `json:"mfa_methods,omitempty"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
 
 type UserInfo struct {
@@ -44,14 +99,37 @@ type UserInfo struct {
 }
 
 type ProfileResponse struct {
-	ID              uuid.UUID  `json:"id"`
-	Email           string     `json:"email"`
-	Username        string     `json:"username"`
-	Role            string     `json:"role"`
-	IsActive        bool       `json:"is_active"`
-	IsEmailVerified bool       `json:"is_email_verified"`
-	StorageQuota    int64      `json:"storage_quota"`
-	UsedSpace       int64      `json:"used_space"`
+	ID                     uuid.UUID `json:"id"`
+	Email                  string    `json:"email"`
+	Username               string    `json:"username"`
+	Role                   string    `json:"role"`
+	IsActive               bool      `json:"is_active"`
+	IsEmailVerified        bool      `json:"is_email_verified"`
+	StorageQuota           int64     `json:"storage_quota"`
+	UsedSpace              int64     `json:"used_space"`
+	TwoFactorEnabled       bool      `json:"two_factor_enabled"`
+	RecoveryCodesRemaining int       `json:"recovery_codes_remaining"` // неиспользованные резервные коды 2FA
+}
+
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	Codes []string `json:"codes"`
+}
+
+type RotateKeyResponse struct {
+	KeyID     string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminUpdateUserRequest - изменение аккаунта администратором, пустые поля не меняются
+type AdminUpdateUserRequest struct {
+	IsActive     *bool  `json:"is_active,omitempty"`
+	StorageQuota *int64 `json:"storage_quota,omitempty"`
 }
 
 // JWT Claims
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type clientInfoKey struct{}
+
+// ClientInfo - сведения о клиенте, выполняющем запрос.
+// Заполняется транспортным слоем (HTTP/gRPC) и передается через контекст.
+type ClientInfo struct {
+	IP         string
+	UserAgent  string
+	DeviceName string
+	RequestID  string
+	ActorID    uuid.UUID // аутентифицированный пользователь, выполняющий запрос
+}
+
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrphanDirectory - домашняя директория, оставшаяся от неудачной регистрации.
+// Удаление повторяется в фоне, пока файловый сервис его не подтвердит.
+type OrphanDirectory struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// EmailMessage - письмо пользователю. HTML необязателен, Text есть всегда.
+// Template - имя шаблона, из которого собрано письмо; пусто для писем без шаблона.
+type EmailMessage struct {
+	To       string
+	Subject  string
+	Template string
+	Text     string
+	HTML     string
+}
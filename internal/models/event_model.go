@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий жизненного цикла пользователя
+const (
+	EventUserRegistered  = "user.registered"
+	EventEmailVerified   = "user.email_verified"
+	EventEmailChanged    = "user.email_changed"
+	EventUsernameChanged = "user.username_changed"
+	EventPasswordChanged = "user.password_changed"
+	EventUserLocked      = "user.locked"
+	EventLoginNewDevice  = "user.login_new_device"
+	EventUserDisabled    = "user.disabled"
+	EventUserEnabled     = "user.enabled"
+	EventQuotaChanged    = "user.quota_changed"
+)
+
+// Виды изменений в ленте WatchUserEvents - то, что важно для кэша профилей у других сервисов
+const (
+	UserChangeCreated      = "created"
+	UserChangeUpdated      = "updated"
+	UserChangeDisabled     = "disabled"
+	UserChangeQuotaChanged = "quota_changed"
+)
+
+// FeedEntry - событие в ленте изменений. Seq растет монотонно в пределах эпохи ленты.
+type FeedEntry struct {
+	Seq   uint64    `json:"seq"`
+	Kind  string    `json:"kind"`
+	Event UserEvent `json:"event"`
+}
+
+// FeedState - границы ленты. При пустой ленте FirstSeq = LastSeq + 1.
+type FeedState struct {
+	Epoch    string
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// UserEvent - событие для других сервисов HomeCloud. ID одинаков при повторной доставке,
+// по нему получатель отбрасывает дубликаты.
+type UserEvent struct {
+	ID         uuid.UUID         `json:"id"`
+	Type       string            `json:"type"`
+	UserID     uuid.UUID         `json:"user_id"`
+	Data       map[string]string `json:"data,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// OutboxRecord - событие в outbox и состояние его доставки
+type OutboxRecord struct {
+	Event         UserEvent `json:"event"`
+	Delivered     []string  `json:"delivered,omitempty"` // приемники, которые уже получили событие
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// IsDeliveredTo - получил ли приемник sink событие
+func (r *OutboxRecord) IsDeliveredTo(sink string) bool {
+	for _, name := range r.Delivered {
+		if name == sink {
+			return true
+		}
+	}
+	return false
+}
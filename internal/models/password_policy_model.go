@@ -0,0 +1,14 @@
+package models
+
+// PasswordPolicy - требования к паролю. Отдается клиентам, чтобы проверять пароль при вводе;
+// окончательная проверка, включая словарь, выполняется на сервере
+type PasswordPolicy struct {
+	MinLength           int      `json:"min_length"`
+	MaxLength           int      `json:"max_length"`
+	MinCharacterClasses int      `json:"min_character_classes,omitempty"` // строчные, заглавные, цифры, прочие символы
+	MinEntropyBits      float64  `json:"min_entropy_bits,omitempty"`      // оценка: длина * log2(алфавит использованных классов)
+	MaxRepeatedChars    int      `json:"max_repeated_chars,omitempty"`    // одинаковых символов подряд
+	ForbidUserInfo      bool     `json:"forbid_user_info"`                // без username и email владельца
+	ForbiddenSubstrings []string `json:"forbidden_substrings,omitempty"`
+	CommonPasswords     bool     `json:"common_passwords"` // проверка по словарю распространенных паролей
+}
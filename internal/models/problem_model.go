@@ -0,0 +1,20 @@
+package models
+
+// Problem - тело ответа с ошибкой по RFC 7807 (application/problem+json).
+// Code - стабильный машинный код, по нему клиент выбирает, что показать пользователю
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Code      string         `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    []ProblemField `json:"errors,omitempty"`
+}
+
+// ProblemField - нарушение в конкретном поле запроса
+type ProblemField struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
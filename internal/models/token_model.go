@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken - непрозрачный refresh токен. На сервере хранится только его хеш.
+// Все токены, полученные ротацией от одного входа, образуют семейство (FamilyID).
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	FamilyID  uuid.UUID  `json:"family_id"`
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`    // токен уже обменян на новую пару
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // семейство отозвано
+}
+
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// TokenPair - пара токенов, выдаваемая при входе и при обновлении
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration // время жизни access токена
+}
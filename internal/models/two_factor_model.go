@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Способы подтверждения второго фактора при входе
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+	MFAMethodWebAuthn     = "webauthn" // завершается через /webauthn/login/finish
+)
+
+// Количество резервных кодов в наборе
+const RecoveryCodeCount = 10
+
+// TOTPSecret - секрет приложения-аутентификатора пользователя.
+// Secret хранится зашифрованным, до подтверждения кодом Confirmed = false.
+type TOTPSecret struct {
+	UserID       uuid.UUID  `json:"user_id"`
+	Secret       string     `json:"secret"`
+	Confirmed    bool       `json:"confirmed"`
+	LastUsedStep int64      `json:"last_used_step"` // защита от повторного использования кода
+	CreatedAt    time.Time  `json:"created_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+}
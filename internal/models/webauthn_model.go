@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Церемонии WebAuthn, для которых выдается challenge
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login" // вход по passkey без пароля
+	WebAuthnCeremonyMFA          = "mfa"   // второй фактор после пароля
+)
+
+// Base64URL - двоичные поля WebAuthn, в JSON передаются как base64url без выравнивания
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func (b Base64URL) String() string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// WebAuthnCredential - passkey или ключ безопасности пользователя
+type WebAuthnCredential struct {
+	ID         string     `json:"id"` // credential id в base64url
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	PublicKey  []byte     `json:"public_key"` // PKIX DER
+	Algorithm  int64      `json:"algorithm"`  // COSE
+	SignCount  uint32     `json:"sign_count"`
+	AAGUID     []byte     `json:"aaguid"`
+	Transports []string   `json:"transports,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge - незавершенная церемония. Используется один раз.
+type WebAuthnChallenge struct {
+	ID        uuid.UUID `json:"id"`
+	Challenge string    `json:"challenge"`
+	Ceremony  string    `json:"ceremony"`
+	UserID    uuid.UUID `json:"user_id"` // uuid.Nil - вход по discoverable passkey
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// MFA challenge токен, по которому начат второй фактор; гасится после успешного входа
+	MFATokenID        string    `json:"mfa_token_id,omitempty"`
+	MFATokenExpiresAt time.Time `json:"mfa_token_expires_at,omitempty"`
+}
+
+func (c *WebAuthnChallenge) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// Параметры navigator.credentials.create() и navigator.credentials.get()
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity     `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // миллисекунды
+	Attestation            string                 `json:"attestation"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+}
+
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"` // миллисекунды
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// Ответы браузера
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+	AttestationObject Base64URL `json:"attestationObject" validate:"required"`
+	Transports        []string  `json:"transports,omitempty"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData Base64URL `json:"authenticatorData" validate:"required"`
+	Signature         Base64URL `json:"signature" validate:"required"`
+	UserHandle        Base64URL `json:"userHandle,omitempty"`
+}
+
+type AttestationCredential struct {
+	ID       string                           `json:"id" validate:"required"`
+	Type     string                           `json:"type" validate:"required,eq=public-key"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+type AssertionCredential struct {
+	ID       string                         `json:"id" validate:"required"`
+	Type     string                         `json:"type" validate:"required,eq=public-key"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+// Запросы и ответы HTTP API
+
+type WebAuthnRegisterBeginResponse struct {
+	ChallengeID uuid.UUID                          `json:"challenge_id"`
+	PublicKey   PublicKeyCredentialCreationOptions `json:"publicKey"`
+}
+
+type WebAuthnRegisterFinishRequest struct {
+	ChallengeID uuid.UUID             `json:"challenge_id" validate:"required"`
+	Name        string                `json:"name,omitempty" validate:"omitempty,max=100"`
+	Password    string                `json:"password" validate:"required"`
+	Credential  AttestationCredential `json:"credential" validate:"required"`
+}
+
+// WebAuthnLoginBeginRequest - email для входа без пароля (пусто - discoverable passkey)
+// или mfa_token после проверки пароля
+type WebAuthnLoginBeginRequest struct {
+	Email    string `json:"email,omitempty" validate:"omitempty,email"`
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+type WebAuthnLoginBeginResponse struct {
+	ChallengeID uuid.UUID                         `json:"challenge_id"`
+	PublicKey   PublicKeyCredentialRequestOptions `json:"publicKey"`
+}
+
+type WebAuthnLoginFinishRequest struct {
+	ChallengeID uuid.UUID           `json:"challenge_id" validate:"required"`
+	Credential  AssertionCredential `json:"credential" validate:"required"`
+	DeviceName  string              `json:"device_name,omitempty" validate:"omitempty,max=100"`
+}
+
+type WebAuthnCredentialResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type DeleteWebAuthnCredentialRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type WebAuthnCredentialListResponse struct {
+	Credentials []WebAuthnCredentialResponse `json:"credentials"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Состояния доставки вебхука
+const (
+	WebhookDeliveryPending = "pending"
+	WebhookDeliveryDead    = "dead" // попытки исчерпаны, ждет ручного повтора
+)
+
+// WebhookSubscription - подписка внешней системы на события пользователей.
+// Тело каждого запроса подписывается HMAC-SHA256 секретом подписки.
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"` // типы событий, пустой список - все события
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches - подходит ли событие eventType под фильтр подписки
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, allowed := range s.Events {
+		if allowed == eventType || allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery - доставка одного события одной подписке.
+// Успешно доставленные записи удаляются, в хранилище остаются ожидающие и dead letter.
+type WebhookDelivery struct {
+	ID             uuid.UUID `json:"id"`
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	Event          UserEvent `json:"event"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"` // пусто - секрет генерируется
+}
+
+// WebhookResponse - подписка без секрета. Секрет возвращается только при создании.
+type WebhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
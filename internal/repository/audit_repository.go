@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryAuditRepository хранит журнал аудита в памяти процесса
+type MemoryAuditRepository struct {
+	mu      sync.RWMutex
+	records []*models.AuditRecord // по возрастанию Seq
+}
+
+// NewMemoryAuditRepository создает новый экземпляр MemoryAuditRepository
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+func (r *MemoryAuditRepository) Append(ctx context.Context, record *models.AuditRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, copyAuditRecord(record))
+	return nil
+}
+
+func (r *MemoryAuditRepository) Last(ctx context.Context) (*models.AuditRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.records) == 0 {
+		return nil, nil
+	}
+	return copyAuditRecord(r.records[len(r.records)-1]), nil
+}
+
+func (r *MemoryAuditRepository) Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var records []*models.AuditRecord
+	for _, record := range r.records {
+		if !filter.Matches(record) {
+			continue
+		}
+		records = append(records, copyAuditRecord(record))
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func copyAuditRecord(record *models.AuditRecord) *models.AuditRecord {
+	copied := *record
+	if record.Details != nil {
+		copied.Details = make(map[string]string, len(record.Details))
+		for k, v := range record.Details {
+			copied.Details[k] = v
+		}
+	}
+	return &copied
+}
+
+// FileAuditRepository дописывает журнал в файл по записи на строку и держит его копию
+// в памяти для выборок. Файл только дополняется и никогда не перезаписывается.
+type FileAuditRepository struct {
+	mem  *MemoryAuditRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileAuditRepository создает хранилище и загружает ранее записанный журнал
+func NewFileAuditRepository(path string) (*FileAuditRepository, error) {
+	r := &FileAuditRepository{
+		mem:  NewMemoryAuditRepository(),
+		path: path,
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var record models.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode audit log line %d: %w", line, err)
+		}
+		r.mem.records = append(r.mem.records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return r, nil
+}
+
+func (r *FileAuditRepository) Append(ctx context.Context, record *models.AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+
+	return r.mem.Append(ctx, record)
+}
+
+func (r *FileAuditRepository) Last(ctx context.Context) (*models.AuditRecord, error) {
+	return r.mem.Last(ctx)
+}
+
+func (r *FileAuditRepository) Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditRecord, error) {
+	return r.mem.Query(ctx, filter)
+}
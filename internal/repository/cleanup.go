@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// ExpiredCleaner удаляет записи, истекшие к моменту now
+type ExpiredCleaner func(ctx context.Context, now time.Time) (int, error)
+
+// RunCleanup периодически вызывает cleaners до отмены ctx
+func RunCleanup(ctx context.Context, interval time.Duration, cleaners ...ExpiredCleaner) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, clean := range cleaners {
+				clean(ctx, now)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryEventFeedRepository хранит ленту изменений в памяти процесса.
+// Эпоха ленты меняется при каждом запуске: курсоры прошлого запуска недействительны.
+type MemoryEventFeedRepository struct {
+	mu        sync.RWMutex
+	epoch     string
+	lastSeq   uint64
+	entries   []*models.FeedEntry // по возрастанию Seq
+	byEvent   map[uuid.UUID]uint64
+	retention time.Duration
+}
+
+// NewMemoryEventFeedRepository создает ленту. retention <= 0 - сутки.
+func NewMemoryEventFeedRepository(retention time.Duration) *MemoryEventFeedRepository {
+	if retention <= 0 {
+		retention = 24 * time.Hour
+	}
+	return &MemoryEventFeedRepository{
+		epoch:     uuid.New().String(),
+		byEvent:   make(map[uuid.UUID]uint64),
+		retention: retention,
+	}
+}
+
+func (r *MemoryEventFeedRepository) Append(ctx context.Context, kind string, event *models.UserEvent) (*models.FeedEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if seq, ok := r.byEvent[event.ID]; ok {
+		if entry := r.find(seq); entry != nil {
+			return copyFeedEntry(entry), nil
+		}
+	}
+
+	r.lastSeq++
+	entry := &models.FeedEntry{Seq: r.lastSeq, Kind: kind, Event: *event}
+	r.entries = append(r.entries, copyFeedEntry(entry))
+	r.byEvent[event.ID] = entry.Seq
+	return entry, nil
+}
+
+func (r *MemoryEventFeedRepository) ListAfter(ctx context.Context, seq uint64, limit int) ([]*models.FeedEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	start := sort.Search(len(r.entries), func(i int) bool {
+		return r.entries[i].Seq > seq
+	})
+	end := len(r.entries)
+	if limit > 0 && end-start > limit {
+		end = start + limit
+	}
+
+	entries := make([]*models.FeedEntry, 0, end-start)
+	for _, entry := range r.entries[start:end] {
+		entries = append(entries, copyFeedEntry(entry))
+	}
+	return entries, nil
+}
+
+func (r *MemoryEventFeedRepository) State(ctx context.Context) (models.FeedState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state := models.FeedState{Epoch: r.epoch, FirstSeq: r.lastSeq + 1, LastSeq: r.lastSeq}
+	if len(r.entries) > 0 {
+		state.FirstSeq = r.entries[0].Seq
+	}
+	return state, nil
+}
+
+func (r *MemoryEventFeedRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := now.Add(-r.retention)
+	expired := 0
+	for expired < len(r.entries) && r.entries[expired].Event.OccurredAt.Before(cutoff) {
+		delete(r.byEvent, r.entries[expired].Event.ID)
+		expired++
+	}
+	r.entries = append([]*models.FeedEntry(nil), r.entries[expired:]...)
+	return expired, nil
+}
+
+func (r *MemoryEventFeedRepository) find(seq uint64) *models.FeedEntry {
+	i := sort.Search(len(r.entries), func(i int) bool {
+		return r.entries[i].Seq >= seq
+	})
+	if i < len(r.entries) && r.entries[i].Seq == seq {
+		return r.entries[i]
+	}
+	return nil
+}
+
+func copyFeedEntry(entry *models.FeedEntry) *models.FeedEntry {
+	copied := *entry
+	if entry.Event.Data != nil {
+		copied.Event.Data = make(map[string]string, len(entry.Event.Data))
+		for k, v := range entry.Event.Data {
+			copied.Event.Data[k] = v
+		}
+	}
+	return &copied
+}
+
+// eventFeedState - формат файла FileEventFeedRepository
+type eventFeedState struct {
+	Epoch   string              `json:"epoch"`
+	LastSeq uint64              `json:"last_seq"`
+	Entries []*models.FeedEntry `json:"entries"`
+}
+
+// FileEventFeedRepository хранит ленту в памяти и сохраняет ее в JSON файл:
+// эпоха и курсоры клиентов переживают перезапуск сервиса
+type FileEventFeedRepository struct {
+	mem  *MemoryEventFeedRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileEventFeedRepository создает ленту и загружает ранее сохраненные записи
+func NewFileEventFeedRepository(path string, retention time.Duration) (*FileEventFeedRepository, error) {
+	r := &FileEventFeedRepository{
+		mem:  NewMemoryEventFeedRepository(retention),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read event feed file: %w", err)
+	}
+
+	var state eventFeedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode event feed file: %w", err)
+	}
+	if state.Epoch != "" {
+		r.mem.epoch = state.Epoch
+	}
+	r.mem.lastSeq = state.LastSeq
+	r.mem.entries = state.Entries
+	for _, entry := range state.Entries {
+		r.mem.byEvent[entry.Event.ID] = entry.Seq
+	}
+	return r, nil
+}
+
+func (r *FileEventFeedRepository) Append(ctx context.Context, kind string, event *models.UserEvent) (*models.FeedEntry, error) {
+	entry, err := r.mem.Append(ctx, kind, event)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *FileEventFeedRepository) ListAfter(ctx context.Context, seq uint64, limit int) ([]*models.FeedEntry, error) {
+	return r.mem.ListAfter(ctx, seq, limit)
+}
+
+func (r *FileEventFeedRepository) State(ctx context.Context) (models.FeedState, error) {
+	return r.mem.State(ctx)
+}
+
+func (r *FileEventFeedRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	expired, err := r.mem.DeleteExpired(ctx, now)
+	if err != nil || expired == 0 {
+		return expired, err
+	}
+	return expired, r.save()
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (r *FileEventFeedRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	data, err := json.Marshal(eventFeedState{
+		Epoch:   r.mem.epoch,
+		LastSeq: r.mem.lastSeq,
+		Entries: r.mem.entries,
+	})
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode event feed: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to save event feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homecloud-auth-service/internal/models"
+)
+
+func TestFileEventFeedRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "event_feed.json")
+	now := time.Now()
+
+	repo, err := NewFileEventFeedRepository(path, time.Hour)
+	require.NoError(t, err)
+
+	old := &models.UserEvent{ID: uuid.New(), Type: models.EventUserRegistered, OccurredAt: now.Add(-2 * time.Hour)}
+	fresh := &models.UserEvent{ID: uuid.New(), Type: models.EventQuotaChanged, OccurredAt: now}
+	first, err := repo.Append(ctx, models.UserChangeCreated, old)
+	require.NoError(t, err)
+	second, err := repo.Append(ctx, models.UserChangeQuotaChanged, fresh)
+	require.NoError(t, err)
+	assert.Equal(t, first.Seq+1, second.Seq)
+
+	// Повторная доставка из outbox не создает новую запись
+	again, err := repo.Append(ctx, models.UserChangeQuotaChanged, fresh)
+	require.NoError(t, err)
+	assert.Equal(t, second.Seq, again.Seq)
+
+	expired, err := repo.DeleteExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	before, err := repo.State(ctx)
+	require.NoError(t, err)
+
+	reloaded, err := NewFileEventFeedRepository(path, time.Hour)
+	require.NoError(t, err)
+	state, err := reloaded.State(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before, state, "epoch and bounds survive restart")
+	assert.Equal(t, second.Seq, state.FirstSeq)
+
+	entries, err := reloaded.ListAfter(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, fresh.ID, entries[0].Event.ID)
+
+	// Нумерация продолжается после перезапуска
+	next, err := reloaded.Append(ctx, models.UserChangeUpdated, &models.UserEvent{ID: uuid.New(), OccurredAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, second.Seq+1, next.Seq)
+}
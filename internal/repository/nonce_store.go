@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// MemoryNonceStore хранит использованные nonce в памяти процесса
+type MemoryNonceStore struct {
+	mu     sync.RWMutex
+	nonces map[string]time.Time // nonce -> срок действия токена
+}
+
+// NewMemoryNonceStore создает новый экземпляр MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryNonceStore) Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, used := s.nonces[nonce]; used {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
+
+func (s *MemoryNonceStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for nonce, expiresAt := range s.nonces {
+		if expiresAt.Before(now) {
+			delete(s.nonces, nonce)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// FileNonceStore хранит использованные nonce в памяти и сохраняет их в JSON файл,
+// чтобы токен нельзя было использовать повторно после перезапуска сервиса
+type FileNonceStore struct {
+	mem  *MemoryNonceStore
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileNonceStore создает хранилище и загружает ранее сохраненное состояние
+func NewFileNonceStore(path string) (*FileNonceStore, error) {
+	s := &FileNonceStore{
+		mem:  NewMemoryNonceStore(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read nonce file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.mem.nonces); err != nil {
+		return nil, fmt.Errorf("failed to decode nonce file: %w", err)
+	}
+	return s, nil
+}
+
+func (s *FileNonceStore) Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	ok, err := s.mem.Consume(ctx, nonce, expiresAt)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.save()
+}
+
+func (s *FileNonceStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	deleted, err := s.mem.DeleteExpired(ctx, now)
+	if err != nil || deleted == 0 {
+		return deleted, err
+	}
+	return deleted, s.save()
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (s *FileNonceStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mem.mu.RLock()
+	data, err := json.Marshal(s.mem.nonces)
+	s.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode nonces: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to save nonces: %w", err)
+	}
+	return nil
+}
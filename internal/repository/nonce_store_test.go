@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileNonceStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nonces.json")
+
+	store, err := NewFileNonceStore(path)
+	require.NoError(t, err)
+
+	ok, err := store.Consume(ctx, "nonce-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = store.Consume(ctx, "nonce-2", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// После перезапуска nonce по-прежнему считается использованным
+	reloaded, err := NewFileNonceStore(path)
+	require.NoError(t, err)
+	ok, err = reloaded.Consume(ctx, "nonce-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	deleted, err := reloaded.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryOrphanDirectoryRepository хранит очередь удаления директорий в памяти процесса
+type MemoryOrphanDirectoryRepository struct {
+	mu          sync.RWMutex
+	directories map[uuid.UUID]*models.OrphanDirectory
+}
+
+// NewMemoryOrphanDirectoryRepository создает новый экземпляр MemoryOrphanDirectoryRepository
+func NewMemoryOrphanDirectoryRepository() *MemoryOrphanDirectoryRepository {
+	return &MemoryOrphanDirectoryRepository{
+		directories: make(map[uuid.UUID]*models.OrphanDirectory),
+	}
+}
+
+func (r *MemoryOrphanDirectoryRepository) Save(ctx context.Context, directory *models.OrphanDirectory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *directory
+	r.directories[directory.UserID] = &stored
+	return nil
+}
+
+func (r *MemoryOrphanDirectoryRepository) ListDue(ctx context.Context, now time.Time) ([]*models.OrphanDirectory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var due []*models.OrphanDirectory
+	for _, directory := range r.directories {
+		if !directory.NextAttemptAt.After(now) {
+			copied := *directory
+			due = append(due, &copied)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	return due, nil
+}
+
+func (r *MemoryOrphanDirectoryRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.directories, userID)
+	return nil
+}
+
+// FileOrphanDirectoryRepository хранит очередь в памяти и сохраняет ее в JSON файл,
+// чтобы директории не терялись при перезапуске сервиса
+type FileOrphanDirectoryRepository struct {
+	mem  *MemoryOrphanDirectoryRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileOrphanDirectoryRepository создает хранилище и загружает ранее сохраненную очередь
+func NewFileOrphanDirectoryRepository(path string) (*FileOrphanDirectoryRepository, error) {
+	r := &FileOrphanDirectoryRepository{
+		mem:  NewMemoryOrphanDirectoryRepository(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read orphan directories file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &r.mem.directories); err != nil {
+		return nil, fmt.Errorf("failed to decode orphan directories file: %w", err)
+	}
+	return r, nil
+}
+
+func (r *FileOrphanDirectoryRepository) Save(ctx context.Context, directory *models.OrphanDirectory) error {
+	if err := r.mem.Save(ctx, directory); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileOrphanDirectoryRepository) ListDue(ctx context.Context, now time.Time) ([]*models.OrphanDirectory, error) {
+	return r.mem.ListDue(ctx, now)
+}
+
+func (r *FileOrphanDirectoryRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	if err := r.mem.Delete(ctx, userID); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (r *FileOrphanDirectoryRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	data, err := json.Marshal(r.mem.directories)
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode orphan directories: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to save orphan directories: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homecloud-auth-service/internal/models"
+)
+
+func TestFileOrphanDirectoryRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orphans.json")
+	now := time.Now()
+
+	repo, err := NewFileOrphanDirectoryRepository(path)
+	require.NoError(t, err)
+
+	due := &models.OrphanDirectory{UserID: uuid.New(), CreatedAt: now, NextAttemptAt: now.Add(-time.Second)}
+	later := &models.OrphanDirectory{UserID: uuid.New(), CreatedAt: now, NextAttemptAt: now.Add(time.Hour)}
+	require.NoError(t, repo.Save(ctx, due))
+	require.NoError(t, repo.Save(ctx, later))
+
+	// После перезапуска очередь сохраняется, в работу идут только записи с подошедшим сроком
+	reloaded, err := NewFileOrphanDirectoryRepository(path)
+	require.NoError(t, err)
+	list, err := reloaded.ListDue(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, due.UserID, list[0].UserID)
+
+	require.NoError(t, reloaded.Delete(ctx, due.UserID))
+	reloaded, err = NewFileOrphanDirectoryRepository(path)
+	require.NoError(t, err)
+	list, err = reloaded.ListDue(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, later.UserID, list[0].UserID)
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryOutboxRepository хранит outbox в памяти процесса
+type MemoryOutboxRepository struct {
+	mu      sync.RWMutex
+	records map[uuid.UUID]*models.OutboxRecord
+}
+
+// NewMemoryOutboxRepository создает новый экземпляр MemoryOutboxRepository
+func NewMemoryOutboxRepository() *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{
+		records: make(map[uuid.UUID]*models.OutboxRecord),
+	}
+}
+
+func (r *MemoryOutboxRepository) Append(ctx context.Context, event *models.UserEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[event.ID]; ok {
+		return errdefs.ErrConflict
+	}
+	r.records[event.ID] = &models.OutboxRecord{
+		Event:         *event,
+		NextAttemptAt: event.OccurredAt,
+	}
+	return nil
+}
+
+func (r *MemoryOutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.OutboxRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var due []*models.OutboxRecord
+	for _, record := range r.records {
+		if !record.NextAttemptAt.After(now) {
+			due = append(due, copyOutboxRecord(record))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Event.OccurredAt.Before(due[j].Event.OccurredAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *MemoryOutboxRepository) Update(ctx context.Context, record *models.OutboxRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[record.Event.ID]; !ok {
+		return errdefs.ErrNotFound
+	}
+	r.records[record.Event.ID] = copyOutboxRecord(record)
+	return nil
+}
+
+func (r *MemoryOutboxRepository) Delete(ctx context.Context, eventID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, eventID)
+	return nil
+}
+
+func copyOutboxRecord(record *models.OutboxRecord) *models.OutboxRecord {
+	copied := *record
+	copied.Delivered = append([]string(nil), record.Delivered...)
+	return &copied
+}
+
+// FileOutboxRepository хранит outbox в памяти и сохраняет его в JSON файл:
+// недоставленные события переживают перезапуск сервиса
+type FileOutboxRepository struct {
+	mem  *MemoryOutboxRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileOutboxRepository создает хранилище и загружает ранее сохраненные события
+func NewFileOutboxRepository(path string) (*FileOutboxRepository, error) {
+	r := &FileOutboxRepository{
+		mem:  NewMemoryOutboxRepository(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read outbox file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &r.mem.records); err != nil {
+		return nil, fmt.Errorf("failed to decode outbox file: %w", err)
+	}
+	return r, nil
+}
+
+func (r *FileOutboxRepository) Append(ctx context.Context, event *models.UserEvent) error {
+	if err := r.mem.Append(ctx, event); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileOutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.OutboxRecord, error) {
+	return r.mem.ListDue(ctx, now, limit)
+}
+
+func (r *FileOutboxRepository) Update(ctx context.Context, record *models.OutboxRecord) error {
+	if err := r.mem.Update(ctx, record); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileOutboxRepository) Delete(ctx context.Context, eventID uuid.UUID) error {
+	if err := r.mem.Delete(ctx, eventID); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (r *FileOutboxRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	data, err := json.Marshal(r.mem.records)
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to save outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homecloud-auth-service/internal/models"
+)
+
+func TestFileOutboxRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	now := time.Now()
+
+	repo, err := NewFileOutboxRepository(path)
+	require.NoError(t, err)
+
+	first := &models.UserEvent{ID: uuid.New(), Type: models.EventUserRegistered, UserID: uuid.New(), OccurredAt: now.Add(-time.Second)}
+	second := &models.UserEvent{ID: uuid.New(), Type: models.EventEmailVerified, UserID: first.UserID, OccurredAt: now}
+	require.NoError(t, repo.Append(ctx, second))
+	require.NoError(t, repo.Append(ctx, first))
+
+	due, err := repo.ListDue(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, first.ID, due[0].Event.ID, "events are listed in order of occurrence")
+
+	due[0].Delivered = []string{"file"}
+	due[0].NextAttemptAt = now.Add(time.Minute)
+	require.NoError(t, repo.Update(ctx, due[0]))
+	require.NoError(t, repo.Delete(ctx, second.ID))
+
+	// После перезапуска остается недоставленное событие с состоянием доставки
+	reloaded, err := NewFileOutboxRepository(path)
+	require.NoError(t, err)
+	due, err = reloaded.ListDue(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	due, err = reloaded.ListDue(ctx, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.True(t, due[0].IsDeliveredTo("file"))
+	assert.False(t, due[0].IsDeliveredTo("http"))
+}
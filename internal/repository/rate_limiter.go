@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimiter - скользящее окно в памяти процесса: не больше limit попыток за window
+type MemoryRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string][]time.Time
+	now    func() time.Time
+}
+
+// NewMemoryRateLimiter создает новый экземпляр MemoryRateLimiter
+func NewMemoryRateLimiter(limit int, window time.Duration) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+		now:    time.Now,
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	hits := l.recent(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false, hits[0].Add(l.window).Sub(now), nil
+	}
+
+	l.hits[key] = append(hits, now)
+	return true, 0, nil
+}
+
+// recent возвращает попытки ключа, попадающие в окно
+func (l *MemoryRateLimiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
+
+func (l *MemoryRateLimiter) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deleted := 0
+	for key := range l.hits {
+		hits := l.recent(key, now)
+		if len(hits) == 0 {
+			delete(l.hits, key)
+			deleted++
+			continue
+		}
+		l.hits[key] = hits
+	}
+	return deleted, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := limiter.Allow(ctx, "user:1"); !ok {
+			t.Fatalf("Expected attempt %d to be allowed", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	ok, retryAfter, _ := limiter.Allow(ctx, "user:1")
+	if ok {
+		t.Fatal("Expected third attempt to be throttled")
+	}
+	if retryAfter != 40*time.Second {
+		t.Errorf("Expected retry after 40s, got %s", retryAfter)
+	}
+
+	// Другие ключи не затрагиваются
+	if ok, _, _ := limiter.Allow(ctx, "user:2"); !ok {
+		t.Error("Expected other key to be allowed")
+	}
+
+	// Первая попытка вышла из окна
+	now = now.Add(41 * time.Second)
+	if ok, _, _ := limiter.Allow(ctx, "user:1"); !ok {
+		t.Error("Expected attempt after window to be allowed")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if deleted, _ := limiter.DeleteExpired(ctx, now); deleted != 2 {
+		t.Errorf("Expected 2 expired keys, got %d", deleted)
+	}
+}
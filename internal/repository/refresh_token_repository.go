@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryRefreshTokenRepository хранит refresh токены в памяти процесса
+type MemoryRefreshTokenRepository struct {
+	mu     sync.RWMutex
+	byID   map[uuid.UUID]*models.RefreshToken
+	byHash map[string]uuid.UUID
+}
+
+// NewMemoryRefreshTokenRepository создает новый экземпляр MemoryRefreshTokenRepository
+func NewMemoryRefreshTokenRepository() *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{
+		byID:   make(map[uuid.UUID]*models.RefreshToken),
+		byHash: make(map[string]uuid.UUID),
+	}
+}
+
+func (r *MemoryRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byHash[token.TokenHash]; ok {
+		return errdefs.ErrConflict
+	}
+	stored := *token
+	r.byID[token.ID] = &stored
+	r.byHash[token.TokenHash] = token.ID
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byHash[tokenHash]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	token := *r.byID[id]
+	return &token, nil
+}
+
+func (r *MemoryRefreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.byID[id]
+	if !ok {
+		return false, errdefs.ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &usedAt
+	return true, nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range r.byID {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, issuedBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	families := make(map[uuid.UUID]struct{})
+	for _, token := range r.byID {
+		if token.UserID == userID && token.CreatedAt.Before(issuedBefore) {
+			families[token.FamilyID] = struct{}{}
+		}
+	}
+
+	now := time.Now()
+	for _, token := range r.byID {
+		if _, ok := families[token.FamilyID]; ok && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for id, token := range r.byID {
+		if token.ExpiresAt.Before(before) {
+			delete(r.byHash, token.TokenHash)
+			delete(r.byID, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemorySessionRepository хранит сессии пользователей в памяти процесса
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]*models.Session
+}
+
+// NewMemorySessionRepository создает новый экземпляр MemorySessionRepository
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[uuid.UUID]*models.Session),
+	}
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; ok {
+		return errdefs.ErrConflict
+	}
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *MemorySessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *MemorySessionRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []*models.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Touch(ctx context.Context, id uuid.UUID, lastSeenAt time.Time, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return errdefs.ErrNotFound
+	}
+	session.LastSeenAt = lastSeenAt
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+func (r *MemorySessionRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return errdefs.ErrNotFound
+	}
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *MemorySessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, createdBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.CreatedAt.Before(createdBefore) {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemorySessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for id, session := range r.sessions {
+		if session.ExpiresAt.Before(before) {
+			delete(r.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userRevocation - все токены пользователя, выданные раньше IssuedBefore, отозваны
+type userRevocation struct {
+	IssuedBefore time.Time `json:"issued_before"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// MemoryTokenRevocationStore хранит отозванные токены в памяти процесса
+type MemoryTokenRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time // token_id -> истечение токена
+	users  map[uuid.UUID]userRevocation
+}
+
+// NewMemoryTokenRevocationStore создает новый экземпляр MemoryTokenRevocationStore
+func NewMemoryTokenRevocationStore() *MemoryTokenRevocationStore {
+	return &MemoryTokenRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[uuid.UUID]userRevocation),
+	}
+}
+
+func (s *MemoryTokenRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) RevokeUserTokens(ctx context.Context, userID uuid.UUID, issuedBefore time.Time, expiresAt time.Time) error {
+	// iat токенов хранится с точностью до миллисекунд: без округления токен,
+	// выданный в ту же миллисекунду после выхода, считался бы выданным раньше
+	issuedBefore = issuedBefore.Truncate(time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.users[userID]
+	if ok && current.IssuedBefore.After(issuedBefore) {
+		return nil
+	}
+	s.users[userID] = userRevocation{IssuedBefore: issuedBefore, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) IsRevoked(ctx context.Context, tokenID string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[tokenID]; ok {
+		return true, nil
+	}
+	if revocation, ok := s.users[userID]; ok && issuedAt.Before(revocation.IssuedBefore) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *MemoryTokenRevocationStore) EvictExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evicted := 0
+	for tokenID, expiresAt := range s.tokens {
+		if expiresAt.Before(now) {
+			delete(s.tokens, tokenID)
+			evicted++
+		}
+	}
+	for userID, revocation := range s.users {
+		if revocation.ExpiresAt.Before(now) {
+			delete(s.users, userID)
+			evicted++
+		}
+	}
+	return evicted, nil
+}
+
+// revocationSnapshot - формат файла FileTokenRevocationStore
+type revocationSnapshot struct {
+	Tokens map[string]time.Time         `json:"tokens"`
+	Users  map[uuid.UUID]userRevocation `json:"users"`
+}
+
+// FileTokenRevocationStore хранит отозванные токены в памяти и сохраняет их в JSON файл,
+// чтобы отзыв переживал перезапуск сервиса
+type FileTokenRevocationStore struct {
+	mem  *MemoryTokenRevocationStore
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileTokenRevocationStore создает хранилище и загружает ранее сохраненное состояние
+func NewFileTokenRevocationStore(path string) (*FileTokenRevocationStore, error) {
+	s := &FileTokenRevocationStore{
+		mem:  NewMemoryTokenRevocationStore(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read revocation file: %w", err)
+	}
+
+	var snapshot revocationSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode revocation file: %w", err)
+	}
+	for tokenID, expiresAt := range snapshot.Tokens {
+		s.mem.tokens[tokenID] = expiresAt
+	}
+	for userID, revocation := range snapshot.Users {
+		s.mem.users[userID] = revocation
+	}
+	return s, nil
+}
+
+func (s *FileTokenRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if err := s.mem.RevokeToken(ctx, tokenID, expiresAt); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *FileTokenRevocationStore) RevokeUserTokens(ctx context.Context, userID uuid.UUID, issuedBefore time.Time, expiresAt time.Time) error {
+	if err := s.mem.RevokeUserTokens(ctx, userID, issuedBefore, expiresAt); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *FileTokenRevocationStore) IsRevoked(ctx context.Context, tokenID string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	return s.mem.IsRevoked(ctx, tokenID, userID, issuedAt)
+}
+
+func (s *FileTokenRevocationStore) EvictExpired(ctx context.Context, now time.Time) (int, error) {
+	evicted, err := s.mem.EvictExpired(ctx, now)
+	if err != nil || evicted == 0 {
+		return evicted, err
+	}
+	return evicted, s.save()
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (s *FileTokenRevocationStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mem.mu.RLock()
+	data, err := json.Marshal(revocationSnapshot{Tokens: s.mem.tokens, Users: s.mem.users})
+	s.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode revocations: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to save revocations: %w", err)
+	}
+	return nil
+}
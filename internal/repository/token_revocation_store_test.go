@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTokenRevocationStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	userID := uuid.New()
+	now := time.Now()
+
+	store, err := NewFileTokenRevocationStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.RevokeToken(ctx, "token-1", now.Add(time.Hour)))
+	require.NoError(t, store.RevokeUserTokens(ctx, userID, now, now.Add(time.Hour)))
+
+	reloaded, err := NewFileTokenRevocationStore(path)
+	require.NoError(t, err)
+
+	revoked, err := reloaded.IsRevoked(ctx, "token-1", uuid.New(), now)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = reloaded.IsRevoked(ctx, "token-2", userID, now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = reloaded.IsRevoked(ctx, "token-3", userID, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestMemoryTokenRevocationStoreKeepsTokensIssuedInSameSecond(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenRevocationStore()
+	userID := uuid.New()
+
+	// Выход в середине секунды; iat токенов - с точностью до миллисекунд
+	loggedOut := time.Unix(1700000000, 400_123_456)
+	require.NoError(t, store.RevokeUserTokens(ctx, userID, loggedOut, loggedOut.Add(time.Hour)))
+
+	revoked, err := store.IsRevoked(ctx, "before", userID, time.UnixMilli(1700000000_399))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	for _, issuedAt := range []time.Time{time.UnixMilli(1700000000_400), time.UnixMilli(1700000000_999)} {
+		revoked, err = store.IsRevoked(ctx, "after", userID, issuedAt)
+		require.NoError(t, err)
+		assert.False(t, revoked, "token issued at %v", issuedAt)
+	}
+}
+
+func TestMemoryTokenRevocationStoreEvictsExpired(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenRevocationStore()
+	now := time.Now()
+
+	require.NoError(t, store.RevokeToken(ctx, "expired", now.Add(-time.Minute)))
+	require.NoError(t, store.RevokeToken(ctx, "active", now.Add(time.Minute)))
+
+	evicted, err := store.EvictExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, evicted)
+
+	revoked, err := store.IsRevoked(ctx, "active", uuid.New(), now)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
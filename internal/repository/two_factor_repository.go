@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryTwoFactorRepository хранит секреты второго фактора в памяти процесса
+type MemoryTwoFactorRepository struct {
+	mu       sync.RWMutex
+	totp     map[uuid.UUID]*models.TOTPSecret
+	recovery map[uuid.UUID][]string // хеши неиспользованных резервных кодов
+}
+
+// NewMemoryTwoFactorRepository создает новый экземпляр MemoryTwoFactorRepository
+func NewMemoryTwoFactorRepository() *MemoryTwoFactorRepository {
+	return &MemoryTwoFactorRepository{
+		totp:     make(map[uuid.UUID]*models.TOTPSecret),
+		recovery: make(map[uuid.UUID][]string),
+	}
+}
+
+func (r *MemoryTwoFactorRepository) SaveTOTP(ctx context.Context, secret *models.TOTPSecret) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *secret
+	r.totp[secret.UserID] = &stored
+	return nil
+}
+
+func (r *MemoryTwoFactorRepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPSecret, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	secret, ok := r.totp[userID]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	copied := *secret
+	return &copied, nil
+}
+
+func (r *MemoryTwoFactorRepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, confirmedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secret, ok := r.totp[userID]
+	if !ok {
+		return errdefs.ErrNotFound
+	}
+	secret.Confirmed = true
+	secret.ConfirmedAt = &confirmedAt
+	return nil
+}
+
+func (r *MemoryTwoFactorRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secret, ok := r.totp[userID]
+	if !ok {
+		return false, errdefs.ErrNotFound
+	}
+	if step <= secret.LastUsedStep {
+		return false, nil
+	}
+	secret.LastUsedStep = step
+	return true, nil
+}
+
+func (r *MemoryTwoFactorRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.totp, userID)
+	return nil
+}
+
+func (r *MemoryTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(hashes) == 0 {
+		delete(r.recovery, userID)
+		return nil
+	}
+	r.recovery[userID] = append([]string(nil), hashes...)
+	return nil
+}
+
+func (r *MemoryTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hashes := r.recovery[userID]
+	for i, stored := range hashes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			r.recovery[userID] = append(hashes[:i:i], hashes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.recovery[userID]), nil
+}
+
+// twoFactorSnapshot - формат файла FileTwoFactorRepository
+type twoFactorSnapshot struct {
+	TOTP          map[uuid.UUID]*models.TOTPSecret `json:"totp"`
+	RecoveryCodes map[uuid.UUID][]string           `json:"recovery_codes"`
+}
+
+// FileTwoFactorRepository хранит секреты второго фактора в памяти и сохраняет их в JSON файл
+type FileTwoFactorRepository struct {
+	mem  *MemoryTwoFactorRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileTwoFactorRepository создает хранилище и загружает ранее сохраненное состояние
+func NewFileTwoFactorRepository(path string) (*FileTwoFactorRepository, error) {
+	r := &FileTwoFactorRepository{
+		mem:  NewMemoryTwoFactorRepository(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read two-factor file: %w", err)
+	}
+
+	var snapshot twoFactorSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode two-factor file: %w", err)
+	}
+	for userID, secret := range snapshot.TOTP {
+		r.mem.totp[userID] = secret
+	}
+	for userID, hashes := range snapshot.RecoveryCodes {
+		r.mem.recovery[userID] = hashes
+	}
+	return r, nil
+}
+
+func (r *FileTwoFactorRepository) SaveTOTP(ctx context.Context, secret *models.TOTPSecret) error {
+	if err := r.mem.SaveTOTP(ctx, secret); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileTwoFactorRepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPSecret, error) {
+	return r.mem.GetTOTP(ctx, userID)
+}
+
+func (r *FileTwoFactorRepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, confirmedAt time.Time) error {
+	if err := r.mem.ConfirmTOTP(ctx, userID, confirmedAt); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileTwoFactorRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	ok, err := r.mem.UseTOTPStep(ctx, userID, step)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, r.save()
+}
+
+func (r *FileTwoFactorRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	if err := r.mem.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	if err := r.mem.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	ok, err := r.mem.UseRecoveryCode(ctx, userID, hash)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, r.save()
+}
+
+func (r *FileTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	return r.mem.CountRecoveryCodes(ctx, userID)
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (r *FileTwoFactorRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	data, err := json.Marshal(twoFactorSnapshot{TOTP: r.mem.totp, RecoveryCodes: r.mem.recovery})
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode two-factor secrets: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to save two-factor secrets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homecloud-auth-service/internal/models"
+)
+
+func TestFileTwoFactorRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "two_factor.json")
+
+	repo, err := NewFileTwoFactorRepository(path)
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	require.NoError(t, repo.SaveTOTP(ctx, &models.TOTPSecret{UserID: userID, Secret: "encrypted", CreatedAt: time.Now()}))
+	require.NoError(t, repo.ConfirmTOTP(ctx, userID, time.Now()))
+
+	ok, err := repo.UseTOTPStep(ctx, userID, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	reloaded, err := NewFileTwoFactorRepository(path)
+	require.NoError(t, err)
+
+	secret, err := reloaded.GetTOTP(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, secret.Confirmed)
+	assert.Equal(t, "encrypted", secret.Secret)
+
+	// Шаг, принятый до перезапуска, повторно не принимается
+	ok, err = reloaded.UseTOTPStep(ctx, userID, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, reloaded.DeleteTOTP(ctx, userID))
+	_, err = reloaded.GetTOTP(ctx, userID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryWebAuthnRepository хранит ключи WebAuthn и незавершенные церемонии в памяти процесса
+type MemoryWebAuthnRepository struct {
+	mu          sync.RWMutex
+	credentials map[string]*models.WebAuthnCredential
+	challenges  map[uuid.UUID]*models.WebAuthnChallenge
+}
+
+// NewMemoryWebAuthnRepository создает новый экземпляр MemoryWebAuthnRepository
+func NewMemoryWebAuthnRepository() *MemoryWebAuthnRepository {
+	return &MemoryWebAuthnRepository{
+		credentials: make(map[string]*models.WebAuthnCredential),
+		challenges:  make(map[uuid.UUID]*models.WebAuthnChallenge),
+	}
+}
+
+func (r *MemoryWebAuthnRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.credentials[credential.ID]; ok {
+		return errdefs.ErrConflict
+	}
+	stored := *credential
+	r.credentials[credential.ID] = &stored
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) GetCredential(ctx context.Context, id string) (*models.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credential, ok := r.credentials[id]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	copied := *credential
+	return &copied, nil
+}
+
+func (r *MemoryWebAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var credentials []*models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			copied := *credential
+			credentials = append(credentials, &copied)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+func (r *MemoryWebAuthnRepository) UpdateCredentialUsage(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential, ok := r.credentials[id]
+	if !ok {
+		return errdefs.ErrNotFound
+	}
+	credential.SignCount = signCount
+	credential.LastUsedAt = &usedAt
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteCredential(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.credentials[id]; !ok {
+		return errdefs.ErrNotFound
+	}
+	delete(r.credentials, id)
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *challenge
+	r.challenges[challenge.ID] = &stored
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) ConsumeChallenge(ctx context.Context, id uuid.UUID) (*models.WebAuthnChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	delete(r.challenges, id)
+	return challenge, nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for id, challenge := range r.challenges {
+		if challenge.ExpiresAt.Before(before) {
+			delete(r.challenges, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// webAuthnSnapshot - формат файла FileWebAuthnRepository.
+// Церемонии живут несколько минут и в файл не сохраняются.
+type webAuthnSnapshot struct {
+	Credentials map[string]*models.WebAuthnCredential `json:"credentials"`
+}
+
+// FileWebAuthnRepository хранит ключи WebAuthn в памяти и сохраняет их в JSON файл
+type FileWebAuthnRepository struct {
+	mem  *MemoryWebAuthnRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileWebAuthnRepository создает хранилище и загружает ранее сохраненные ключи
+func NewFileWebAuthnRepository(path string) (*FileWebAuthnRepository, error) {
+	r := &FileWebAuthnRepository{
+		mem:  NewMemoryWebAuthnRepository(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read webauthn file: %w", err)
+	}
+
+	var snapshot webAuthnSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode webauthn file: %w", err)
+	}
+	for id, credential := range snapshot.Credentials {
+		r.mem.credentials[id] = credential
+	}
+	return r, nil
+}
+
+func (r *FileWebAuthnRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	if err := r.mem.CreateCredential(ctx, credential); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebAuthnRepository) GetCredential(ctx context.Context, id string) (*models.WebAuthnCredential, error) {
+	return r.mem.GetCredential(ctx, id)
+}
+
+func (r *FileWebAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	return r.mem.ListCredentials(ctx, userID)
+}
+
+func (r *FileWebAuthnRepository) UpdateCredentialUsage(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	if err := r.mem.UpdateCredentialUsage(ctx, id, signCount, usedAt); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebAuthnRepository) DeleteCredential(ctx context.Context, id string) error {
+	if err := r.mem.DeleteCredential(ctx, id); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebAuthnRepository) SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	return r.mem.SaveChallenge(ctx, challenge)
+}
+
+func (r *FileWebAuthnRepository) ConsumeChallenge(ctx context.Context, id uuid.UUID) (*models.WebAuthnChallenge, error) {
+	return r.mem.ConsumeChallenge(ctx, id)
+}
+
+func (r *FileWebAuthnRepository) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int, error) {
+	return r.mem.DeleteExpiredChallenges(ctx, before)
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (r *FileWebAuthnRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	data, err := json.Marshal(webAuthnSnapshot{Credentials: r.mem.credentials})
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode webauthn credentials: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to save webauthn credentials: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// MemoryWebhookRepository хранит подписки и доставки вебхуков в памяти процесса
+type MemoryWebhookRepository struct {
+	mu            sync.RWMutex
+	subscriptions map[uuid.UUID]*models.WebhookSubscription
+	deliveries    map[uuid.UUID]*models.WebhookDelivery
+}
+
+// NewMemoryWebhookRepository создает новый экземпляр MemoryWebhookRepository
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		subscriptions: make(map[uuid.UUID]*models.WebhookSubscription),
+		deliveries:    make(map[uuid.UUID]*models.WebhookDelivery),
+	}
+}
+
+func (r *MemoryWebhookRepository) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.subscriptions[subscription.ID]; ok {
+		return errdefs.ErrConflict
+	}
+	r.subscriptions[subscription.ID] = copyWebhookSubscription(subscription)
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	return copyWebhookSubscription(subscription), nil
+}
+
+func (r *MemoryWebhookRepository) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscriptions := make([]*models.WebhookSubscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscriptions = append(subscriptions, copyWebhookSubscription(subscription))
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+func (r *MemoryWebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.subscriptions[id]; !ok {
+		return errdefs.ErrNotFound
+	}
+	delete(r.subscriptions, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.SubscriptionID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryWebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.ID]; ok {
+		return errdefs.ErrConflict
+	}
+	r.deliveries[delivery.ID] = copyWebhookDelivery(delivery)
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	return copyWebhookDelivery(delivery), nil
+}
+
+func (r *MemoryWebhookRepository) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var due []*models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, copyWebhookDelivery(delivery))
+		}
+	}
+	sortDeliveries(due)
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *MemoryWebhookRepository) ListDeliveriesByStatus(ctx context.Context, status string) ([]*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deliveries []*models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == status {
+			deliveries = append(deliveries, copyWebhookDelivery(delivery))
+		}
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (r *MemoryWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.ID]; !ok {
+		return errdefs.ErrNotFound
+	}
+	r.deliveries[delivery.ID] = copyWebhookDelivery(delivery)
+	return nil
+}
+
+func (r *MemoryWebhookRepository) DeleteDelivery(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.deliveries, id)
+	return nil
+}
+
+func sortDeliveries(deliveries []*models.WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Event.OccurredAt.Before(deliveries[j].Event.OccurredAt)
+	})
+}
+
+func copyWebhookSubscription(subscription *models.WebhookSubscription) *models.WebhookSubscription {
+	copied := *subscription
+	copied.Events = append([]string(nil), subscription.Events...)
+	return &copied
+}
+
+func copyWebhookDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	copied := *delivery
+	if delivery.Event.Data != nil {
+		copied.Event.Data = make(map[string]string, len(delivery.Event.Data))
+		for k, v := range delivery.Event.Data {
+			copied.Event.Data[k] = v
+		}
+	}
+	return &copied
+}
+
+// webhookState - формат файла FileWebhookRepository
+type webhookState struct {
+	Subscriptions map[uuid.UUID]*models.WebhookSubscription `json:"subscriptions"`
+	Deliveries    map[uuid.UUID]*models.WebhookDelivery     `json:"deliveries"`
+}
+
+// FileWebhookRepository хранит подписки и доставки в памяти и сохраняет их в JSON файл:
+// подписки и недоставленные вебхуки переживают перезапуск сервиса
+type FileWebhookRepository struct {
+	mem  *MemoryWebhookRepository
+	path string
+	mu   sync.Mutex // сериализует запись файла
+}
+
+// NewFileWebhookRepository создает хранилище и загружает ранее сохраненные данные
+func NewFileWebhookRepository(path string) (*FileWebhookRepository, error) {
+	r := &FileWebhookRepository{
+		mem:  NewMemoryWebhookRepository(),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read webhooks file: %w", err)
+	}
+
+	state := webhookState{
+		Subscriptions: r.mem.subscriptions,
+		Deliveries:    r.mem.deliveries,
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks file: %w", err)
+	}
+	if state.Subscriptions != nil {
+		r.mem.subscriptions = state.Subscriptions
+	}
+	if state.Deliveries != nil {
+		r.mem.deliveries = state.Deliveries
+	}
+	return r, nil
+}
+
+func (r *FileWebhookRepository) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	if err := r.mem.CreateSubscription(ctx, subscription); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	return r.mem.GetSubscription(ctx, id)
+}
+
+func (r *FileWebhookRepository) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	return r.mem.ListSubscriptions(ctx)
+}
+
+func (r *FileWebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	if err := r.mem.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := r.mem.CreateDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	return r.mem.GetDelivery(ctx, id)
+}
+
+func (r *FileWebhookRepository) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return r.mem.ListDueDeliveries(ctx, now, limit)
+}
+
+func (r *FileWebhookRepository) ListDeliveriesByStatus(ctx context.Context, status string) ([]*models.WebhookDelivery, error) {
+	return r.mem.ListDeliveriesByStatus(ctx, status)
+}
+
+func (r *FileWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := r.mem.UpdateDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileWebhookRepository) DeleteDelivery(ctx context.Context, id uuid.UUID) error {
+	if err := r.mem.DeleteDelivery(ctx, id); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// save атомарно перезаписывает файл текущим состоянием
+func (r *FileWebhookRepository) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	data, err := json.Marshal(webhookState{
+		Subscriptions: r.mem.subscriptions,
+		Deliveries:    r.mem.deliveries,
+	})
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode webhooks: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to save webhooks: %w", err)
+	}
+	return nil
+}
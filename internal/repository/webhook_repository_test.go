@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+func TestFileWebhookRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	now := time.Now()
+
+	repo, err := NewFileWebhookRepository(path)
+	require.NoError(t, err)
+
+	subscription := &models.WebhookSubscription{ID: uuid.New(), URL: "https://hooks.example.com", Secret: "secret", CreatedAt: now}
+	require.NoError(t, repo.CreateSubscription(ctx, subscription))
+
+	pending := &models.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: subscription.ID,
+		Event:          models.UserEvent{ID: uuid.New(), Type: models.EventUserLocked, OccurredAt: now},
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+	}
+	dead := &models.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: subscription.ID,
+		Event:          models.UserEvent{ID: uuid.New(), Type: models.EventUserRegistered, OccurredAt: now.Add(-time.Minute)},
+		Status:         models.WebhookDeliveryDead,
+		Attempts:       8,
+	}
+	require.NoError(t, repo.CreateDelivery(ctx, pending))
+	require.NoError(t, repo.CreateDelivery(ctx, dead))
+	assert.ErrorIs(t, repo.CreateDelivery(ctx, pending), errdefs.ErrConflict)
+
+	reloaded, err := NewFileWebhookRepository(path)
+	require.NoError(t, err)
+
+	subscriptions, err := reloaded.ListSubscriptions(ctx)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	assert.Equal(t, "secret", subscriptions[0].Secret)
+
+	due, err := reloaded.ListDueDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "dead letters are not retried automatically")
+	assert.Equal(t, pending.ID, due[0].ID)
+
+	deadLetters, err := reloaded.ListDeliveriesByStatus(ctx, models.WebhookDeliveryDead)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, dead.ID, deadLetters[0].ID)
+
+	// Удаление подписки убирает и ее доставки
+	require.NoError(t, reloaded.DeleteSubscription(ctx, subscription.ID))
+	_, err = reloaded.GetDelivery(ctx, pending.ID)
+	assert.ErrorIs(t, err, errdefs.ErrNotFound)
+}
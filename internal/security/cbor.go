@@ -0,0 +1,165 @@
+package security
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Минимальный декодер CBOR (RFC 8949) для WebAuthn: attestationObject и COSE ключи.
+// Поддерживаются целые числа, строки байт и текста, массивы, карты и простые значения.
+// Неопределенная длина и теги не используются аутентификаторами и не поддерживаются.
+
+const cborMaxDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// decodeCBOR декодирует одно значение и возвращает количество прочитанных байт.
+// Целые числа возвращаются как int64, строки байт как []byte, карты как map[interface{}]interface{}.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("cbor: nesting too deep")
+	}
+	if d.pos >= len(d.data) {
+		return nil, errCBORTruncated
+	}
+
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	// Простые значения не имеют аргумента длины
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	arg, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, fmt.Errorf("cbor: integer overflow")
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, fmt.Errorf("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == 3 {
+			return string(raw), nil
+		}
+		return append([]byte(nil), raw...), nil
+	case 4:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported major type %d", major)
+	}
+}
+
+// argument читает аргумент заголовка: значение, длину или количество элементов
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		b, err := d.take(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(b[0]), nil
+	case info == 25:
+		b, err := d.take(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err := d.take(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err := d.take(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, fmt.Errorf("cbor: indefinite length is not supported")
+	}
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
@@ -0,0 +1,330 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"homecloud-auth-service/internal/logger"
+)
+
+// Поддерживаемые алгоритмы подписи access токенов
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const keyRingFile = "keyring.json"
+
+// SigningKey - ключ подписи access токенов, идентифицируется по kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+	RetiredAt *time.Time // ключ больше не подписывает, но ещё проверяет выданные им токены
+}
+
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// KeyRing - набор ключей подписи: активный ключ и предыдущие ключи,
+// которые хранятся, пока не истекут подписанные ими токены
+type KeyRing struct {
+	mu        sync.RWMutex
+	algorithm string
+	dir       string
+	retention time.Duration
+	keys      []*SigningKey // последний - активный
+}
+
+// NewKeyRing загружает ключи из dir или создает новый активный ключ.
+// Пустой dir - ключи хранятся только в памяти и пересоздаются при перезапуске.
+// retention - сколько проверять токены ключа после его вывода из оборота.
+func NewKeyRing(algorithm string, dir string, retention time.Duration) (*KeyRing, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	ring := &KeyRing{
+		algorithm: algorithm,
+		dir:       dir,
+		retention: retention,
+	}
+
+	if dir != "" {
+		if err := ring.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	active := ring.Active()
+	if active == nil || active.Algorithm != algorithm {
+		if _, err := ring.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// Active возвращает текущий ключ подписи
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.keys) == 0 {
+		return nil
+	}
+	return r.keys[len(r.keys)-1]
+}
+
+// Lookup ищет ключ проверки по kid
+func (r *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Rotate создает новый активный ключ, выводит предыдущий из оборота
+// и удаляет ключи, срок хранения которых истек. Набор в памяти меняется только
+// после сохранения: иначе после перезапуска нечем было бы проверить токены нового ключа.
+func (r *KeyRing) Rotate() (*SigningKey, error) {
+	key, err := generateSigningKey(r.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	kept := make([]*SigningKey, 0, len(r.keys)+1)
+	for _, old := range r.keys {
+		if old.RetiredAt == nil {
+			retired := *old
+			retired.RetiredAt = &now
+			old = &retired
+		}
+		if now.Sub(*old.RetiredAt) < r.retention {
+			kept = append(kept, old)
+		}
+	}
+	kept = append(kept, key)
+
+	if r.dir != "" {
+		if err := r.save(kept); err != nil {
+			return nil, err
+		}
+	}
+	r.keys = kept
+	return key, nil
+}
+
+// Пауза перед повтором неудачной автоматической ротации
+const rotationRetryDelay = time.Minute
+
+// RunRotation ротирует ключи до отмены ctx, когда активному ключу исполняется interval.
+// Возраст считается от создания ключа, поэтому перезапуски сервиса не откладывают ротацию.
+func (r *KeyRing) RunRotation(ctx context.Context, interval time.Duration) {
+	for {
+		wait := interval
+		if active := r.Active(); active != nil {
+			wait = time.Until(active.CreatedAt.Add(interval))
+		}
+		if wait <= 0 {
+			key, err := r.Rotate()
+			if err == nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "Signing key rotated", zap.String("kid", key.ID))
+				continue
+			}
+			logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to rotate signing key", zap.Error(err))
+			wait = rotationRetryDelay
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// JWK - открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet - содержимое /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые части всех ключей, которыми ещё можно проверять токены
+func (r *KeyRing) JWKS() JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(r.keys))}
+	for _, key := range r.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch pub := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		private = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	return &SigningKey{
+		ID:        generateRandomID(),
+		Algorithm: algorithm,
+		Private:   private,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// storedKey - формат ключа в файле keyring.json
+type storedKey struct {
+	ID         string     `json:"kid"`
+	Algorithm  string     `json:"alg"`
+	PrivateKey string     `json:"private_key"` // PKCS#8 PEM
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+}
+
+func (r *KeyRing) load() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, keyRingFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read key ring: %w", err)
+	}
+
+	var stored []storedKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to decode key ring: %w", err)
+	}
+
+	for _, sk := range stored {
+		block, _ := pem.Decode([]byte(sk.PrivateKey))
+		if block == nil {
+			return fmt.Errorf("invalid PEM for key %s", sk.ID)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse key %s: %w", sk.ID, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("key %s is not a signing key", sk.ID)
+		}
+		r.keys = append(r.keys, &SigningKey{
+			ID:        sk.ID,
+			Algorithm: sk.Algorithm,
+			Private:   signer,
+			CreatedAt: sk.CreatedAt,
+			RetiredAt: sk.RetiredAt,
+		})
+	}
+	return nil
+}
+
+// save записывает набор keys в файл; вызывается под r.mu
+func (r *KeyRing) save(keys []*SigningKey) error {
+	stored := make([]storedKey, 0, len(keys))
+	for _, key := range keys {
+		der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+		if err != nil {
+			return fmt.Errorf("failed to encode key %s: %w", key.ID, err)
+		}
+		stored = append(stored, storedKey{
+			ID:         key.ID,
+			Algorithm:  key.Algorithm,
+			PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			CreatedAt:  key.CreatedAt,
+			RetiredAt:  key.RetiredAt,
+		})
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key ring: %w", err)
+	}
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create key ring directory: %w", err)
+	}
+	path := filepath.Join(r.dir, keyRingFile)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key ring: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to replace key ring: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package security
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+// bcrypt учитывает только первые 72 байта пароля
+const bcryptMaxBytes = 72
+
+// Значения по умолчанию для незаданных длин
+const (
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = bcryptMaxBytes
+)
+
+// Username и локальная часть email короче не считаются совпадением
+const minUserInfoLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// PasswordPolicy проверяет пароли по настраиваемым правилам
+type PasswordPolicy struct {
+	rules      models.PasswordPolicy
+	dictionary map[string]struct{}
+}
+
+// NewPasswordPolicy создает политику; dictionary - запрещенные пароли (регистр не важен).
+// Незаданная минимальная длина - 8 символов, максимальная не больше 72 - предела bcrypt
+func NewPasswordPolicy(rules models.PasswordPolicy, dictionary []string) *PasswordPolicy {
+	if rules.MinLength <= 0 {
+		rules.MinLength = defaultPasswordMinLength
+	}
+	if rules.MaxLength <= 0 || rules.MaxLength > defaultPasswordMaxLength {
+		rules.MaxLength = defaultPasswordMaxLength
+	}
+
+	p := &PasswordPolicy{rules: rules, dictionary: make(map[string]struct{}, len(dictionary))}
+	for _, word := range dictionary {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			p.dictionary[word] = struct{}{}
+		}
+	}
+	p.rules.CommonPasswords = len(p.dictionary) > 0
+	return p
+}
+
+// Rules возвращает правила политики для клиентов
+func (p *PasswordPolicy) Rules() models.PasswordPolicy {
+	rules := p.rules
+	rules.ForbiddenSubstrings = append([]string(nil), p.rules.ForbiddenSubstrings...)
+	return rules
+}
+
+// Check возвращает *errdefs.ValidationError со всеми нарушениями для поля field или nil.
+// identity - username и email владельца пароля
+func (p *PasswordPolicy) Check(field, password string, identity ...string) error {
+	var fields []errdefs.FieldViolation
+	add := func(code, format string, args ...interface{}) {
+		fields = append(fields, errdefs.FieldViolation{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.rules.MinLength {
+		add("too_short", "%s must be at least %d characters", field, p.rules.MinLength)
+	}
+	if length > p.rules.MaxLength || len(password) > bcryptMaxBytes {
+		add("too_long", "%s must be at most %d characters", field, p.rules.MaxLength)
+	}
+
+	classes, alphabet := characterClasses(password)
+	if p.rules.MinCharacterClasses > 0 && classes < p.rules.MinCharacterClasses {
+		add("too_few_character_classes",
+			"%s must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", field, p.rules.MinCharacterClasses)
+	}
+	if p.rules.MinEntropyBits > 0 && entropyBits(length, alphabet) < p.rules.MinEntropyBits {
+		add("too_predictable", "%s is too easy to guess, use a longer password or more character types", field)
+	}
+	if p.rules.MaxRepeatedChars > 0 && maxRun(password) > p.rules.MaxRepeatedChars {
+		add("repeated_characters", "%s must not contain more than %d identical characters in a row", field, p.rules.MaxRepeatedChars)
+	}
+
+	lower := strings.ToLower(password)
+	if p.rules.ForbidUserInfo && containsUserInfo(lower, identity) {
+		add("contains_user_info", "%s must not contain your username or email", field)
+	}
+	for _, substring := range p.rules.ForbiddenSubstrings {
+		if substring != "" && strings.Contains(lower, strings.ToLower(substring)) {
+			add("forbidden_substring", "%s must not contain %q", field, substring)
+		}
+	}
+	if p.isCommon(lower) {
+		add("common_password", "%s is too common", field)
+	}
+
+	if len(fields) > 0 {
+		return &errdefs.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// Словарный пароль, в том числе с цифрами и символами в конце: password123, qwerty!
+func (p *PasswordPolicy) isCommon(lower string) bool {
+	if len(p.dictionary) == 0 {
+		return false
+	}
+	if _, ok := p.dictionary[lower]; ok {
+		return true
+	}
+	base := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	if base == "" || base == lower {
+		return false
+	}
+	_, ok := p.dictionary[base]
+	return ok
+}
+
+// Число классов символов и размер их общего алфавита для оценки энтропии
+func characterClasses(password string) (int, int) {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		case unicode.IsLower(r):
+			lower, other = true, true
+		case unicode.IsUpper(r):
+			upper, other = true, true
+		default:
+			symbol, other = true, true
+		}
+	}
+
+	classes, alphabet := 0, 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}} {
+		if class.present {
+			classes++
+			alphabet += class.size
+		}
+	}
+	// Буквы вне ASCII расширяют алфавит, но отдельным классом не считаются
+	if other {
+		alphabet += 100
+	}
+	return classes, alphabet
+}
+
+// Грубая оценка энтропии: пароль считается случайным в алфавите использованных классов
+func entropyBits(length, alphabet int) float64 {
+	if length == 0 || alphabet == 0 {
+		return 0
+	}
+	return float64(length) * math.Log2(float64(alphabet))
+}
+
+func maxRun(password string) int {
+	longest, run := 0, 0
+	var prev rune = -1
+	for _, r := range password {
+		if r == prev {
+			run++
+		} else {
+			run = 1
+			prev = r
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+func containsUserInfo(lower string, identity []string) bool {
+	for _, value := range identity {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			candidates = append(candidates, local)
+		}
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= minUserInfoLength && strings.Contains(lower, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CommonPasswords - встроенный список распространенных паролей
+func CommonPasswords() []string {
+	return parseDictionary(commonPasswordsFile)
+}
+
+// LoadPasswordDictionary читает список запрещенных паролей: по паролю в строке, # - комментарий
+func LoadPasswordDictionary(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password dictionary: %w", err)
+	}
+	return parseDictionary(string(data)), nil
+}
+
+func parseDictionary(data string) []string {
+	var words []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words
+}
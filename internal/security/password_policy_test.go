@@ -0,0 +1,126 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+func violationCodes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var invalid *errdefs.ValidationError
+	if !errdefs.As(err, &invalid) {
+		t.Fatalf("Expected *errdefs.ValidationError, got %v", err)
+	}
+	var codes []string
+	for _, field := range invalid.Fields {
+		codes = append(codes, field.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyDefaults(t *testing.T) {
+	policy := NewPasswordPolicy(models.PasswordPolicy{MaxLength: 500}, nil)
+
+	rules := policy.Rules()
+	if rules.MinLength != 8 || rules.MaxLength != 72 || rules.CommonPasswords {
+		t.Fatalf("Unexpected default rules: %+v", rules)
+	}
+	if err := policy.Check("password", "long enough"); err != nil {
+		t.Errorf("Expected password to pass defaults, got %v", err)
+	}
+	// Ограничение bcrypt считается в байтах: 40 кириллических символов - 80 байт
+	if codes := violationCodes(t, policy.Check("password", strings.Repeat("ж", 40))); len(codes) != 1 || codes[0] != "too_long" {
+		t.Errorf("Expected too_long, got %v", codes)
+	}
+}
+
+func TestPasswordPolicyRules(t *testing.T) {
+	policy := NewPasswordPolicy(models.PasswordPolicy{
+		MinLength:           8,
+		MinCharacterClasses: 3,
+		MinEntropyBits:      50,
+		MaxRepeatedChars:    2,
+		ForbidUserInfo:      true,
+		ForbiddenSubstrings: []string{"HomeCloud"},
+	}, []string{"Sunshine", "dragon"})
+
+	cases := []struct {
+		password string
+		want     string
+	}{
+		{"Ab1!", "too_short"},
+		{"abcdefghijkl", "too_few_character_classes"},
+		{"Aa1Bb2Cc", "too_predictable"},
+		{"Xk9#aaa-Lp2$", "repeated_characters"},
+		{"my-Alice-2024!x", "contains_user_info"},
+		{"Jd8#smith.j-q", "contains_user_info"},
+		{"my-homecloud-9X", "forbidden_substring"},
+		{"SUNSHINE", "common_password"},
+		{"Dragon2024!", "common_password"},
+	}
+	for _, tc := range cases {
+		codes := violationCodes(t, policy.Check("new_password", tc.password, "alice", "smith.j@example.com"))
+		found := false
+		for _, code := range codes {
+			found = found || code == tc.want
+		}
+		if !found {
+			t.Errorf("Check(%q): expected %s among %v", tc.password, tc.want, codes)
+		}
+	}
+
+	if err := policy.Check("new_password", "Tr0ub4dor&3-horse", "alice", "smith.j@example.com"); err != nil {
+		t.Errorf("Expected strong password to pass, got %v", err)
+	}
+}
+
+func TestPasswordPolicyReportsAllViolations(t *testing.T) {
+	policy := NewPasswordPolicy(models.PasswordPolicy{MinLength: 10, MinCharacterClasses: 2}, CommonPasswords())
+
+	err := policy.Check("password", "qwerty")
+	codes := violationCodes(t, err)
+	if strings.Join(codes, ",") != "too_short,too_few_character_classes,common_password" {
+		t.Fatalf("Unexpected violations: %v", codes)
+	}
+	if !errdefs.Is(err, errdefs.ErrInvalidInput) {
+		t.Error("Expected policy violation to be invalid input")
+	}
+	if fields := errdefs.Fields(err); fields[0].Field != "password" || fields[0].Message != "password must be at least 10 characters" {
+		t.Errorf("Unexpected field violation: %+v", fields[0])
+	}
+}
+
+func TestLoadPasswordDictionary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dictionary.txt")
+	if err := os.WriteFile(path, []byte("# corporate\nAcme2024\n\n  winter-is-coming  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := LoadPasswordDictionary(path)
+	if err != nil {
+		t.Fatalf("Failed to load dictionary: %v", err)
+	}
+	if len(words) != 2 {
+		t.Fatalf("Expected 2 words, got %v", words)
+	}
+
+	policy := NewPasswordPolicy(models.PasswordPolicy{}, words)
+	if codes := violationCodes(t, policy.Check("password", "acme2024")); len(codes) != 1 || codes[0] != "common_password" {
+		t.Errorf("Expected common_password, got %v", codes)
+	}
+	if !policy.Rules().CommonPasswords {
+		t.Error("Expected rules to report dictionary check")
+	}
+
+	if _, err := LoadPasswordDictionary(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected error for missing dictionary")
+	}
+}
@@ -1,11 +1,19 @@
 package security
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/metrics"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -13,41 +21,41 @@ import (
 
 type Security struct {
 	jwtSecret string
+	keys *KeyRing // nil - access токены подписываются HS256 с jwtSecret
 	jwtExpiration time.Duration
+	refreshExpiration time.Duration
 	verificationSecret string
 	verificationExpiration time.Duration
+	passwordResetSecret string
+	passwordResetExpiration time.Duration
 }
 
-func NewSecurity(jwtSecret string, jwtExpiration time.Duration, verificationSecret string, verificationExpiration time.Duration) *Security {
+func NewSecurity(jwtSecret string, keys *KeyRing, jwtExpiration time.Duration, refreshExpiration time.Duration, verificationSecret string, verificationExpiration time.Duration, passwordResetSecret string, passwordResetExpiration time.Duration) *Security {
 	return &Security{
 		jwtSecret: jwtSecret,
+		keys: keys,
 		jwtExpiration: jwtExpiration,
+		refreshExpiration: refreshExpiration,
 		verificationSecret: verificationSecret,
 		verificationExpiration: verificationExpiration,
+		passwordResetSecret: passwordResetSecret,
+		passwordResetExpiration: passwordResetExpiration,
 	}
 }
 
-// Хеширование паролей
+// Хеширование паролей. Пароль и хеш не логируются ни при каком уровне
 func (s *Security) HashPassword(password string) (string, error) {
-	fmt.Printf("DEBUG: Hashing password: %s\n", password)
+	defer metrics.ObserveBcrypt("hash", time.Now())
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return "", fmt.Errorf("error hashing password: %w", err)
 	}
-	hashStr := string(hash)
-	fmt.Printf("DEBUG: Generated hash: %s\n", hashStr)
-	return hashStr, nil
+	return string(hash), nil
 }
 
 func (s *Security) ComparePassword(hashedPassword, password string) error {
-	fmt.Printf("DEBUG: Comparing password: %s with hash: %s\n", password, hashedPassword)
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	if err != nil {
-		fmt.Printf("DEBUG: Password comparison failed: %v\n", err)
-		return err
-	}
-	fmt.Printf("DEBUG: Password comparison successful\n")
-	return nil
+	defer metrics.ObserveBcrypt("compare", time.Now())
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
 // Генерация случайного ID для токенов
@@ -57,19 +65,46 @@ func generateRandomID() string {
 	return hex.EncodeToString(b)
 }
 
-// JWT токены
-func (s *Security) GenerateToken(userID uuid.UUID) (string, error) {
+// iat с точностью до миллисекунд. По нему "выйти везде" отделяет старые токены от новых:
+// с целыми секундами токен, выданный в ту же секунду после выхода, тоже считался бы отозванным.
+func issuedAtClaim(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1e3
+}
+
+// Чтение iat без округления до секунд, которое делает jwt.NumericDate
+func issuedAtFromClaims(claims jwt.MapClaims) time.Time {
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(math.Round(iat * 1e3)))
+}
+
+// JWT токены. sessionID - семейство refresh токенов, к которому относится access токен
+func (s *Security) GenerateToken(userID uuid.UUID, sessionID uuid.UUID) (string, error) {
 	expirationTime := time.Now().Add(s.jwtExpiration)
 	
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
 		"token_id": generateRandomID(),
+		"sid": sessionID.String(),
 		"exp": expirationTime.Unix(),
-		"iat": time.Now().Unix(),
+		"iat": issuedAtClaim(time.Now()),
 	}
 	
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	if s.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		tokenString, err := token.SignedString([]byte(s.jwtSecret))
+		if err != nil {
+			return "", fmt.Errorf("error signing token: %w", err)
+		}
+		return tokenString, nil
+	}
+	
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", fmt.Errorf("error signing token: %w", err)
 	}
@@ -77,23 +112,38 @@ func (s *Security) GenerateToken(userID uuid.UUID) (string, error) {
 	return tokenString, nil
 }
 
-func (s *Security) ValidateToken(tokenString string) (*TokenClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// Выбор ключа проверки: по kid из набора ключей или общий секрет в режиме HS256
+func (s *Security) verificationKey(token *jwt.Token) (interface{}, error) {
+	if s.keys == nil {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(s.jwtSecret), nil
-	})
+	}
+	
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public(), nil
+}
+
+func (s *Security) ValidateToken(tokenString string) (*TokenClaims, error) {
+	token, err := jwt.Parse(tokenString, s.verificationKey)
 	
 	if err != nil {
-		if err == jwt.ErrTokenExpired {
-			return nil, fmt.Errorf("token expired")
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errdefs.ErrExpiredToken
 		}
-		return nil, fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrInvalidToken, err)
 	}
 	
 	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, errdefs.ErrInvalidToken
 	}
 	
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -113,35 +163,68 @@ func (s *Security) ValidateToken(tokenString string) (*TokenClaims, error) {
 	
 	tokenID, _ := claims["token_id"].(string)
 	
-	return &TokenClaims{
+	var sessionID uuid.UUID
+	if sid, ok := claims["sid"].(string); ok {
+		sessionID, _ = uuid.Parse(sid)
+	}
+	
+	result := &TokenClaims{
 		UserID: userID,
 		TokenID: tokenID,
-	}, nil
+		SessionID: sessionID,
+		IssuedAt: issuedAtFromClaims(claims),
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.ExpiresAt = exp.Time
+	}
+	
+	return result, nil
+}
+
+// Время жизни access токена
+func (s *Security) AccessTokenExpiration() time.Duration {
+	return s.jwtExpiration
+}
+
+// Набор ключей подписи; nil в режиме HS256
+func (s *Security) KeyRing() *KeyRing {
+	return s.keys
 }
 
-func (s *Security) RefreshToken(tokenString string) (string, error) {
-	claims, err := s.ValidateToken(tokenString)
-	if err != nil && err.Error() != "token expired" {
-		return "", err
+// Refresh токены: непрозрачная случайная строка, на сервере хранится только её хеш
+func (s *Security) GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating refresh token: %w", err)
 	}
-	
-	return s.GenerateToken(claims.UserID)
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (s *Security) HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func (s *Security) InvalidateToken(tokenString string) error {
-	// В простой реализации просто валидируем токен
-	// В реальном приложении можно хранить черный список токенов
-	_, err := s.ValidateToken(tokenString)
-	return err
+// Время жизни refresh токена
+func (s *Security) RefreshTokenExpiration() time.Duration {
+	return s.refreshExpiration
 }
 
-// Токены верификации email
-func (s *Security) GenerateVerificationToken(userID uuid.UUID) (string, error) {
+// Токены верификации email. Токен содержит подтверждаемый адрес и одноразовый nonce:
+// после смены email токен недействителен, использованный nonce отклоняется сервисом.
+func (s *Security) GenerateVerificationToken(userID uuid.UUID, email string) (string, error) {
 	expirationTime := time.Now().Add(s.verificationExpiration)
 	
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating verification nonce: %w", err)
+	}
+	
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
 		"type": "email_verification",
+		"email": email,
+		"nonce": base64.RawURLEncoding.EncodeToString(nonce),
 		"exp": expirationTime.Unix(),
 		"iat": time.Now().Unix(),
 	}
@@ -155,7 +238,7 @@ func (s *Security) GenerateVerificationToken(userID uuid.UUID) (string, error) {
 	return tokenString, nil
 }
 
-func (s *Security) ValidateVerificationToken(tokenString string) (uuid.UUID, error) {
+func (s *Security) ValidateVerificationToken(tokenString string) (*VerificationClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -164,37 +247,255 @@ func (s *Security) ValidateVerificationToken(tokenString string) (uuid.UUID, err
 	})
 	
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid verification token: %w", err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errdefs.ErrVerificationTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrInvalidToken, err)
 	}
 	
-	if !token.Valid {
-		return uuid.Nil, fmt.Errorf("invalid verification token")
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errdefs.ErrInvalidToken
 	}
 	
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return uuid.Nil, fmt.Errorf("invalid verification token claims")
+	if tokenType, _ := claims["type"].(string); tokenType != "email_verification" {
+		return nil, fmt.Errorf("%w: invalid token type", errdefs.ErrInvalidToken)
 	}
 	
-	tokenType, ok := claims["type"].(string)
-	if !ok || tokenType != "email_verification" {
-		return uuid.Nil, fmt.Errorf("invalid token type")
+	userIDStr, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user_id", errdefs.ErrInvalidToken)
 	}
 	
-	userIDStr, ok := claims["user_id"].(string)
-	if !ok {
-		return uuid.Nil, fmt.Errorf("invalid user_id in verification token")
+	email, _ := claims["email"].(string)
+	nonce, _ := claims["nonce"].(string)
+	if email == "" || nonce == "" {
+		return nil, fmt.Errorf("%w: missing email or nonce", errdefs.ErrInvalidToken)
+	}
+	
+	result := &VerificationClaims{
+		UserID: userID,
+		Email: email,
+		Nonce: nonce,
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.ExpiresAt = exp.Time
+	}
+	
+	return result, nil
+}
+
+// VerificationClaims - данные проверенного токена верификации email
+type VerificationClaims struct {
+	UserID uuid.UUID
+	Email string
+	Nonce string
+	ExpiresAt time.Time
+}
+
+// Время жизни ссылки отмены смены email: старый адрес может не проверяться неделями
+const emailRevertExpiration = 7 * 24 * time.Hour
+
+// Токены смены email. Токен подтверждения уходит на новый адрес, токен отмены - на старый.
+// Оба содержат прежний и новый адрес и одноразовый nonce.
+func (s *Security) GenerateEmailChangeToken(userID uuid.UUID, oldEmail, newEmail string) (string, error) {
+	return s.generateEmailChangeToken("email_change", userID, oldEmail, newEmail, s.verificationExpiration)
+}
+
+func (s *Security) GenerateEmailRevertToken(userID uuid.UUID, oldEmail, newEmail string) (string, error) {
+	return s.generateEmailChangeToken("email_revert", userID, oldEmail, newEmail, emailRevertExpiration)
+}
+
+func (s *Security) ValidateEmailChangeToken(tokenString string) (*EmailChangeClaims, error) {
+	return s.validateEmailChangeToken("email_change", tokenString)
+}
+
+func (s *Security) ValidateEmailRevertToken(tokenString string) (*EmailChangeClaims, error) {
+	return s.validateEmailChangeToken("email_revert", tokenString)
+}
+
+func (s *Security) generateEmailChangeToken(tokenType string, userID uuid.UUID, oldEmail, newEmail string, expiration time.Duration) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating email change nonce: %w", err)
 	}
 	
+	claims := jwt.MapClaims{
+		"user_id": userID.String(),
+		"type": tokenType,
+		"old_email": oldEmail,
+		"new_email": newEmail,
+		"nonce": base64.RawURLEncoding.EncodeToString(nonce),
+		"exp": time.Now().Add(expiration).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(s.verificationSecret))
+	if err != nil {
+		return "", fmt.Errorf("error signing email change token: %w", err)
+	}
+	
+	return tokenString, nil
+}
+
+func (s *Security) validateEmailChangeToken(tokenType, tokenString string) (*EmailChangeClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.verificationSecret), nil
+	})
+	
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errdefs.ErrVerificationTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrInvalidToken, err)
+	}
+	
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errdefs.ErrInvalidToken
+	}
+	
+	if claimType, _ := claims["type"].(string); claimType != tokenType {
+		return nil, fmt.Errorf("%w: invalid token type", errdefs.ErrInvalidToken)
+	}
+	
+	userIDStr, _ := claims["user_id"].(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user_id format: %w", err)
+		return nil, fmt.Errorf("%w: invalid user_id", errdefs.ErrInvalidToken)
+	}
+	
+	oldEmail, _ := claims["old_email"].(string)
+	newEmail, _ := claims["new_email"].(string)
+	nonce, _ := claims["nonce"].(string)
+	if oldEmail == "" || newEmail == "" || nonce == "" {
+		return nil, fmt.Errorf("%w: missing email or nonce", errdefs.ErrInvalidToken)
+	}
+	
+	result := &EmailChangeClaims{
+		UserID: userID,
+		OldEmail: oldEmail,
+		NewEmail: newEmail,
+		Nonce: nonce,
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.ExpiresAt = exp.Time
 	}
 	
-	return userID, nil
+	return result, nil
+}
+
+// EmailChangeClaims - данные проверенного токена смены email
+type EmailChangeClaims struct {
+	UserID uuid.UUID
+	OldEmail string
+	NewEmail string
+	Nonce string
+	ExpiresAt time.Time
+}
+
+// Токены сброса пароля. В токен входит отпечаток текущего хеша пароля:
+// после смены пароля (в том числе этим же токеном) токен перестает действовать.
+// Одноразовый nonce погашается при сбросе, чтобы токен нельзя было применить повторно.
+func (s *Security) GeneratePasswordResetToken(userID uuid.UUID, passwordHash string) (string, error) {
+	expirationTime := time.Now().Add(s.passwordResetExpiration)
+
+	claims := jwt.MapClaims{
+		"user_id": userID.String(),
+		"type": "password_reset",
+		"pwd": s.PasswordFingerprint(passwordHash),
+		"nonce": generateRandomID(),
+		"exp": expirationTime.Unix(),
+		"iat": time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(s.passwordResetSecret))
+	if err != nil {
+		return "", fmt.Errorf("error signing password reset token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+func (s *Security) ValidatePasswordResetToken(tokenString string) (*PasswordResetClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.passwordResetSecret), nil
+	})
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errdefs.ErrExpiredToken
+		}
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errdefs.ErrInvalidToken
+	}
+
+	if tokenType, _ := claims["type"].(string); tokenType != "password_reset" {
+		return nil, fmt.Errorf("%w: invalid token type", errdefs.ErrInvalidToken)
+	}
+
+	userIDStr, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user_id", errdefs.ErrInvalidToken)
+	}
+
+	fingerprint, _ := claims["pwd"].(string)
+	nonce, _ := claims["nonce"].(string)
+	if fingerprint == "" || nonce == "" {
+		return nil, fmt.Errorf("%w: missing password fingerprint or nonce", errdefs.ErrInvalidToken)
+	}
+
+	result := &PasswordResetClaims{
+		UserID: userID,
+		PasswordFingerprint: fingerprint,
+		Nonce: nonce,
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.ExpiresAt = exp.Time
+	}
+
+	return result, nil
+}
+
+// Отпечаток хеша пароля для привязки токена сброса.
+// HMAC с секретом, чтобы по токену нельзя было перебирать пароль офлайн.
+func (s *Security) PasswordFingerprint(passwordHash string) string {
+	mac := hmac.New(sha256.New, []byte(s.passwordResetSecret))
+	mac.Write([]byte(passwordHash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// PasswordResetClaims - данные проверенного токена сброса пароля
+type PasswordResetClaims struct {
+	UserID uuid.UUID
+	PasswordFingerprint string
+	Nonce string
+	ExpiresAt time.Time
+}
+
+// MatchesPassword - токен выдан для текущего пароля пользователя
+func (c *PasswordResetClaims) MatchesPassword(fingerprint string) bool {
+	return hmac.Equal([]byte(c.PasswordFingerprint), []byte(fingerprint))
 }
 
 type TokenClaims struct {
-	UserID  uuid.UUID `json:"user_id"`
-	TokenID string    `json:"token_id,omitempty"`
+	UserID    uuid.UUID `json:"user_id"`
+	TokenID   string    `json:"token_id,omitempty"`
+	SessionID uuid.UUID `json:"sid,omitempty"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
 }
\ No newline at end of file
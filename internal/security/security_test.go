@@ -1,18 +1,26 @@
 package security
 
 import (
-	"VotingSystem/config"
-	"fmt"
+	"context"
+	"errors"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
 )
 
-func TestGetHashPswd(t *testing.T) {
-	cfg := config.Config{}
-	security := NewSecurity(cfg)
+func newTestSecurity(jwtExpiration time.Duration) *Security {
+	return NewSecurity("test-secret-key", nil, jwtExpiration, time.Hour, "test-verification-key", time.Hour, "test-reset-secret", time.Hour)
+}
+
+func TestHashPassword(t *testing.T) {
+	security := newTestSecurity(time.Minute)
 
 	testPassword := "testPassword123"
-	hash, err := security.GetHashPswd(testPassword)
+	hash, err := security.HashPassword(testPassword)
 	if err != nil {
 		t.Fatalf("Failed to hash password: %v", err)
 	}
@@ -21,38 +29,31 @@ func TestGetHashPswd(t *testing.T) {
 		t.Error("Hash should not be equal to the original password")
 	}
 
-	err = security.CompareHashAndPassword(hash, testPassword)
+	err = security.ComparePassword(hash, testPassword)
 	if err != nil {
 		t.Errorf("Failed to compare hash and password: %v", err)
 	}
 
-	err = security.CompareHashAndPassword(hash, "wrongPassword")
+	err = security.ComparePassword(hash, "wrongPassword")
 	if err == nil {
 		t.Error("Expected error when comparing hash with incorrect password")
 	}
 }
 
 func TestJWTTokens(t *testing.T) {
-	cfg := config.Config{
-		Jwt: config.JwtConfig{
-			SecretKey:  "test-secret-key",
-			Expiration: 15,
-		},
-	}
-	security := NewSecurity(cfg)
-	
-	userID := "user123"
-	
-	token, err := security.GenerateToken(userID)
+	security := newTestSecurity(time.Minute)
+
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	token, err := security.GenerateToken(userID, sessionID)
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
 	if token == "" {
 		t.Error("Generated token should not be empty")
 	}
-	
-	fmt.Printf("Original token: %s\n", token)
-	
+
 	claims, err := security.ValidateToken(token)
 	if err != nil {
 		t.Fatalf("Failed to validate token: %v", err)
@@ -60,55 +61,244 @@ func TestJWTTokens(t *testing.T) {
 	if claims.UserID != userID {
 		t.Errorf("Expected user ID %s, got %s", userID, claims.UserID)
 	}
-	
+	if claims.TokenID == "" {
+		t.Error("Token ID should not be empty")
+	}
+	if claims.SessionID != sessionID {
+		t.Errorf("Expected session ID %s, got %s", sessionID, claims.SessionID)
+	}
+	if claims.ExpiresAt.Before(claims.IssuedAt) {
+		t.Error("Token should expire after it was issued")
+	}
+
 	_, err = security.ValidateToken("invalid.token.string")
-	if err == nil {
-		t.Error("Expected error when validating invalid token")
+	if !errors.Is(err, errdefs.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestIssuedAtKeepsMilliseconds(t *testing.T) {
+	issued := time.UnixMilli(1700000000_123)
+	got := issuedAtFromClaims(jwt.MapClaims{"iat": issuedAtClaim(issued.Add(456 * time.Microsecond))})
+	if !got.Equal(issued) {
+		t.Errorf("Expected iat %v, got %v", issued, got)
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	security := newTestSecurity(-time.Minute)
+
+	token, err := security.GenerateToken(uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	_, err = security.ValidateToken(token)
+	if !errors.Is(err, errdefs.ErrExpiredToken) {
+		t.Errorf("Expected ErrExpiredToken, got %v", err)
 	}
-	
-	time.Sleep(5 * time.Millisecond)
-	
-	refreshedToken, err := security.RefreshToken(token)
+}
+
+func TestRefreshTokens(t *testing.T) {
+	security := newTestSecurity(time.Minute)
+
+	first, err := security.GenerateRefreshToken()
 	if err != nil {
-		t.Fatalf("Failed to refresh token: %v", err)
+		t.Fatalf("Failed to generate refresh token: %v", err)
 	}
-	
-	fmt.Printf("Refreshed token: %s\n", refreshedToken)
-	
-	if refreshedToken == "" {
-		t.Error("Refreshed token should not be empty")
+	second, err := security.GenerateRefreshToken()
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
 	}
-	if refreshedToken == token {
-		t.Error("Refreshed token should be different from the original token")
+	if first == second {
+		t.Error("Refresh tokens should be unique")
+	}
+
+	if security.HashRefreshToken(first) != security.HashRefreshToken(first) {
+		t.Error("Refresh token hash should be deterministic")
+	}
+	if security.HashRefreshToken(first) == first {
+		t.Error("Refresh token hash should not be equal to the token")
 	}
 }
 
-func TestExpiredToken(t *testing.T) {
-	cfg := config.Config{
-		Jwt: config.JwtConfig{
-			SecretKey:  "test-secret-key",
-			Expiration: 1, 
-		},
-	}
-	security := NewSecurity(cfg)
-	
-	userID := "user123"
-	
-	token, err := security.GenerateToken(userID)
+func TestAsymmetricTokens(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			ring, err := NewKeyRing(algorithm, "", time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to create key ring: %v", err)
+			}
+			security := NewSecurity("", ring, time.Minute, time.Hour, "test-verification-key", time.Hour, "test-reset-secret", time.Hour)
+
+			userID := uuid.New()
+			oldToken, err := security.GenerateToken(userID, uuid.New())
+			if err != nil {
+				t.Fatalf("Failed to generate token: %v", err)
+			}
+			oldKid := ring.Active().ID
+
+			if _, err := ring.Rotate(); err != nil {
+				t.Fatalf("Failed to rotate key: %v", err)
+			}
+			newToken, err := security.GenerateToken(userID, uuid.New())
+			if err != nil {
+				t.Fatalf("Failed to generate token: %v", err)
+			}
+
+			// Токены, подписанные предыдущим ключом, остаются действительными
+			for _, token := range []string{oldToken, newToken} {
+				claims, err := security.ValidateToken(token)
+				if err != nil {
+					t.Fatalf("Failed to validate token: %v", err)
+				}
+				if claims.UserID != userID {
+					t.Errorf("Expected user ID %s, got %s", userID, claims.UserID)
+				}
+			}
+
+			jwks := ring.JWKS()
+			if len(jwks.Keys) != 2 {
+				t.Fatalf("Expected 2 keys in JWKS, got %d", len(jwks.Keys))
+			}
+			if jwks.Keys[0].Kid != oldKid || jwks.Keys[0].Alg != algorithm {
+				t.Errorf("Unexpected JWKS entry: %+v", jwks.Keys[0])
+			}
+
+			// Токен с тем же содержимым, но подписанный HS256, не принимается
+			hmac := newTestSecurity(time.Minute)
+			forged, _ := hmac.GenerateToken(userID, uuid.New())
+			if _, err := security.ValidateToken(forged); !errors.Is(err, errdefs.ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken for HS256 token, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyRingPersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	ring, err := NewKeyRing(AlgorithmEdDSA, dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+	security := NewSecurity("", ring, time.Minute, time.Hour, "test-verification-key", time.Hour, "test-reset-secret", time.Hour)
+	token, err := security.GenerateToken(uuid.New(), uuid.New())
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
-	
-	time.Sleep(1 * time.Millisecond)
-	
-	refreshedToken, err := security.RefreshToken(token)
+
+	reloaded, err := NewKeyRing(AlgorithmEdDSA, dir, time.Hour)
 	if err != nil {
-		t.Fatalf("Failed to refresh token: %v", err)
+		t.Fatalf("Failed to reload key ring: %v", err)
 	}
-	if refreshedToken == "" {
-		t.Error("Refreshed token should not be empty")
+	if reloaded.Active().ID != ring.Active().ID {
+		t.Errorf("Expected active key %s after reload, got %s", ring.Active().ID, reloaded.Active().ID)
 	}
-	if refreshedToken == token {
-		t.Error("Refreshed token should be different from the original token")
+
+	restarted := NewSecurity("", reloaded, time.Minute, time.Hour, "test-verification-key", time.Hour, "test-reset-secret", time.Hour)
+	if _, err := restarted.ValidateToken(token); err != nil {
+		t.Errorf("Failed to validate token after reload: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestKeyRingRotateKeepsKeysWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	ring, err := NewKeyRing(AlgorithmEdDSA, dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+	active := ring.Active()
+
+	// Каталог ключей указывает внутрь файла: запись невозможна
+	ring.dir = filepath.Join(dir, keyRingFile, "sub")
+	if _, err := ring.Rotate(); err == nil {
+		t.Fatal("Expected rotation to fail when the key ring cannot be saved")
+	}
+	if ring.Active().ID != active.ID || active.RetiredAt != nil || len(ring.JWKS().Keys) != 1 {
+		t.Errorf("Expected key ring to stay unchanged after a failed save")
+	}
+}
+
+func TestKeyRingRunRotationByKeyAge(t *testing.T) {
+	ring, err := NewKeyRing(AlgorithmEdDSA, t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+	old := ring.Active()
+	// Ключ создан до перезапуска и уже старше интервала
+	old.CreatedAt = time.Now().Add(-2 * time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ring.RunRotation(ctx, time.Hour)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ring.Active().ID == old.ID {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected overdue key to be rotated on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := ring.Lookup(old.ID); !ok {
+		t.Error("Expected retired key to stay available for verification")
+	}
+}
+
+func TestPasswordResetTokens(t *testing.T) {
+	security := newTestSecurity(time.Minute)
+	userID := uuid.New()
+
+	token, err := security.GeneratePasswordResetToken(userID, "hash-1")
+	if err != nil {
+		t.Fatalf("Failed to generate password reset token: %v", err)
+	}
+
+	claims, err := security.ValidatePasswordResetToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate password reset token: %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("Expected user ID %s, got %s", userID, claims.UserID)
+	}
+	if !claims.MatchesPassword(security.PasswordFingerprint("hash-1")) {
+		t.Error("Expected token to match the password it was issued for")
+	}
+	if claims.MatchesPassword(security.PasswordFingerprint("hash-2")) {
+		t.Error("Expected token not to match a changed password")
+	}
+
+	// Токен другого назначения не принимается
+	verification, _ := security.GenerateVerificationToken(userID, "user@example.com")
+	if _, err := security.ValidatePasswordResetToken(verification); !errors.Is(err, errdefs.ErrInvalidToken) {
+		t.Errorf("Expected verification token to be rejected, got %v", err)
+	}
+}
+
+func TestVerificationTokens(t *testing.T) {
+	security := newTestSecurity(time.Minute)
+	userID := uuid.New()
+
+	first, err := security.GenerateVerificationToken(userID, "user@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate verification token: %v", err)
+	}
+	second, _ := security.GenerateVerificationToken(userID, "user@example.com")
+
+	claims, err := security.ValidateVerificationToken(first)
+	if err != nil {
+		t.Fatalf("Failed to validate verification token: %v", err)
+	}
+	if claims.UserID != userID || claims.Email != "user@example.com" || claims.Nonce == "" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+	if other, _ := security.ValidateVerificationToken(second); other.Nonce == claims.Nonce {
+		t.Error("Expected each token to have its own nonce")
+	}
+
+	expired := NewSecurity("test-secret-key", nil, time.Minute, time.Hour, "test-verification-key", -time.Minute, "test-reset-secret", time.Hour)
+	token, _ := expired.GenerateVerificationToken(userID, "user@example.com")
+	if _, err := expired.ValidateVerificationToken(token); !errors.Is(err, errdefs.ErrVerificationTokenExpired) {
+		t.Errorf("Expected ErrVerificationTokenExpired, got %v", err)
+	}
+}
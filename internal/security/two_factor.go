@@ -0,0 +1,258 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+)
+
+// Параметры TOTP (RFC 6238), совместимые с Google Authenticator и аналогами
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // допустимое отклонение часов клиента в шагах
+)
+
+// Резервные коды: 10 символов base32 (50 бит), для удобства ввода разделены дефисом
+const recoveryCodeLength = 10
+
+// TwoFactor - второй фактор аутентификации: TOTP коды, MFA challenge токены
+// и шифрование секретов пользователей перед сохранением
+type TwoFactor struct {
+	issuer              string
+	challengeSecret     []byte
+	encryptionKey       [32]byte
+	challengeExpiration time.Duration
+}
+
+// NewTwoFactor создает TwoFactor. Оба ключа обязательны: секреты, зашифрованные
+// случайным ключом, после перезапуска уже не расшифровать.
+func NewTwoFactor(issuer, challengeSecret, encryptionKey string, challengeExpiration time.Duration) (*TwoFactor, error) {
+	if challengeSecret == "" {
+		return nil, errors.New("mfa challenge secret is required")
+	}
+	if encryptionKey == "" {
+		return nil, errors.New("two-factor encryption key is required")
+	}
+	if issuer == "" {
+		issuer = "HomeCloud"
+	}
+	return &TwoFactor{
+		issuer:              issuer,
+		challengeSecret:     []byte(challengeSecret),
+		encryptionKey:       sha256.Sum256([]byte(encryptionKey)),
+		challengeExpiration: challengeExpiration,
+	}, nil
+}
+
+// NewEphemeralTwoFactor создает TwoFactor со случайными ключами, которые действуют
+// только до перезапуска процесса. Годится лишь для хранилища секретов в памяти.
+func NewEphemeralTwoFactor(issuer string, challengeExpiration time.Duration) *TwoFactor {
+	twoFactor, _ := NewTwoFactor(issuer, generateRandomID()+generateRandomID(), generateRandomID()+generateRandomID(), challengeExpiration)
+	return twoFactor
+}
+
+// GenerateTOTPSecret возвращает новый секрет в base32 без выравнивания
+func (t *TwoFactor) GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating totp secret: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI формирует otpauth:// ссылку для QR-кода приложения-аутентификатора
+func (t *TwoFactor) TOTPProvisioningURI(account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", t.issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(t.issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// ValidateTOTPCode проверяет код с учетом отклонения часов и возвращает номер шага,
+// которому он соответствует. Номер шага нужен для защиты от повторного использования кода.
+func (t *TwoFactor) ValidateTOTPCode(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode вычисляет HOTP (RFC 4226) для шага step
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// GenerateRecoveryCodes возвращает n одноразовых резервных кодов вида xxxxx-xxxxx
+func (t *TwoFactor) GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("error generating recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode возвращает HMAC-SHA256 резервного кода. Регистр, пробелы и дефисы
+// не учитываются. Ключ HMAC не хранится рядом с хешами, поэтому утечка хранилища
+// не позволяет перебрать коды.
+func (t *TwoFactor) HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	mac := hmac.New(sha256.New, t.encryptionKey[:])
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateChallengeToken выдает короткоживущий токен между проверкой пароля и второго фактора
+func (t *TwoFactor) GenerateChallengeToken(userID uuid.UUID) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID.String(),
+		"type":    "mfa_challenge",
+		"jti":     generateRandomID(),
+		"exp":     time.Now().Add(t.challengeExpiration).Unix(),
+		"iat":     issuedAtClaim(time.Now()),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(t.challengeSecret)
+	if err != nil {
+		return "", fmt.Errorf("error signing mfa challenge token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ChallengeClaims - данные MFA challenge токена. TokenID нужен, чтобы токен
+// можно было использовать только для одного входа.
+type ChallengeClaims struct {
+	UserID    uuid.UUID
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (t *TwoFactor) ValidateChallengeToken(tokenString string) (*ChallengeClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return t.challengeSecret, nil
+	})
+	if err != nil {
+		if errdefs.Is(err, jwt.ErrTokenExpired) {
+			return nil, errdefs.ErrExpiredToken
+		}
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errdefs.ErrInvalidToken
+	}
+	if tokenType, _ := claims["type"].(string); tokenType != "mfa_challenge" {
+		return nil, fmt.Errorf("%w: invalid token type", errdefs.ErrInvalidToken)
+	}
+
+	userIDStr, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user_id", errdefs.ErrInvalidToken)
+	}
+	tokenID, _ := claims["jti"].(string)
+	if tokenID == "" {
+		return nil, fmt.Errorf("%w: missing jti", errdefs.ErrInvalidToken)
+	}
+
+	result := &ChallengeClaims{UserID: userID, TokenID: tokenID, IssuedAt: issuedAtFromClaims(claims)}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.ExpiresAt = exp.Time
+	}
+	return result, nil
+}
+
+// EncryptSecret шифрует секрет пользователя (AES-256-GCM) для хранения
+func (t *TwoFactor) EncryptSecret(plain string) (string, error) {
+	block, err := aes.NewCipher(t.encryptionKey[:])
+	if err != nil {
+		return "", fmt.Errorf("error creating cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (t *TwoFactor) DecryptSecret(encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("error decoding secret: %w", err)
+	}
+
+	block, err := aes.NewCipher(t.encryptionKey[:])
+	if err != nil {
+		return "", fmt.Errorf("error creating cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("error creating cipher: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("error decrypting secret: ciphertext too short")
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting secret: %w", err)
+	}
+	return string(plain), nil
+}
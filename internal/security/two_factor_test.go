@@ -0,0 +1,137 @@
+package security
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"homecloud-auth-service/internal/errdefs"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Тестовый вектор RFC 6238 (SHA1), последние 6 цифр
+	key := []byte("12345678901234567890")
+	if code := totpCode(key, 59/totpPeriod); code != "287082" {
+		t.Errorf("Expected code 287082, got %s", code)
+	}
+	if code := totpCode(key, 1111111109/totpPeriod); code != "081804" {
+		t.Errorf("Expected code 081804, got %s", code)
+	}
+}
+
+func newTestTwoFactor(t *testing.T, challengeSecret, encryptionKey string, challengeExpiration time.Duration) *TwoFactor {
+	t.Helper()
+	twoFactor, err := NewTwoFactor("HomeCloud", challengeSecret, encryptionKey, challengeExpiration)
+	if err != nil {
+		t.Fatalf("Failed to create two-factor: %v", err)
+	}
+	return twoFactor
+}
+
+func TestNewTwoFactorRequiresKeys(t *testing.T) {
+	if _, err := NewTwoFactor("HomeCloud", "", "test-encryption", time.Minute); err == nil {
+		t.Error("Expected error without challenge secret")
+	}
+	if _, err := NewTwoFactor("HomeCloud", "test-challenge", "", time.Minute); err == nil {
+		t.Error("Expected error without encryption key")
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	twoFactor := newTestTwoFactor(t, "test-challenge", "test-encryption", time.Minute)
+
+	secret, err := twoFactor.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+
+	now := time.Now()
+	step := now.Unix() / totpPeriod
+
+	if got, ok := twoFactor.ValidateTOTPCode(secret, totpCode(key, step), now); !ok || got != step {
+		t.Errorf("Expected current code to be valid for step %d, got %d, %v", step, got, ok)
+	}
+	// Допускается отклонение часов на один шаг
+	if _, ok := twoFactor.ValidateTOTPCode(secret, totpCode(key, step-1), now); !ok {
+		t.Error("Expected previous step code to be valid")
+	}
+	if _, ok := twoFactor.ValidateTOTPCode(secret, totpCode(key, step-3), now); ok {
+		t.Error("Expected old code to be rejected")
+	}
+	if _, ok := twoFactor.ValidateTOTPCode(secret, "12345", now); ok {
+		t.Error("Expected short code to be rejected")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	twoFactor := NewEphemeralTwoFactor("HomeCloud", time.Minute)
+
+	uri := twoFactor.TOTPProvisioningURI("user@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/HomeCloud:user@example.com?") {
+		t.Errorf("Unexpected URI: %s", uri)
+	}
+	for _, param := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=HomeCloud", "digits=6", "period=30"} {
+		if !strings.Contains(uri, param) {
+			t.Errorf("Expected URI to contain %s: %s", param, uri)
+		}
+	}
+}
+
+func TestChallengeToken(t *testing.T) {
+	twoFactor := newTestTwoFactor(t, "test-challenge", "test-encryption", time.Minute)
+
+	userID := uuid.New()
+	token, err := twoFactor.GenerateChallengeToken(userID)
+	if err != nil {
+		t.Fatalf("Failed to generate challenge token: %v", err)
+	}
+	got, err := twoFactor.ValidateChallengeToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate challenge token: %v", err)
+	}
+	if got.UserID != userID || got.TokenID == "" || !got.ExpiresAt.After(got.IssuedAt) {
+		t.Errorf("Unexpected challenge claims %+v", got)
+	}
+
+	// Access токен не может заменить challenge, даже если подписан тем же секретом
+	security := NewSecurity("test-challenge", nil, time.Minute, time.Hour, "test-verification-key", time.Hour, "test-reset-secret", time.Hour)
+	access, _ := security.GenerateToken(userID, uuid.New())
+	if _, err := twoFactor.ValidateChallengeToken(access); !errors.Is(err, errdefs.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for access token, got %v", err)
+	}
+
+	expired := newTestTwoFactor(t, "test-challenge", "test-encryption", -time.Minute)
+	token, _ = expired.GenerateChallengeToken(userID)
+	if _, err := twoFactor.ValidateChallengeToken(token); !errors.Is(err, errdefs.ErrExpiredToken) {
+		t.Errorf("Expected ErrExpiredToken, got %v", err)
+	}
+}
+
+func TestEncryptSecret(t *testing.T) {
+	twoFactor := newTestTwoFactor(t, "test-challenge", "test-encryption", time.Minute)
+
+	encrypted, err := twoFactor.EncryptSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+	if strings.Contains(encrypted, "JBSWY3DPEHPK3PXP") {
+		t.Error("Encrypted secret should not contain the plain secret")
+	}
+
+	plain, err := twoFactor.DecryptSecret(encrypted)
+	if err != nil {
+		t.Fatalf("Failed to decrypt secret: %v", err)
+	}
+	if plain != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected decrypted secret JBSWY3DPEHPK3PXP, got %s", plain)
+	}
+
+	other := newTestTwoFactor(t, "test-challenge", "other-encryption", time.Minute)
+	if _, err := other.DecryptSecret(encrypted); err == nil {
+		t.Error("Expected error when decrypting with another key")
+	}
+}
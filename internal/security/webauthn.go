@@ -0,0 +1,294 @@
+package security
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"homecloud-auth-service/internal/errdefs"
+)
+
+// Алгоритмы COSE (RFC 9053), которые принимаются при регистрации ключа
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// Флаги authenticatorData
+const (
+	authFlagUserPresent  = 0x01
+	authFlagUserVerified = 0x04
+	authFlagAttested     = 0x40
+)
+
+// WebAuthn - проверка церемоний регистрации и входа по passkey (WebAuthn Level 2).
+// Принимается только attestation "none": подлинность модели аутентификатора не проверяется.
+type WebAuthn struct {
+	rpID     string
+	rpName   string
+	rpIDHash [32]byte
+	origins  map[string]bool
+}
+
+// NewWebAuthn создает WebAuthn для домена rpID. origins - допустимые origin веб-интерфейса.
+func NewWebAuthn(rpID, rpName string, origins []string) *WebAuthn {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return &WebAuthn{
+		rpID:     rpID,
+		rpName:   rpName,
+		rpIDHash: sha256.Sum256([]byte(rpID)),
+		origins:  allowed,
+	}
+}
+
+func (w *WebAuthn) RPID() string {
+	return w.rpID
+}
+
+func (w *WebAuthn) RPName() string {
+	return w.rpName
+}
+
+// NewChallenge возвращает случайный challenge церемонии в base64url
+func (w *WebAuthn) NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating webauthn challenge: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AttestedCredential - ключ, созданный аутентификатором при регистрации
+type AttestedCredential struct {
+	ID           []byte
+	PublicKey    []byte // PKIX DER
+	Algorithm    int64  // COSE
+	SignCount    uint32
+	AAGUID       []byte
+	UserVerified bool
+}
+
+// collectedClientData - clientDataJSON, подписываемый браузером
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// VerifyRegistration проверяет ответ navigator.credentials.create()
+func (w *WebAuthn) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*AttestedCredential, error) {
+	if err := w.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, webAuthnError("invalid attestation object: %v", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, webAuthnError("invalid attestation object")
+	}
+	if format, _ := attestation["fmt"].(string); format != "none" {
+		return nil, webAuthnError("unsupported attestation format %q", format)
+	}
+	if stmt, _ := attestation["attStmt"].(map[interface{}]interface{}); len(stmt) != 0 {
+		return nil, webAuthnError("attestation statement must be empty for format none")
+	}
+	authData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, webAuthnError("missing authenticator data")
+	}
+
+	flags, signCount, err := w.verifyAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if flags&authFlagAttested == 0 {
+		return nil, webAuthnError("authenticator data has no attested credential")
+	}
+
+	// aaguid (16) | длина credentialId (2) | credentialId | COSE ключ
+	rest := authData[37:]
+	if len(rest) < 18 {
+		return nil, webAuthnError("attested credential data is truncated")
+	}
+	aaguid := rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, webAuthnError("invalid credential id length")
+	}
+	credentialID := rest[:idLen]
+
+	publicKey, algorithm, err := parseCOSEKey(rest[idLen:])
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, webAuthnError("unsupported public key: %v", err)
+	}
+
+	return &AttestedCredential{
+		ID:           append([]byte(nil), credentialID...),
+		PublicKey:    der,
+		Algorithm:    algorithm,
+		SignCount:    signCount,
+		AAGUID:       append([]byte(nil), aaguid...),
+		UserVerified: flags&authFlagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion проверяет ответ navigator.credentials.get() ключом publicKey и возвращает
+// новый счетчик подписей. Счетчик, не увеличившийся относительно storedSignCount,
+// означает клон аутентификатора. Аутентификаторы без счетчика всегда присылают 0.
+func (w *WebAuthn) VerifyAssertion(challenge string, publicKey []byte, algorithm int64, storedSignCount uint32, clientDataJSON, authenticatorData, signature []byte) (uint32, bool, error) {
+	if err := w.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, false, err
+	}
+
+	flags, signCount, err := w.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, false, err
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return 0, false, webAuthnError("invalid stored public key: %v", err)
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientDataHash[:]...)
+	if err := verifyCOSESignature(parsed, algorithm, signed, signature); err != nil {
+		return 0, false, err
+	}
+
+	if (signCount != 0 || storedSignCount != 0) && signCount <= storedSignCount {
+		return 0, false, webAuthnError("sign count did not increase, authenticator may be cloned")
+	}
+	return signCount, flags&authFlagUserVerified != 0, nil
+}
+
+func (w *WebAuthn) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var clientData collectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return webAuthnError("invalid client data: %v", err)
+	}
+	if clientData.Type != ceremony {
+		return webAuthnError("unexpected client data type %q", clientData.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return webAuthnError("challenge mismatch")
+	}
+	if !w.origins[clientData.Origin] {
+		return webAuthnError("origin %q is not allowed", clientData.Origin)
+	}
+	return nil
+}
+
+// verifyAuthenticatorData проверяет rpIdHash и присутствие пользователя,
+// возвращает флаги и счетчик подписей
+func (w *WebAuthn) verifyAuthenticatorData(authData []byte) (byte, uint32, error) {
+	if len(authData) < 37 {
+		return 0, 0, webAuthnError("authenticator data is truncated")
+	}
+	if !bytes.Equal(authData[:32], w.rpIDHash[:]) {
+		return 0, 0, webAuthnError("rp id hash mismatch")
+	}
+	flags := authData[32]
+	if flags&authFlagUserPresent == 0 {
+		return 0, 0, webAuthnError("user presence flag is not set")
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+// parseCOSEKey разбирает открытый ключ в формате COSE_Key (RFC 9052)
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	decoded, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, webAuthnError("invalid credential public key: %v", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, webAuthnError("invalid credential public key")
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, webAuthnError("invalid P-256 public key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, webAuthnError("P-256 point is not on curve")
+		}
+		return pub, alg, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, webAuthnError("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, webAuthnError("invalid RSA public key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	default:
+		return nil, 0, webAuthnError("unsupported key type %d with algorithm %d", kty, alg)
+	}
+}
+
+func verifyCOSESignature(publicKey crypto.PublicKey, algorithm int64, signed, signature []byte) error {
+	switch algorithm {
+	case COSEAlgES256:
+		pub, ok := publicKey.(*ecdsa.PublicKey)
+		digest := sha256.Sum256(signed)
+		if !ok || !ecdsa.VerifyASN1(pub, digest[:], signature) {
+			return webAuthnError("invalid signature")
+		}
+	case COSEAlgEdDSA:
+		pub, ok := publicKey.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, signed, signature) {
+			return webAuthnError("invalid signature")
+		}
+	case COSEAlgRS256:
+		pub, ok := publicKey.(*rsa.PublicKey)
+		digest := sha256.Sum256(signed)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return webAuthnError("invalid signature")
+		}
+	default:
+		return webAuthnError("unsupported algorithm %d", algorithm)
+	}
+	return nil
+}
+
+func webAuthnError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errdefs.ErrWebAuthnFailed, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,231 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"homecloud-auth-service/internal/errdefs"
+)
+
+const testOrigin = "https://cloud.example.com"
+
+// encodeCBOR - кодировщик CBOR для тестов, поддерживает типы из decodeCBOR
+func encodeCBOR(value interface{}) []byte {
+	header := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case map[interface{}]interface{}:
+		// Порядок ключей для декодера не важен, сортировка делает вывод стабильным
+		keys := make([]interface{}, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return string(encodeCBOR(keys[i])) < string(encodeCBOR(keys[j]))
+		})
+		out := header(5, uint64(len(v)))
+		for _, key := range keys {
+			out = append(out, encodeCBOR(key)...)
+			out = append(out, encodeCBOR(v[key])...)
+		}
+		return out
+	default:
+		panic("unsupported cbor value")
+	}
+}
+
+// testAuthenticator - программный ES256 аутентификатор
+type testAuthenticator struct {
+	key       *ecdsa.PrivateKey
+	id        []byte
+	rpID      string
+	signCount uint32
+	flags     byte
+}
+
+func newTestAuthenticator(t *testing.T, rpID string) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return &testAuthenticator{
+		key:   key,
+		id:    []byte("test-credential-id"),
+		rpID:  rpID,
+		flags: authFlagUserPresent | authFlagUserVerified,
+	}
+}
+
+func (a *testAuthenticator) authData(extra []byte, flags byte) []byte {
+	hash := sha256.Sum256([]byte(a.rpID))
+	data := append(hash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, extra...)
+}
+
+func clientData(ceremony, challenge, origin string) []byte {
+	data, _ := json.Marshal(collectedClientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	return data
+}
+
+// create возвращает clientDataJSON и attestationObject формата none
+func (a *testAuthenticator) create(challenge, origin string) ([]byte, []byte) {
+	coseKey := encodeCBOR(map[interface{}]interface{}{
+		1:  2,
+		3:  COSEAlgES256,
+		-1: 1,
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	credentialData := append(make([]byte, 16), byte(len(a.id)>>8), byte(len(a.id)))
+	credentialData = append(credentialData, a.id...)
+	credentialData = append(credentialData, coseKey...)
+
+	attestation := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authData(credentialData, a.flags|authFlagAttested),
+	})
+	return clientData("webauthn.create", challenge, origin), attestation
+}
+
+// get возвращает clientDataJSON, authenticatorData и подпись
+func (a *testAuthenticator) get(t *testing.T, challenge, origin string) ([]byte, []byte, []byte) {
+	a.signCount++
+	client := clientData("webauthn.get", challenge, origin)
+	authData := a.authData(nil, a.flags)
+
+	clientHash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign assertion: %v", err)
+	}
+	return client, authData, signature
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	data := encodeCBOR(map[interface{}]interface{}{
+		"n": -257,
+		"b": []byte{1, 2, 3},
+		1:   1000000,
+	})
+	decoded, n, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if n != len(data) {
+		t.Errorf("Expected %d bytes consumed, got %d", len(data), n)
+	}
+	m := decoded.(map[interface{}]interface{})
+	if m["n"] != int64(-257) || m[int64(1)] != int64(1000000) || string(m["b"].([]byte)) != "\x01\x02\x03" {
+		t.Errorf("Unexpected decoded value: %#v", m)
+	}
+
+	if _, _, err := decodeCBOR(data[:len(data)-1]); err == nil {
+		t.Error("Expected truncated data to fail")
+	}
+	if _, _, err := decodeCBOR([]byte{0x9f}); err == nil {
+		t.Error("Expected indefinite length array to fail")
+	}
+}
+
+func TestWebAuthnRegistrationAndAssertion(t *testing.T) {
+	webAuthn := NewWebAuthn("cloud.example.com", "HomeCloud", []string{testOrigin})
+	authenticator := newTestAuthenticator(t, "cloud.example.com")
+
+	challenge, _ := webAuthn.NewChallenge()
+	client, attestation := authenticator.create(challenge, testOrigin)
+	credential, err := webAuthn.VerifyRegistration(challenge, client, attestation)
+	if err != nil {
+		t.Fatalf("Failed to verify registration: %v", err)
+	}
+	if string(credential.ID) != string(authenticator.id) || credential.Algorithm != COSEAlgES256 {
+		t.Errorf("Unexpected credential: %+v", credential)
+	}
+	expectedKey, _ := x509.MarshalPKIXPublicKey(&authenticator.key.PublicKey)
+	if string(credential.PublicKey) != string(expectedKey) {
+		t.Error("Expected stored public key to match authenticator key")
+	}
+
+	challenge, _ = webAuthn.NewChallenge()
+	client, authData, signature := authenticator.get(t, challenge, testOrigin)
+	signCount, verified, err := webAuthn.VerifyAssertion(challenge, credential.PublicKey, credential.Algorithm, credential.SignCount, client, authData, signature)
+	if err != nil {
+		t.Fatalf("Failed to verify assertion: %v", err)
+	}
+	if signCount != 1 || !verified {
+		t.Errorf("Expected sign count 1 with user verification, got %d, %v", signCount, verified)
+	}
+
+	// Тот же ответ с неувеличившимся счетчиком - признак клона
+	_, _, err = webAuthn.VerifyAssertion(challenge, credential.PublicKey, credential.Algorithm, signCount, client, authData, signature)
+	if !errors.Is(err, errdefs.ErrWebAuthnFailed) {
+		t.Errorf("Expected replayed assertion to fail, got %v", err)
+	}
+
+	// Подпись другим ключом
+	other := newTestAuthenticator(t, "cloud.example.com")
+	other.signCount = 10
+	client, authData, signature = other.get(t, challenge, testOrigin)
+	if _, _, err := webAuthn.VerifyAssertion(challenge, credential.PublicKey, credential.Algorithm, signCount, client, authData, signature); !errors.Is(err, errdefs.ErrWebAuthnFailed) {
+		t.Errorf("Expected foreign signature to fail, got %v", err)
+	}
+}
+
+func TestWebAuthnRejectsWrongContext(t *testing.T) {
+	webAuthn := NewWebAuthn("cloud.example.com", "HomeCloud", []string{testOrigin})
+	challenge, _ := webAuthn.NewChallenge()
+
+	cases := map[string]func() ([]byte, []byte){
+		"wrong origin": func() ([]byte, []byte) {
+			return newTestAuthenticator(t, "cloud.example.com").create(challenge, "https://evil.example.com")
+		},
+		"wrong challenge": func() ([]byte, []byte) {
+			return newTestAuthenticator(t, "cloud.example.com").create("other-challenge", testOrigin)
+		},
+		"wrong rp id": func() ([]byte, []byte) {
+			return newTestAuthenticator(t, "evil.example.com").create(challenge, testOrigin)
+		},
+		"no user presence": func() ([]byte, []byte) {
+			authenticator := newTestAuthenticator(t, "cloud.example.com")
+			authenticator.flags = 0
+			return authenticator.create(challenge, testOrigin)
+		},
+	}
+
+	for name, build := range cases {
+		client, attestation := build()
+		if _, err := webAuthn.VerifyRegistration(challenge, client, attestation); !errors.Is(err, errdefs.ErrWebAuthnFailed) {
+			t.Errorf("%s: expected ErrWebAuthnFailed, got %v", name, err)
+		}
+	}
+}
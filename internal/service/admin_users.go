@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Изменение аккаунта администратором; nil - поле не меняется. Оба поля проверяются
+// и сохраняются одной записью, поэтому события не описывают частично примененное изменение.
+// При блокировке завершаются все сессии: выданные токены перестают действовать сразу.
+func (s *UserService) AdminUpdateUser(ctx context.Context, userID uuid.UUID, isActive *bool, storageQuota *int64) error {
+	if storageQuota != nil && *storageQuota < 0 {
+		return errdefs.InvalidField("storage_quota", "negative", "storage quota must not be negative")
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	previousQuota := user.StorageQuota
+	quotaChanged := storageQuota != nil && *storageQuota != user.StorageQuota
+	activeChanged := isActive != nil && *isActive != user.IsActive
+	if !quotaChanged && !activeChanged {
+		return nil
+	}
+
+	details := map[string]string{}
+	if quotaChanged {
+		user.StorageQuota = *storageQuota
+		details["storage_quota"] = strconv.FormatInt(user.StorageQuota, 10)
+		details["previous_storage_quota"] = strconv.FormatInt(previousQuota, 10)
+	}
+	if activeChanged {
+		user.IsActive = *isActive
+		details["is_active"] = strconv.FormatBool(user.IsActive)
+	}
+	user.UpdatedAt = time.Now()
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	s.recordAudit(ctx, models.AuditAdminUserUpdate, user.ID, nil, details)
+
+	// События пишутся сразу после сохранения: изменение уже применено, даже если
+	// завершить сессии не удастся
+	if quotaChanged {
+		s.recordEvent(ctx, models.EventQuotaChanged, user.ID, map[string]string{
+			"storage_quota":          details["storage_quota"],
+			"previous_storage_quota": details["previous_storage_quota"],
+		})
+	}
+	if activeChanged {
+		if user.IsActive {
+			s.recordEvent(ctx, models.EventUserEnabled, user.ID, nil)
+		} else {
+			s.recordEvent(ctx, models.EventUserDisabled, user.ID, nil)
+		}
+	}
+
+	if activeChanged && !user.IsActive {
+		if err := s.LogoutAll(ctx, user.ID, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
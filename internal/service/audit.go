@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"homecloud-auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Запись действия над пользователем target в журнал аудита. cause != nil - действие
+// отклонено, текст ошибки сохраняется как причина. Исполнитель берется из контекста.
+func (s *UserService) recordAudit(ctx context.Context, action string, target uuid.UUID, cause error, details map[string]string) {
+	record := &models.AuditRecord{
+		Action:       action,
+		TargetUserID: target,
+		Outcome:      models.AuditSuccess,
+		Details:      details,
+	}
+	if cause != nil {
+		record.Outcome = models.AuditFailure
+		record.Reason = cause.Error()
+	}
+	s.auditLog.Record(ctx, record)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/mail"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/validation"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Запрос смены email. Адрес меняется только после перехода по ссылке из письма,
+// отправленного на новый адрес; до этого у аккаунта остается прежний email и его статус верификации.
+func (s *UserService) RequestEmailChange(ctx context.Context, userID uuid.UUID, password, newEmail string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	if err := s.comparePassword(ctx, user.PasswordHash, password); err != nil {
+		return errdefs.ErrInvalidCredentials
+	}
+
+	newEmail = strings.TrimSpace(newEmail)
+	if err := validation.Struct(models.ChangeEmailRequest{NewEmail: newEmail, Password: password}); err != nil {
+		return err
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return errdefs.InvalidField("new_email", "unchanged", "new email matches the current one")
+	}
+	if err := s.checkEmailAvailable(ctx, newEmail); err != nil {
+		return err
+	}
+
+	// Письма уходят на чужой адрес - тот же лимит, что и для повторной верификации
+	allowed, retryAfter, err := s.resendLimiter.Allow(ctx, "email:"+strings.ToLower(newEmail))
+	if err != nil {
+		return fmt.Errorf("failed to check email change limit: %w", err)
+	}
+	if !allowed {
+		return &errdefs.RateLimitError{RetryAfter: retryAfter}
+	}
+
+	token, err := s.security.GenerateEmailChangeToken(user.ID, user.Email, newEmail)
+	if err != nil {
+		return fmt.Errorf("failed to generate email change token: %w", err)
+	}
+	link, err := tokenLink(s.emailChangeURL, token)
+	if err != nil {
+		return err
+	}
+
+	recipient := *user
+	recipient.Email = newEmail
+	return s.sendEmail(ctx, &recipient, mail.TemplateEmailChange, mail.TemplateData{Link: link, NewEmail: newEmail})
+}
+
+// Подтверждение смены email по ссылке с нового адреса. Новый адрес считается
+// подтвержденным; на старый уходит уведомление со ссылкой отмены.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string) error {
+	claims, err := s.security.ValidateEmailChangeToken(token)
+	if err != nil {
+		return fmt.Errorf("invalid email change token: %w", err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	// Email успел измениться другим запросом - ссылка устарела
+	if !strings.EqualFold(user.Email, claims.OldEmail) {
+		return errdefs.ErrVerificationEmailMismatch
+	}
+
+	if err := s.checkEmailAvailable(ctx, claims.NewEmail); err != nil {
+		return err
+	}
+	if err := s.consumeEmailToken(ctx, claims.Nonce, claims.ExpiresAt); err != nil {
+		return err
+	}
+
+	oldEmail := user.Email
+	if err := s.setEmail(ctx, user, claims.NewEmail); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, models.AuditEmailChange, user.ID, nil, map[string]string{
+		"old_email": oldEmail,
+		"new_email": claims.NewEmail,
+	})
+
+	revertToken, err := s.security.GenerateEmailRevertToken(user.ID, oldEmail, claims.NewEmail)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to generate email revert token", zap.Stringer("user_id", user.ID), zap.Error(err))
+		return nil
+	}
+	link, err := tokenLink(s.emailRevertURL, revertToken)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to build email revert link", zap.Error(err))
+		return nil
+	}
+
+	recipient := *user
+	recipient.Email = oldEmail
+	client := models.ClientInfoFromContext(ctx)
+	err = s.sendEmail(ctx, &recipient, mail.TemplateEmailChanged, mail.TemplateData{
+		Link:      link,
+		NewEmail:  claims.NewEmail,
+		Time:      time.Now(),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to notify old address about email change", zap.String("email", oldEmail), zap.Error(err))
+	}
+	return nil
+}
+
+// Отмена смены email по ссылке «это был не я» со старого адреса. Старый адрес
+// возвращается подтвержденным, все сессии завершаются: аккаунт мог быть захвачен.
+func (s *UserService) RevertEmailChange(ctx context.Context, token string) error {
+	claims, err := s.security.ValidateEmailRevertToken(token)
+	if err != nil {
+		return fmt.Errorf("invalid email revert token: %w", err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if !strings.EqualFold(user.Email, claims.NewEmail) {
+		return errdefs.ErrVerificationEmailMismatch
+	}
+
+	if err := s.checkEmailAvailable(ctx, claims.OldEmail); err != nil {
+		return err
+	}
+	if err := s.consumeEmailToken(ctx, claims.Nonce, claims.ExpiresAt); err != nil {
+		return err
+	}
+
+	if err := s.setEmail(ctx, user, claims.OldEmail); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, models.AuditEmailChangeRevert, user.ID, nil, map[string]string{
+		"restored_email": claims.OldEmail,
+		"reverted_email": claims.NewEmail,
+	})
+
+	if err := s.LogoutAll(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+
+	s.sendSecurityAlert(ctx, user, fmt.Sprintf("Смена email на %s отменена, все сессии завершены. Рекомендуем сменить пароль.", claims.NewEmail))
+	return nil
+}
+
+// Проверка, что адрес не занят другим аккаунтом
+func (s *UserService) checkEmailAvailable(ctx context.Context, email string) error {
+	exists, err := s.repo.CheckEmailExists(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to check email existence: %w", err)
+	}
+	if exists {
+		return errdefs.Public(errdefs.ErrConflict, "auth.email_taken", "email already exists")
+	}
+	return nil
+}
+
+// Одноразовость токенов смены email - через то же хранилище, что и у верификации
+func (s *UserService) consumeEmailToken(ctx context.Context, nonce string, expiresAt time.Time) error {
+	fresh, err := s.verificationNonces.Consume(ctx, nonce, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to consume email token: %w", err)
+	}
+	if !fresh {
+		return errdefs.ErrVerificationTokenUsed
+	}
+	return nil
+}
+
+// Установка адреса, владение которым подтверждено переходом по ссылке
+func (s *UserService) setEmail(ctx context.Context, user *models.User, email string) error {
+	user.Email = email
+	user.IsEmailVerified = true
+	user.UpdatedAt = time.Now()
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+	s.recordEvent(ctx, models.EventEmailChanged, user.ID, map[string]string{"email": email})
+	return nil
+}
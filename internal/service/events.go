@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Запись события в outbox сразу после изменения состояния. Запись в БД идет через
+// отдельный сервис, общей транзакции нет: ошибка outbox не отменяет уже сделанное
+// изменение и только логируется.
+func (s *UserService) recordEvent(ctx context.Context, eventType string, userID uuid.UUID, data map[string]string) {
+	event := &models.UserEvent{
+		ID:         uuid.New(),
+		Type:       eventType,
+		UserID:     userID,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	}
+	if err := s.outbox.Append(ctx, event); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to record user event",
+			zap.String("event_type", eventType), zap.Stringer("user_id", userID), zap.Error(err))
+	}
+}
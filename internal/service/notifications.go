@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/mail"
+	"homecloud-auth-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// Отправка письма пользователю по шаблону
+func (s *UserService) sendEmail(ctx context.Context, user *models.User, template string, data mail.TemplateData) error {
+	data.Username = user.Username
+	message, err := mail.Render(template, user.Email, data)
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", template, err)
+	}
+	return nil
+}
+
+// Уведомление о важном изменении аккаунта. Ошибка доставки не отменяет само изменение.
+func (s *UserService) sendSecurityAlert(ctx context.Context, user *models.User, event string) {
+	client := models.ClientInfoFromContext(ctx)
+	err := s.sendEmail(ctx, user, mail.TemplateSecurityAlert, mail.TemplateData{
+		Event:     event,
+		Time:      time.Now(),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to send security alert", zap.String("email", user.Email), zap.Error(err))
+	}
+}
+
+// Ссылка из письма: base с токеном в параметре token
+func tokenLink(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid link url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
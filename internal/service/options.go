@@ -0,0 +1,155 @@
+package service
+
+import (
+	"time"
+
+	"homecloud-auth-service/internal/interfaces"
+)
+
+// Option настраивает необязательные зависимости UserService.
+// Если зависимость не передана, используется реализация в памяти.
+type Option func(*UserService)
+
+// WithRefreshTokenRepository задает хранилище refresh токенов
+func WithRefreshTokenRepository(repo interfaces.RefreshTokenRepository) Option {
+	return func(s *UserService) {
+		s.refreshTokens = repo
+	}
+}
+
+// WithTokenRevocationStore задает хранилище отозванных access токенов
+func WithTokenRevocationStore(store interfaces.TokenRevocationStore) Option {
+	return func(s *UserService) {
+		s.revocations = store
+	}
+}
+
+// WithSessionRepository задает хранилище сессий
+func WithSessionRepository(repo interfaces.SessionRepository) Option {
+	return func(s *UserService) {
+		s.sessions = repo
+	}
+}
+
+// WithTwoFactorRepository задает хранилище секретов второго фактора
+func WithTwoFactorRepository(repo interfaces.TwoFactorRepository) Option {
+	return func(s *UserService) {
+		s.twoFactorRepo = repo
+	}
+}
+
+// WithTwoFactor задает ключи MFA challenge токенов и шифрования секретов
+func WithTwoFactor(twoFactor interfaces.TwoFactor) Option {
+	return func(s *UserService) {
+		s.twoFactor = twoFactor
+	}
+}
+
+// WithWebAuthnRepository задает хранилище passkey и церемоний WebAuthn
+func WithWebAuthnRepository(repo interfaces.WebAuthnRepository) Option {
+	return func(s *UserService) {
+		s.webAuthnRepo = repo
+	}
+}
+
+// WithWebAuthn задает параметры проверяющей стороны WebAuthn (домен и origin)
+func WithWebAuthn(webAuthn interfaces.WebAuthn) Option {
+	return func(s *UserService) {
+		s.webAuthn = webAuthn
+	}
+}
+
+// WithMailer задает способ доставки писем пользователям
+func WithMailer(mailer interfaces.Mailer) Option {
+	return func(s *UserService) {
+		s.mailer = mailer
+	}
+}
+
+// WithVerificationURL задает адрес подтверждения email, на который ведет ссылка из письма.
+// Пустая строка оставляет адрес по умолчанию.
+func WithVerificationURL(url string) Option {
+	return func(s *UserService) {
+		if url != "" {
+			s.verifyURL = url
+		}
+	}
+}
+
+// WithPasswordResetURL задает страницу сброса пароля, на которую ведет ссылка из письма.
+// Пустая строка оставляет адрес по умолчанию.
+func WithPasswordResetURL(url string) Option {
+	return func(s *UserService) {
+		if url != "" {
+			s.resetURL = url
+		}
+	}
+}
+
+// WithEmailChangeURLs задает адреса подтверждения и отмены смены email для ссылок из писем.
+// Пустые строки оставляют адреса по умолчанию.
+func WithEmailChangeURLs(confirmURL, revertURL string) Option {
+	return func(s *UserService) {
+		if confirmURL != "" {
+			s.emailChangeURL = confirmURL
+		}
+		if revertURL != "" {
+			s.emailRevertURL = revertURL
+		}
+	}
+}
+
+// WithVerificationResendLimiter задает лимит повторной отправки письма для верификации
+func WithVerificationResendLimiter(limiter interfaces.RateLimiter) Option {
+	return func(s *UserService) {
+		s.resendLimiter = limiter
+	}
+}
+
+// WithVerificationNonceStore задает хранилище использованных токенов верификации email
+func WithVerificationNonceStore(store interfaces.NonceStore) Option {
+	return func(s *UserService) {
+		s.verificationNonces = store
+	}
+}
+
+// WithOrphanDirectoryRepository задает очередь директорий, оставшихся от неудачных регистраций
+func WithOrphanDirectoryRepository(repo interfaces.OrphanDirectoryRepository) Option {
+	return func(s *UserService) {
+		s.orphanDirectories = repo
+	}
+}
+
+// WithRetry задает число попыток и начальную паузу для вызовов внешних сервисов при регистрации.
+// Неположительные значения оставляют значения по умолчанию.
+func WithRetry(attempts int, baseDelay time.Duration) Option {
+	return func(s *UserService) {
+		if attempts > 0 {
+			s.retry.attempts = attempts
+		}
+		if baseDelay > 0 {
+			s.retry.baseDelay = baseDelay
+		}
+	}
+}
+
+// WithOutbox задает outbox событий пользователей для других сервисов
+func WithOutbox(outbox interfaces.OutboxRepository) Option {
+	return func(s *UserService) {
+		s.outbox = outbox
+	}
+}
+
+// WithAuditLogger задает журнал аудита
+func WithAuditLogger(logger interfaces.AuditLogger) Option {
+	return func(s *UserService) {
+		s.auditLog = logger
+	}
+}
+
+// WithPasswordPolicy задает требования к паролям
+func WithPasswordPolicy(policy interfaces.PasswordPolicy) Option {
+	return func(s *UserService) {
+		s.passwordPolicy = policy
+	}
+}
@@ -0,0 +1,17 @@
+package service
+
+import "homecloud-auth-service/internal/models"
+
+// PasswordPolicy возвращает требования к паролю для подсказок клиенту
+func (s *UserService) PasswordPolicy() models.PasswordPolicy {
+	return s.passwordPolicy.Rules()
+}
+
+// checkPassword проверяет пароль по политике; identity - username и email владельца.
+// Пустой пароль уже отклонен правилом required модели запроса
+func (s *UserService) checkPassword(field, password string, identity ...string) error {
+	if password == "" {
+		return nil
+	}
+	return s.passwordPolicy.Check(field, password, identity...)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/mail"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/validation"
+)
+
+// Запрос сброса пароля: письмо со ссылкой на отправку нового пароля.
+// Для неизвестного email и при превышении лимита писем ошибка не возвращается,
+// чтобы ответ не раскрывал наличие аккаунта.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	// Лимит по адресу, а не по аккаунту: иначе по ответу было бы видно, что адрес существует
+	allowed, _, err := s.resendLimiter.Allow(ctx, "reset:"+strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return fmt.Errorf("failed to check password reset limit: %w", err)
+	}
+	if !allowed {
+		return nil
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	token, err := s.security.GeneratePasswordResetToken(user.ID, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to generate password reset token: %w", err)
+	}
+
+	link, err := tokenLink(s.resetURL, token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sendEmail(ctx, user, mail.TemplatePasswordReset, mail.TemplateData{Link: link}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Сброс пароля по токену из письма. Токен действует один раз: nonce погашается
+// до записи пароля, а смена пароля меняет его отпечаток. Все сессии пользователя завершаются.
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	claims, err := s.security.ValidatePasswordResetToken(token)
+	if err != nil {
+		return fmt.Errorf("invalid password reset token: %w", err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("invalid password reset token: %w", errdefs.ErrInvalidToken)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !claims.MatchesPassword(s.security.PasswordFingerprint(user.PasswordHash)) {
+		return fmt.Errorf("password reset token already used: %w", errdefs.ErrInvalidToken)
+	}
+
+	err = validation.Join(
+		validation.Struct(models.ResetPasswordRequest{Token: token, NewPassword: newPassword}),
+		s.checkPassword("new_password", newPassword, user.Username, user.Email),
+	)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hashPassword(ctx, newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash new password: %w", err)
+	}
+
+	// Гасим nonce атомарно: из параллельных запросов с одним токеном пароль сменит только один
+	fresh, err := s.verificationNonces.Consume(ctx, claims.Nonce, claims.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+	if !fresh {
+		return fmt.Errorf("password reset token already used: %w", errdefs.ErrInvalidToken)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	s.recordEvent(ctx, models.EventPasswordChanged, user.ID, map[string]string{"reason": "reset"})
+	s.recordAudit(ctx, models.AuditPasswordReset, user.ID, nil, nil)
+
+	// Владелец почты подтвердил себя - снимаем блокировку после перебора пароля
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		s.repo.UpdateFailedLoginAttempts(ctx, user.ID, 0)
+		s.repo.UpdateLockedUntil(ctx, user.ID, nil)
+	}
+
+	if err := s.LogoutAll(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+
+	s.sendSecurityAlert(ctx, user, "Пароль аккаунта был сброшен по ссылке из письма. Все сессии завершены.")
+	return nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryPolicy - повторы вызовов внешних сервисов
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration // удваивается после каждой неудачной попытки
+}
+
+// do вызывает fn, пока она не завершится успешно, не вернет ошибку, для которой
+// retryable ложно, или не кончатся попытки
+func (p retryPolicy) do(ctx context.Context, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	delay := p.baseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !retryable(err) || attempt >= p.attempts {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// isTransient - сбой связи или перегрузка: повторный вызов может пройти
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// anyError - компенсации идемпотентны, их повторяем при любой ошибке
+func anyError(error) bool { return true }
+
+// sagaStep - шаг саги: действие и компенсация, отменяющая его результат
+type sagaStep struct {
+	name   string
+	action func(ctx context.Context) error
+	// compensate вызывается, если не удался один из следующих шагов. nil - откатывать нечего.
+	compensate func(ctx context.Context) error
+	// Результат действия при ошибке неизвестен (например, таймаут после отправки запроса),
+	// поэтому компенсация нужна и при ошибке самого шага
+	compensateOnFailure bool
+	// onCompensationFailed получает ошибку компенсации после всех повторов,
+	// например, чтобы поставить откат в фоновую очередь
+	onCompensationFailed func(ctx context.Context, err error) error
+}
+
+// runSaga выполняет шаги по порядку. Временные ошибки действий повторяются по retry.
+// При ошибке шага выполненные шаги компенсируются в обратном порядке; компенсации
+// не зависят от отмены ctx, чтобы обрыв запроса клиентом не оставил частичный результат.
+func runSaga(ctx context.Context, retry retryPolicy, steps []sagaStep) error {
+	for i, step := range steps {
+		err := retry.do(ctx, isTransient, step.action)
+		if err == nil {
+			continue
+		}
+
+		done := steps[:i]
+		if step.compensateOnFailure {
+			done = steps[:i+1]
+		}
+		compensationErr := compensate(context.WithoutCancel(ctx), retry, done)
+		stepErr := fmt.Errorf("%s: %w", step.name, err)
+		if compensationErr != nil {
+			return errors.Join(stepErr, compensationErr)
+		}
+		return stepErr
+	}
+	return nil
+}
+
+func compensate(ctx context.Context, retry retryPolicy, steps []sagaStep) error {
+	var errs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if step.compensate == nil {
+			continue
+		}
+
+		err := retry.do(ctx, anyError, step.compensate)
+		if err != nil && step.onCompensationFailed != nil {
+			err = step.onCompensationFailed(ctx, err)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compensate %s: %w", step.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Как часто обновлять время последней активности сессии при проверке токена
+const sessionTouchInterval = time.Minute
+
+// Открытие новой сессии и выдача первой пары токенов.
+// Сведения об устройстве берутся из контекста запроса.
+func (s *UserService) openSession(ctx context.Context, userID uuid.UUID) (*models.TokenPair, error) {
+	client := models.ClientInfoFromContext(ctx)
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.security.RefreshTokenExpiration()),
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueTokens(ctx, userID, session.ID)
+}
+
+// Вход с устройства, которого нет ни в одной сессии пользователя, включая завершенные.
+// Устройство определяется по имени и User-Agent; при ошибке хранилища событие не создается.
+// Первый вход после регистрации новым устройством не считается: сравнивать не с чем.
+func (s *UserService) isNewDevice(ctx context.Context, userID uuid.UUID) bool {
+	client := models.ClientInfoFromContext(ctx)
+	sessions, err := s.sessions.ListByUser(ctx, userID)
+	if err != nil || len(sessions) == 0 {
+		return false
+	}
+	for _, session := range sessions {
+		if session.UserAgent == client.UserAgent && session.DeviceName == client.DeviceName {
+			return false
+		}
+	}
+	return true
+}
+
+// Список активных сессий пользователя
+func (s *UserService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	sessions, err := s.sessions.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	active := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.IsActive() {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// Завершение одной сессии пользователя (например, с потерянного устройства)
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// Чужая сессия неотличима от несуществующей
+	if session.UserID != userID {
+		return errdefs.ErrNotFound
+	}
+
+	if err := s.revokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, models.AuditSessionRevoke, userID, nil, map[string]string{"session_id": sessionID.String()})
+	return nil
+}
+
+// Отзыв сессии и всех refresh токенов её семейства
+func (s *UserService) revokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	if err := s.sessions.Revoke(ctx, sessionID); err != nil && !errdefs.Is(err, errdefs.ErrNotFound) {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if err := s.refreshTokens.RevokeFamily(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// Проверка, что сессия access токена не завершена, и обновление её активности
+func (s *UserService) checkSession(ctx context.Context, sessionID uuid.UUID) error {
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			return fmt.Errorf("session not found: %w", errdefs.ErrInvalidToken)
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.RevokedAt != nil {
+		return fmt.Errorf("session revoked: %w", errdefs.ErrInvalidToken)
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		s.sessions.Touch(ctx, sessionID, now, session.ExpiresAt)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/tracing"
+)
+
+// Хеширование пароля в отдельном спане: bcrypt - самая долгая часть регистрации и входа
+func (s *UserService) hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.hash")
+	hash, err := s.security.HashPassword(password)
+	tracing.End(span, err)
+	return hash, err
+}
+
+// Сравнение пароля с хешем в отдельном спане. Несовпадение пароля - ожидаемый исход, а не ошибка спана
+func (s *UserService) comparePassword(ctx context.Context, hash, password string) error {
+	_, span := tracing.Start(ctx, "bcrypt.compare")
+	err := s.security.ComparePassword(hash, password)
+	span.SetAttributes(attribute.Bool("password.match", err == nil))
+	span.End()
+	return err
+}
+
+// tracedUserService открывает спан на каждый вызов UserService
+type tracedUserService struct {
+	inner interfaces.UserService
+}
+
+// NewTracedUserService оборачивает сервис пользователей трассировкой
+func NewTracedUserService(inner interfaces.UserService) interfaces.UserService {
+	return &tracedUserService{inner: inner}
+}
+
+func (t *tracedUserService) Register(ctx context.Context, email, username, password string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	user, err := t.inner.Register(ctx, email, username, password)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) Login(ctx context.Context, email, password string) (*models.User, *models.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	user, tokens, err := t.inner.Login(ctx, email, password)
+	tracing.End(span, err)
+	return user, tokens, err
+}
+
+func (t *tracedUserService) LoginMFA(ctx context.Context, challengeToken, method, code string) (*models.User, *models.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "UserService.LoginMFA")
+	user, tokens, err := t.inner.LoginMFA(ctx, challengeToken, method, code)
+	tracing.End(span, err)
+	return user, tokens, err
+}
+
+func (t *tracedUserService) ValidateToken(ctx context.Context, token string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.ValidateToken")
+	user, err := t.inner.ValidateToken(ctx, token)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RefreshToken")
+	tokens, err := t.inner.RefreshToken(ctx, refreshToken)
+	tracing.End(span, err)
+	return tokens, err
+}
+
+func (t *tracedUserService) Logout(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.Logout")
+	err := t.inner.Logout(ctx, token)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) LogoutAll(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	ctx, span := tracing.Start(ctx, "UserService.LogoutAll", attribute.String("user.id", userID.String()))
+	err := t.inner.LogoutAll(ctx, userID, before)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "UserService.RequestPasswordReset")
+	err := t.inner.RequestPasswordReset(ctx, email)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ResetPassword")
+	err := t.inner.ResetPassword(ctx, token, newPassword)
+	tracing.End(span, err)
+	return err
+}
+
+// Правила берутся из памяти, отдельный спан не нужен
+func (t *tracedUserService) PasswordPolicy() models.PasswordPolicy {
+	return t.inner.PasswordPolicy()
+}
+
+func (t *tracedUserService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListSessions", attribute.String("user.id", userID.String()))
+	sessions, err := t.inner.ListSessions(ctx, userID)
+	tracing.End(span, err)
+	return sessions, err
+}
+
+func (t *tracedUserService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeSession", attribute.String("user.id", userID.String()))
+	err := t.inner.RevokeSession(ctx, userID, sessionID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	ctx, span := tracing.Start(ctx, "UserService.EnrollTOTP", attribute.String("user.id", userID.String()))
+	secret, otpauthURI, err := t.inner.EnrollTOTP(ctx, userID)
+	tracing.End(span, err)
+	return secret, otpauthURI, err
+}
+
+func (t *tracedUserService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ConfirmTOTP", attribute.String("user.id", userID.String()))
+	err := t.inner.ConfirmTOTP(ctx, userID, code)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) DisableTOTP(ctx context.Context, userID uuid.UUID, password string) error {
+	ctx, span := tracing.Start(ctx, "UserService.DisableTOTP", attribute.String("user.id", userID.String()))
+	err := t.inner.DisableTOTP(ctx, userID, password)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, password string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RegenerateRecoveryCodes", attribute.String("user.id", userID.String()))
+	codes, err := t.inner.RegenerateRecoveryCodes(ctx, userID, password)
+	tracing.End(span, err)
+	return codes, err
+}
+
+func (t *tracedUserService) RecoveryCodesRemaining(ctx context.Context, userID uuid.UUID) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RecoveryCodesRemaining", attribute.String("user.id", userID.String()))
+	remaining, err := t.inner.RecoveryCodesRemaining(ctx, userID)
+	tracing.End(span, err)
+	return remaining, err
+}
+
+func (t *tracedUserService) BeginWebAuthnRegistration(ctx context.Context, userID uuid.UUID) (*models.WebAuthnRegisterBeginResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.BeginWebAuthnRegistration", attribute.String("user.id", userID.String()))
+	options, err := t.inner.BeginWebAuthnRegistration(ctx, userID)
+	tracing.End(span, err)
+	return options, err
+}
+
+func (t *tracedUserService) FinishWebAuthnRegistration(ctx context.Context, userID, challengeID uuid.UUID, name, password string, credential *models.AttestationCredential) (*models.WebAuthnCredential, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FinishWebAuthnRegistration", attribute.String("user.id", userID.String()))
+	stored, err := t.inner.FinishWebAuthnRegistration(ctx, userID, challengeID, name, password, credential)
+	tracing.End(span, err)
+	return stored, err
+}
+
+func (t *tracedUserService) BeginWebAuthnLogin(ctx context.Context, email, mfaToken string) (*models.WebAuthnLoginBeginResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.BeginWebAuthnLogin")
+	options, err := t.inner.BeginWebAuthnLogin(ctx, email, mfaToken)
+	tracing.End(span, err)
+	return options, err
+}
+
+func (t *tracedUserService) FinishWebAuthnLogin(ctx context.Context, challengeID uuid.UUID, assertion *models.AssertionCredential) (*models.User, *models.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FinishWebAuthnLogin")
+	user, tokens, err := t.inner.FinishWebAuthnLogin(ctx, challengeID, assertion)
+	tracing.End(span, err)
+	return user, tokens, err
+}
+
+func (t *tracedUserService) ListWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListWebAuthnCredentials", attribute.String("user.id", userID.String()))
+	credentials, err := t.inner.ListWebAuthnCredentials(ctx, userID)
+	tracing.End(span, err)
+	return credentials, err
+}
+
+func (t *tracedUserService) DeleteWebAuthnCredential(ctx context.Context, userID uuid.UUID, credentialID, password string) error {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteWebAuthnCredential", attribute.String("user.id", userID.String()))
+	err := t.inner.DeleteWebAuthnCredential(ctx, userID, credentialID, password)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) GetUserProfile(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserProfile", attribute.String("user.id", userID.String()))
+	user, err := t.inner.GetUserProfile(ctx, userID)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) UpdateProfile(ctx context.Context, userID uuid.UUID, username *string, oldPassword *string, newPassword *string) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile", attribute.String("user.id", userID.String()))
+	err := t.inner.UpdateProfile(ctx, userID, username, oldPassword, newPassword)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.VerifyEmail")
+	err := t.inner.VerifyEmail(ctx, token)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.SendVerificationEmail", attribute.String("user.id", userID.String()))
+	err := t.inner.SendVerificationEmail(ctx, userID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.ResendVerificationEmail", attribute.String("user.id", userID.String()))
+	err := t.inner.ResendVerificationEmail(ctx, userID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) RequestEmailChange(ctx context.Context, userID uuid.UUID, password, newEmail string) error {
+	ctx, span := tracing.Start(ctx, "UserService.RequestEmailChange", attribute.String("user.id", userID.String()))
+	err := t.inner.RequestEmailChange(ctx, userID, password, newEmail)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) ConfirmEmailChange(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ConfirmEmailChange")
+	err := t.inner.ConfirmEmailChange(ctx, token)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) RevertEmailChange(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.RevertEmailChange")
+	err := t.inner.RevertEmailChange(ctx, token)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) UpdateStorageUsage(ctx context.Context, userID uuid.UUID, usedSpace int64) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateStorageUsage", attribute.String("user.id", userID.String()))
+	err := t.inner.UpdateStorageUsage(ctx, userID, usedSpace)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedUserService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByID", attribute.String("user.id", userID.String()))
+	user, err := t.inner.GetUserByID(ctx, userID)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) AdminUpdateUser(ctx context.Context, userID uuid.UUID, isActive *bool, storageQuota *int64) error {
+	ctx, span := tracing.Start(ctx, "UserService.AdminUpdateUser", attribute.String("user.id", userID.String()))
+	err := t.inner.AdminUpdateUser(ctx, userID, isActive, storageQuota)
+	tracing.End(span, err)
+	return err
+}
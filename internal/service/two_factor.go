@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/security"
+
+	"github.com/google/uuid"
+)
+
+// Второй шаг входа: проверка кода второго фактора по MFA challenge токену
+func (s *UserService) LoginMFA(ctx context.Context, challengeToken, method, code string) (*models.User, *models.TokenPair, error) {
+	challenge, err := s.validateMFAChallenge(ctx, challengeToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !user.CanLogin() {
+		return nil, nil, errdefs.ErrAccountDisabled
+	}
+	// 2FA могли отключить после выдачи challenge токена
+	enabled, err := s.hasSecondFactor(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !enabled {
+		return nil, nil, fmt.Errorf("two-factor authentication is disabled: %w", errdefs.ErrInvalidToken)
+	}
+
+	if method == "" {
+		method = models.MFAMethodTOTP
+	}
+	if err := s.verifySecondFactor(ctx, user.ID, method, code); err != nil {
+		if errdefs.Is(err, errdefs.ErrInvalidMFACode) {
+			s.recordAudit(ctx, models.AuditLogin, user.ID, err, map[string]string{"method": method})
+			metrics.LoginsTotal.WithLabelValues(metrics.LoginBadSecondFactor).Inc()
+			s.registerFailedLogin(ctx, user)
+		}
+		return nil, nil, err
+	}
+	if err := s.consumeMFAChallenge(ctx, challenge.TokenID, challenge.ExpiresAt); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Проверка MFA challenge токена. Токен, по которому уже выполнен вход, отклоняется:
+// иначе его можно было бы повторить для перебора кодов или второго входа.
+func (s *UserService) validateMFAChallenge(ctx context.Context, challengeToken string) (*security.ChallengeClaims, error) {
+	challenge, err := s.twoFactor.ValidateChallengeToken(challengeToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mfa challenge: %w", err)
+	}
+	revoked, err := s.revocations.IsRevoked(ctx, challenge.TokenID, challenge.UserID, challenge.IssuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check mfa challenge: %w", err)
+	}
+	if revoked {
+		return nil, fmt.Errorf("mfa challenge already used: %w", errdefs.ErrInvalidToken)
+	}
+	return challenge, nil
+}
+
+// Погашение MFA challenge токена после успешной проверки второго фактора
+func (s *UserService) consumeMFAChallenge(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if err := s.revocations.RevokeToken(ctx, tokenID, expiresAt); err != nil {
+		return fmt.Errorf("failed to consume mfa challenge: %w", err)
+	}
+	return nil
+}
+
+// Проверка кода второго фактора выбранным способом
+func (s *UserService) verifySecondFactor(ctx context.Context, userID uuid.UUID, method, code string) error {
+	switch method {
+	case models.MFAMethodTOTP:
+		return s.verifyTOTP(ctx, userID, code, true)
+	case models.MFAMethodRecoveryCode:
+		return s.useRecoveryCode(ctx, userID, code)
+	case models.MFAMethodWebAuthn:
+		return errdefs.InvalidField("method", "unsupported", "passkey is verified via /webauthn/login/finish")
+	default:
+		return errdefs.InvalidField("method", "unsupported", "unsupported mfa method %q", method)
+	}
+}
+
+// Способы второго фактора, доступные пользователю. Подсказка для клиента при входе:
+// ошибки хранилища пропускаются, поэтому решения о 2FA по этому списку не принимаются.
+func (s *UserService) mfaMethods(ctx context.Context, userID uuid.UUID) []string {
+	var methods []string
+	if totp, err := s.twoFactorRepo.GetTOTP(ctx, userID); err == nil && totp.Confirmed {
+		methods = append(methods, models.MFAMethodTOTP)
+	}
+	if credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID); err == nil && len(credentials) > 0 {
+		methods = append(methods, models.MFAMethodWebAuthn)
+	}
+	if count, err := s.twoFactorRepo.CountRecoveryCodes(ctx, userID); err == nil && count > 0 {
+		methods = append(methods, models.MFAMethodRecoveryCode)
+	}
+	return methods
+}
+
+// 2FA включена, пока у пользователя есть подтвержденный TOTP или passkey. Состояние
+// берется из хранилищ сервиса авторизации, а не из DB manager.
+// Ошибка хранилища возвращается, а не считается отсутствием факторов.
+func (s *UserService) hasSecondFactor(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	if err == nil && totp.Confirmed {
+		return true, nil
+	}
+
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	return len(credentials) > 0, nil
+}
+
+// Уборка после изменения факторов: без второго фактора резервные коды теряют смысл
+// и удаляются. Если факторы не удалось прочитать, ничего не меняется.
+func (s *UserService) syncTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	enabled, err := s.hasSecondFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return nil
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+// Подтверждение чувствительного действия текущим паролем: одного access токена
+// недостаточно, чтобы менять факторы входа
+func (s *UserService) confirmPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if err := s.comparePassword(ctx, user.PasswordHash, password); err != nil {
+		return errdefs.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Проверка TOTP кода. Принятый код запоминается и повторно не принимается.
+func (s *UserService) verifyTOTP(ctx context.Context, userID uuid.UUID, code string, requireConfirmed bool) error {
+	stored, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			return errdefs.ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	if requireConfirmed && !stored.Confirmed {
+		return errdefs.ErrInvalidMFACode
+	}
+
+	secret, err := s.twoFactor.DecryptSecret(stored.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	step, ok := s.twoFactor.ValidateTOTPCode(secret, code, time.Now())
+	if !ok {
+		return errdefs.ErrInvalidMFACode
+	}
+
+	fresh, err := s.twoFactorRepo.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to store totp step: %w", err)
+	}
+	if !fresh {
+		return errdefs.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// Начало подключения TOTP: новый секрет и otpauth:// ссылка для приложения.
+// 2FA включается только после подтверждения кодом (ConfirmTOTP).
+func (s *UserService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("user not found: %w", err)
+	}
+	if existing, err := s.twoFactorRepo.GetTOTP(ctx, userID); err == nil && existing.Confirmed {
+		return "", "", errdefs.Public(errdefs.ErrConflict, "auth.totp_already_enabled", "totp is already enabled")
+	}
+
+	secret, err := s.twoFactor.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := s.twoFactor.EncryptSecret(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	// Повторный вызов до подтверждения заменяет неподтвержденный секрет
+	err = s.twoFactorRepo.SaveTOTP(ctx, &models.TOTPSecret{
+		UserID:    userID,
+		Secret:    encrypted,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save totp secret: %w", err)
+	}
+
+	return secret, s.twoFactor.TOTPProvisioningURI(user.Email, secret), nil
+}
+
+// Подтверждение подключения TOTP первым кодом из приложения
+func (s *UserService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) error {
+	stored, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("totp enrollment not started: %w", err)
+	}
+	if stored.Confirmed {
+		return errdefs.Public(errdefs.ErrConflict, "auth.totp_already_confirmed", "totp already confirmed")
+	}
+
+	if err := s.verifyTOTP(ctx, userID, code, false); err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepo.ConfirmTOTP(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+
+	return s.syncTwoFactor(ctx, userID)
+}
+
+// Отключение TOTP. Требует текущий пароль пользователя.
+// Если других факторов не осталось, 2FA выключается.
+func (s *UserService) DisableTOTP(ctx context.Context, userID uuid.UUID, password string) error {
+	if err := s.confirmPassword(ctx, userID, password); err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepo.DeleteTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete totp secret: %w", err)
+	}
+
+	return s.syncTwoFactor(ctx, userID)
+}
+
+// Новый набор резервных кодов. Предыдущий набор перестает действовать.
+// Коды возвращаются один раз, на сервере хранятся только их хеши. Коды обходят
+// второй фактор, поэтому, как и отключение TOTP, требуют текущий пароль.
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, password string) ([]string, error) {
+	if err := s.confirmPassword(ctx, userID, password); err != nil {
+		return nil, err
+	}
+	enabled, err := s.hasSecondFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, errdefs.Public(errdefs.ErrConflict, "auth.2fa_not_enabled", "two-factor authentication is not enabled")
+	}
+
+	codes, err := s.twoFactor.GenerateRecoveryCodes(models.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, s.twoFactor.HashRecoveryCode(code))
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// Количество неиспользованных резервных кодов
+func (s *UserService) RecoveryCodesRemaining(ctx context.Context, userID uuid.UUID) (int, error) {
+	count, err := s.twoFactorRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// Вход по резервному коду вместо TOTP. Использованный код удаляется из набора.
+func (s *UserService) useRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error {
+	used, err := s.twoFactorRepo.UseRecoveryCode(ctx, userID, s.twoFactor.HashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if !used {
+		return errdefs.ErrInvalidMFACode
+	}
+	return nil
+}
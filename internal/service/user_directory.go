@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Пауза между фоновыми попытками удалить директорию растет до orphanRetryMaxDelay
+const (
+	orphanRetryBaseDelay = time.Minute
+	orphanRetryMaxDelay  = time.Hour
+)
+
+// Шаги регистрации: домашняя директория в файловом сервисе, затем запись в БД.
+// Если запись не удалась, директория удаляется.
+func (s *UserService) registrationSteps(user *models.User) []sagaStep {
+	return []sagaStep{
+		{
+			name: "create user directory",
+			action: func(ctx context.Context) error {
+				success, message, directoryPath, err := s.fileService.CreateUserDirectory(ctx, user.ID.String(), user.Username)
+				if err != nil {
+					return err
+				}
+				if !success {
+					return fmt.Errorf("file service returned failure: %s", message)
+				}
+				logger.GetLoggerFromCtx(ctx).Debug(ctx, "User directory created", zap.String("path", directoryPath))
+				return nil
+			},
+			compensate: func(ctx context.Context) error {
+				return s.deleteUserDirectory(ctx, user.ID)
+			},
+			compensateOnFailure: true,
+			onCompensationFailed: func(ctx context.Context, err error) error {
+				return s.queueOrphanDirectory(ctx, user.ID, err)
+			},
+		},
+		{
+			name: "create user",
+			action: func(ctx context.Context) error {
+				userID, err := s.repo.CreateUser(ctx, user)
+				if err == nil {
+					user.ID = userID
+					return nil
+				}
+				// Ответ мог потеряться после записи: такой пользователь уже создан,
+				// и удалять его директорию нельзя
+				if _, getErr := s.repo.GetUserByID(ctx, user.ID); getErr == nil {
+					return nil
+				}
+				return err
+			},
+		},
+	}
+}
+
+func (s *UserService) deleteUserDirectory(ctx context.Context, userID uuid.UUID) error {
+	success, message, err := s.fileService.DeleteUserDirectory(ctx, userID.String())
+	if err != nil {
+		return err
+	}
+	if !success {
+		return fmt.Errorf("file service returned failure: %s", message)
+	}
+	return nil
+}
+
+// Директорию не удалось удалить сразу - удаление продолжится в фоне
+func (s *UserService) queueOrphanDirectory(ctx context.Context, userID uuid.UUID, cause error) error {
+	logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to delete directory of unregistered user, queued for retry",
+		zap.Stringer("user_id", userID), zap.Error(cause))
+
+	now := time.Now()
+	err := s.orphanDirectories.Save(ctx, &models.OrphanDirectory{
+		UserID:        userID,
+		Attempts:      1,
+		LastError:     cause.Error(),
+		CreatedAt:     now,
+		NextAttemptAt: now.Add(orphanRetryBaseDelay),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue orphan directory %s: %w", userID, err)
+	}
+	return nil
+}
+
+// RetryOrphanDirectories повторяет удаление директорий, оставшихся от неудачных
+// регистраций. Возвращает число удаленных директорий.
+func (s *UserService) RetryOrphanDirectories(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.orphanDirectories.ListDue(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list orphan directories: %w", err)
+	}
+
+	deleted := 0
+	for _, directory := range due {
+		// Пользователь все-таки создан - директория ему нужна. Если БД недоступна,
+		// удаление откладывается до следующего запуска.
+		_, err := s.repo.GetUserByID(ctx, directory.UserID)
+		if err == nil {
+			s.orphanDirectories.Delete(ctx, directory.UserID)
+			continue
+		}
+		if !isNotFound(err) {
+			continue
+		}
+
+		if err := s.deleteUserDirectory(ctx, directory.UserID); err != nil {
+			delay := orphanRetryBaseDelay << min(directory.Attempts, 6)
+			directory.Attempts++
+			directory.LastError = err.Error()
+			directory.NextAttemptAt = now.Add(min(delay, orphanRetryMaxDelay))
+			if err := s.orphanDirectories.Save(ctx, directory); err != nil {
+				return deleted, fmt.Errorf("failed to update orphan directory %s: %w", directory.UserID, err)
+			}
+			continue
+		}
+
+		if err := s.orphanDirectories.Delete(ctx, directory.UserID); err != nil {
+			return deleted, fmt.Errorf("failed to delete orphan directory record %s: %w", directory.UserID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// isNotFound - записи точно нет (ошибка репозитория или код gRPC сервиса БД)
+func isNotFound(err error) bool {
+	return errdefs.Is(err, errdefs.ErrNotFound) || status.Code(err) == codes.NotFound
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+	"homecloud-auth-service/internal/security"
+)
+
+// fakeFileService - файловый сервис с управляемыми ошибками
+type fakeFileService struct {
+	mu          sync.Mutex
+	createErrs  []error // ошибки очередных вызовов CreateUserDirectory
+	deleteErrs  []error // ошибки очередных вызовов DeleteUserDirectory
+	directories map[string]bool
+	createCalls int
+	deleteCalls int
+}
+
+func newFakeFileService() *fakeFileService {
+	return &fakeFileService{directories: make(map[string]bool)}
+}
+
+func nextErr(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func (f *fakeFileService) CreateUserDirectory(ctx context.Context, userID, username string) (bool, string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.createCalls++
+	if err := nextErr(&f.createErrs); err != nil {
+		return false, "", "", err
+	}
+	f.directories[userID] = true
+	return true, "created", "/home/users/" + userID, nil
+}
+
+func (f *fakeFileService) DeleteUserDirectory(ctx context.Context, userID string) (bool, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleteCalls++
+	if err := nextErr(&f.deleteErrs); err != nil {
+		return false, "", err
+	}
+	delete(f.directories, userID)
+	return true, "deleted", nil
+}
+
+func (f *fakeFileService) Close() error { return nil }
+
+func (f *fakeFileService) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.directories)
+}
+
+// failingUserRepository - CreateUser возвращает ошибку; при commit запись все равно сохраняется
+type failingUserRepository struct {
+	*fakeUserRepository
+	err    error
+	commit bool
+}
+
+func (r *failingUserRepository) CreateUser(ctx context.Context, user *models.User) (uuid.UUID, error) {
+	if r.commit {
+		r.fakeUserRepository.CreateUser(ctx, user)
+	}
+	return uuid.Nil, r.err
+}
+
+func newSagaTestService(t *testing.T, repo interfaces.UserRepository, files *fakeFileService, opts ...Option) *UserService {
+	t.Helper()
+	sec := security.NewSecurity("test-secret", nil, time.Minute, time.Hour, "test-verification-secret", time.Hour, "test-reset-secret", time.Hour)
+	opts = append([]Option{WithRetry(3, time.Millisecond)}, opts...)
+	return NewUserService(repo, sec, files, opts...)
+}
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+func TestRegisterRetriesTransientErrors(t *testing.T) {
+	files := newFakeFileService()
+	files.createErrs = []error{errUnavailable}
+	svc := newSagaTestService(t, newFakeUserRepository(), files)
+
+	_, err := svc.Register(context.Background(), "retry@example.com", "retry", "password123")
+	require.NoError(t, err)
+	assert.Equal(t, 2, files.createCalls)
+	assert.Equal(t, 1, files.count())
+}
+
+func TestRegisterRemovesDirectoryWhenUserNotCreated(t *testing.T) {
+	files := newFakeFileService()
+	repo := &failingUserRepository{fakeUserRepository: newFakeUserRepository(), err: errors.New("duplicate key")}
+	svc := newSagaTestService(t, repo, files)
+
+	_, err := svc.Register(context.Background(), "fail@example.com", "fail", "password123")
+	require.Error(t, err)
+	assert.Zero(t, files.count())
+	assert.Equal(t, 1, files.deleteCalls)
+}
+
+func TestRegisterKeepsDirectoryWhenUserWrittenDespiteError(t *testing.T) {
+	files := newFakeFileService()
+	repo := &failingUserRepository{fakeUserRepository: newFakeUserRepository(), err: errUnavailable, commit: true}
+	svc := newSagaTestService(t, repo, files)
+
+	user, err := svc.Register(context.Background(), "lost@example.com", "lost", "password123")
+	require.NoError(t, err)
+	assert.Equal(t, 1, files.count())
+	assert.Zero(t, files.deleteCalls)
+
+	_, err = repo.GetUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+}
+
+func TestRegisterQueuesOrphanDirectory(t *testing.T) {
+	ctx := context.Background()
+	files := newFakeFileService()
+	files.deleteErrs = []error{errUnavailable, errUnavailable, errUnavailable}
+	repo := &failingUserRepository{fakeUserRepository: newFakeUserRepository(), err: errors.New("database is read-only")}
+	orphans := repository.NewMemoryOrphanDirectoryRepository()
+	svc := newSagaTestService(t, repo, files, WithOrphanDirectoryRepository(orphans))
+
+	_, err := svc.Register(ctx, "orphan@example.com", "orphan", "password123")
+	require.Error(t, err)
+	assert.Equal(t, 1, files.count())
+
+	// До времени следующей попытки директория не трогается
+	deleted, err := svc.RetryOrphanDirectories(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	queued, err := orphans.ListDue(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	assert.Equal(t, 1, queued[0].Attempts)
+
+	deleted, err = svc.RetryOrphanDirectories(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Zero(t, files.count())
+
+	queued, err = orphans.ListDue(ctx, time.Now().Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+}
@@ -3,14 +3,24 @@ package service
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"homecloud-auth-service/internal/audit"
+	"homecloud-auth-service/internal/errdefs"
 	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/mail"
+	"homecloud-auth-service/internal/metrics"
 	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+	"homecloud-auth-service/internal/security"
 	"homecloud-auth-service/internal/transport/grpc/fileClient"
+	"homecloud-auth-service/internal/validation"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // Использованные ошибки -
@@ -23,53 +33,117 @@ import (
 // ErrExpiredToken - токен просрочен
 // ErrInvalidCredentials - неправильные данные
 // ErrEmailAlreadyExists - email уже существует
+// ErrTokenReused - повторное использование refresh токена
+// ErrMFARequired - нужен второй фактор (MFARequiredError с challenge токеном)
+// ErrInvalidMFACode - неверный код второго фактора
 
 type UserService struct {
-	repo        interfaces.UserRepository
-	security    interfaces.Security
-	fileService fileClient.FileServiceClient
+	repo          interfaces.UserRepository
+	security      interfaces.Security
+	fileService   fileClient.FileServiceClient
+	refreshTokens interfaces.RefreshTokenRepository
+	revocations   interfaces.TokenRevocationStore
+	sessions      interfaces.SessionRepository
+	twoFactorRepo interfaces.TwoFactorRepository
+	twoFactor     interfaces.TwoFactor
+	webAuthnRepo  interfaces.WebAuthnRepository
+	webAuthn      interfaces.WebAuthn
+	mailer        interfaces.Mailer
+	verifyURL     string
+	resetURL      string
+	// Ссылки из писем смены email: подтверждение нового адреса и отмена со старого
+	emailChangeURL string
+	emailRevertURL string
+	resendLimiter  interfaces.RateLimiter
+	// Использованные токены верификации email
+	verificationNonces interfaces.NonceStore
+	// Директории, которые не удалось удалить при откате регистрации
+	orphanDirectories interfaces.OrphanDirectoryRepository
+	// Повторы вызовов файлового сервиса и БД при регистрации
+	retry retryPolicy
+	// События для других сервисов
+	outbox interfaces.OutboxRepository
+	// Журнал аудита действий, связанных с безопасностью
+	auditLog interfaces.AuditLogger
+	// Требования к паролям
+	passwordPolicy interfaces.PasswordPolicy
 }
 
-func NewUserService(repo interfaces.UserRepository, security interfaces.Security, fileService fileClient.FileServiceClient) *UserService {
-	return &UserService{
-		repo:        repo,
-		security:    security,
-		fileService: fileService,
+func NewUserService(repo interfaces.UserRepository, sec interfaces.Security, fileService fileClient.FileServiceClient, opts ...Option) *UserService {
+	s := &UserService{
+		repo:           repo,
+		security:       sec,
+		fileService:    fileService,
+		refreshTokens:  repository.NewMemoryRefreshTokenRepository(),
+		revocations:    repository.NewMemoryTokenRevocationStore(),
+		sessions:       repository.NewMemorySessionRepository(),
+		twoFactorRepo:  repository.NewMemoryTwoFactorRepository(),
+		twoFactor:      security.NewEphemeralTwoFactor("HomeCloud", 5*time.Minute),
+		webAuthnRepo:   repository.NewMemoryWebAuthnRepository(),
+		webAuthn:       security.NewWebAuthn("localhost", "HomeCloud", []string{"http://localhost:8080"}),
+		mailer:         mail.NewLogMailer(),
+		verifyURL:      "http://localhost:8080/api/v1/auth/verify",
+		resetURL:       "http://localhost:8080/reset-password",
+		emailChangeURL: "http://localhost:8080/api/v1/auth/email/confirm",
+		emailRevertURL: "http://localhost:8080/api/v1/auth/email/revert",
+		resendLimiter:  repository.NewMemoryRateLimiter(3, time.Hour),
+
+		verificationNonces: repository.NewMemoryNonceStore(),
+		orphanDirectories:  repository.NewMemoryOrphanDirectoryRepository(),
+		retry:              retryPolicy{attempts: 3, baseDelay: 200 * time.Millisecond},
+		outbox:             repository.NewMemoryOutboxRepository(),
+		auditLog:           audit.NewLogger(repository.NewMemoryAuditRepository()),
+		passwordPolicy:     security.NewPasswordPolicy(models.PasswordPolicy{MinLength: 6}, nil),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Регистрация нового пользователя. Сессия не открывается: токены выдает вход
+func (s *UserService) Register(ctx context.Context, email, username, password string) (*models.User, error) {
+	user, err := s.register(ctx, email, username, password)
+	metrics.RegistrationsTotal.WithLabelValues(metrics.Result(err)).Inc()
+	return user, err
 }
 
-// Регистрация нового пользователя
-func (s *UserService) Register(ctx context.Context, email, username, password string) (*models.User, string, error) {
-	fmt.Printf("DEBUG: Register called with email: %s, username: %s\n", email, username)
+func (s *UserService) register(ctx context.Context, email, username, password string) (*models.User, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Debug(ctx, "Register called", zap.String("email", email), zap.String("username", username))
 
 	// Валидация входных данных
-	if err := s.validateRegistrationData(email, username, password); err != nil {
-		fmt.Printf("DEBUG: Validation failed: %v\n", err)
-		return nil, "", err
+	err := validation.Join(
+		validation.Struct(models.RegisterRequest{Email: email, Username: username, Password: password}),
+		s.checkPassword("password", password, username, email),
+	)
+	if err != nil {
+		lg.Debug(ctx, "Registration validation failed", zap.Error(err))
+		return nil, err
 	}
 
 	// Проверка существования email
 	emailExists, err := s.repo.CheckEmailExists(ctx, email)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to check email existence: %w", err)
+		return nil, fmt.Errorf("failed to check email existence: %w", err)
 	}
 	if emailExists {
-		return nil, "", fmt.Errorf("email already exists")
+		return nil, errdefs.Public(errdefs.ErrConflict, "auth.email_taken", "email already exists")
 	}
 
 	// Проверка существования username
 	usernameExists, err := s.repo.CheckUsernameExists(ctx, username)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to check username existence: %w", err)
+		return nil, fmt.Errorf("failed to check username existence: %w", err)
 	}
 	if usernameExists {
-		return nil, "", fmt.Errorf("username already exists")
+		return nil, errdefs.Public(errdefs.ErrConflict, "auth.username_taken", "username already exists")
 	}
 
 	// Хеширование пароля
-	passwordHash, err := s.security.HashPassword(password)
+	passwordHash, err := s.hashPassword(ctx, password)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to hash password: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	// Создание пользователя
@@ -91,76 +165,122 @@ func (s *UserService) Register(ctx context.Context, email, username, password st
 
 	// Создание домашней директории для пользователя (обязательно)
 	if s.fileService == nil {
-		return nil, "", fmt.Errorf("file service is not available - cannot create user directory")
-	}
-
-	// Сначала создаем папку пользователя
-	success, message, directoryPath, err := s.fileService.CreateUserDirectory(ctx, user.ID.String(), username)
-	if err != nil {
-		fmt.Printf("ERROR: Failed to create user directory: %v\n", err)
-		return nil, "", fmt.Errorf("failed to create user directory: %w", err)
+		return nil, fmt.Errorf("file service is not available - cannot create user directory: %w", errdefs.ErrUnavailable)
 	}
 
-	if !success {
-		fmt.Printf("ERROR: File service returned failure: %s\n", message)
-		return nil, "", fmt.Errorf("failed to create user directory: %s", message)
+	// Сначала создаем папку пользователя, затем пользователя в базе данных.
+	// Если запись в БД не удалась, папка удаляется.
+	if err := runSaga(ctx, s.retry, s.registrationSteps(user)); err != nil {
+		lg.Error(ctx, "Registration failed", zap.String("email", email), zap.Error(err))
+		return nil, fmt.Errorf("failed to register user: %w", err)
 	}
 
-	fmt.Printf("DEBUG: User directory created successfully: %s\n", directoryPath)
-
-	// Теперь создаем пользователя в базе данных
-	userID, err := s.repo.CreateUser(ctx, user)
-	if err != nil {
-		fmt.Printf("ERROR: Failed to create user in database after directory creation: %v\n", err)
-		// TODO: Здесь можно добавить логику удаления созданной папки при неудаче создания пользователя
-		return nil, "", fmt.Errorf("failed to create user: %w", err)
-	}
+	s.recordEvent(ctx, models.EventUserRegistered, user.ID, map[string]string{
+		"email":    user.Email,
+		"username": user.Username,
+	})
 
-	// Генерация JWT токена
-	token, err := s.security.GenerateToken(userID)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	// Письмо с подтверждением email. Регистрация не откатывается, если письмо
+	// не ушло: ссылку можно запросить повторно.
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		lg.Error(ctx, "Failed to send verification email", zap.String("email", user.Email), zap.Error(err))
 	}
 
-	user.ID = userID
-	fmt.Printf("DEBUG: User registered successfully: %s\n", user.Email)
-	return user, token, nil
+	lg.Debug(ctx, "User registered", zap.Stringer("user_id", user.ID))
+	return user, nil
 }
 
 // Аутентификация пользователя
-func (s *UserService) Login(ctx context.Context, email, password string) (*models.User, string, error) {
-	fmt.Printf("DEBUG: Login called with email: %s\n", email)
+func (s *UserService) Login(ctx context.Context, email, password string) (*models.User, *models.TokenPair, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Debug(ctx, "Login called", zap.String("email", email))
 
 	// Получение пользователя по email
 	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		fmt.Printf("DEBUG: User not found by email: %s\n", email)
-		return nil, "", fmt.Errorf("invalid credentials")
+		// Недоступность БД не должна выглядеть для клиента как неверный пароль
+		if errdefs.IsUnavailable(err) {
+			return nil, nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		lg.Debug(ctx, "User not found by email", zap.String("email", email))
+		s.recordAudit(ctx, models.AuditLogin, uuid.Nil, fmt.Errorf("unknown email"), map[string]string{"email": email})
+		metrics.LoginsTotal.WithLabelValues(metrics.LoginUnknownUser).Inc()
+		return nil, nil, errdefs.ErrInvalidCredentials
 	}
 
-	fmt.Printf("DEBUG: Found user: %s, password hash: %s\n", user.Email, user.PasswordHash)
-
 	// Проверка активности и блокировки
 	if !user.CanLogin() {
-		fmt.Printf("DEBUG: User cannot login (locked or inactive)\n")
-		return nil, "", fmt.Errorf("account is locked or inactive")
+		lg.Debug(ctx, "User cannot login (locked or inactive)", zap.Stringer("user_id", user.ID))
+		err := errdefs.ErrAccountDisabled
+		s.recordAudit(ctx, models.AuditLogin, user.ID, err, nil)
+		metrics.LoginsTotal.WithLabelValues(loginBlockedOutcome(user)).Inc()
+		return nil, nil, err
 	}
 
 	// Проверка пароля
-	err = s.security.ComparePassword(user.PasswordHash, password)
-	if err != nil {
-		fmt.Printf("DEBUG: Password comparison failed: %v\n", err)
-		// Увеличение счетчика неудачных попыток
-		user.IncrementFailedAttempts()
-		s.repo.UpdateFailedLoginAttempts(ctx, user.ID, user.FailedLoginAttempts)
-		if user.LockedUntil != nil {
-			s.repo.UpdateLockedUntil(ctx, user.ID, user.LockedUntil)
+	err = s.comparePassword(ctx, user.PasswordHash, password)
+	if err != nil {
+		lg.Debug(ctx, "Password comparison failed", zap.Stringer("user_id", user.ID))
+		s.recordAudit(ctx, models.AuditLogin, user.ID, fmt.Errorf("invalid password"), nil)
+		metrics.LoginsTotal.WithLabelValues(metrics.LoginBadPassword).Inc()
+		s.registerFailedLogin(ctx, user)
+		return nil, nil, errdefs.ErrInvalidCredentials
+	}
+
+	// При включенной 2FA токены выдаются только после проверки второго фактора.
+	// Счетчик неудачных попыток не сбрасываем, чтобы он ограничивал и перебор кодов.
+	// Если факторы не удалось прочитать, вход не выполняется.
+	twoFactorEnabled, err := s.hasSecondFactor(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if twoFactorEnabled {
+		challenge, err := s.twoFactor.GenerateChallengeToken(user.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate mfa challenge: %w", err)
+		}
+		return nil, nil, &errdefs.MFARequiredError{
+			ChallengeToken: challenge,
+			Methods:        s.mfaMethods(ctx, user.ID),
 		}
-		return nil, "", fmt.Errorf("invalid credentials")
 	}
 
-	fmt.Printf("DEBUG: Password comparison successful\n")
+	tokens, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lg.Debug(ctx, "Login successful", zap.Stringer("user_id", user.ID))
+	return user, tokens, nil
+}
+
+// Учет неудачной попытки входа и блокировка после превышения лимита
+func (s *UserService) registerFailedLogin(ctx context.Context, user *models.User) {
+	user.IncrementFailedAttempts()
+	s.repo.UpdateFailedLoginAttempts(ctx, user.ID, user.FailedLoginAttempts)
+	if user.LockedUntil != nil {
+		s.repo.UpdateLockedUntil(ctx, user.ID, user.LockedUntil)
+		metrics.LockoutsTotal.Inc()
+		s.recordEvent(ctx, models.EventUserLocked, user.ID, map[string]string{
+			"locked_until": user.LockedUntil.UTC().Format(time.RFC3339),
+		})
+		s.recordAudit(ctx, models.AuditLockout, user.ID, nil, map[string]string{
+			"failed_attempts": strconv.Itoa(user.FailedLoginAttempts),
+			"locked_until":    user.LockedUntil.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// Исход входа для метрик, когда пароль не проверялся
+func loginBlockedOutcome(user *models.User) string {
+	if !user.IsActive {
+		return metrics.LoginInactive
+	}
+	return metrics.LoginLocked
+}
 
+// Завершение успешного входа: сброс неудачных попыток и открытие сессии
+func (s *UserService) completeLogin(ctx context.Context, user *models.User) (*models.TokenPair, error) {
 	// Сброс счетчика неудачных попыток
 	if user.FailedLoginAttempts > 0 {
 		user.ResetFailedAttempts()
@@ -172,125 +292,353 @@ func (s *UserService) Login(ctx context.Context, email, password string) (*model
 	now := time.Now()
 	s.repo.UpdateLastLogin(ctx, user.ID)
 
-	// Генерация JWT токена
-	token, err := s.security.GenerateToken(user.ID)
+	// Проверяется до открытия сессии, иначе новое устройство найдет само себя
+	newDevice := s.isNewDevice(ctx, user.ID)
+
+	// Каждый вход открывает новую сессию (семейство refresh токенов)
+	tokens, err := s.openSession(ctx, user.ID)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
+	if newDevice {
+		client := models.ClientInfoFromContext(ctx)
+		s.recordEvent(ctx, models.EventLoginNewDevice, user.ID, map[string]string{
+			"ip":          client.IP,
+			"user_agent":  client.UserAgent,
+			"device_name": client.DeviceName,
+		})
+	}
+
+	// Вход выполняет сам пользователь, даже если запрос пришел без токена
+	client := models.ClientInfoFromContext(ctx)
+	client.ActorID = user.ID
+	s.recordAudit(models.WithClientInfo(ctx, client), models.AuditLogin, user.ID, nil, map[string]string{
+		"new_device": strconv.FormatBool(newDevice),
+	})
+	metrics.LoginsTotal.WithLabelValues(metrics.LoginSuccess).Inc()
+
 	user.LastLoginAt = &now
-	fmt.Printf("DEBUG: Login successful for user: %s\n", user.Email)
-	return user, token, nil
+	return tokens, nil
 }
 
 // Валидация токена
-func (s *UserService) ValidateToken(ctx context.Context, token string) (*models.User, error) {
+func (s *UserService) ValidateToken(ctx context.Context, token string) (user *models.User, err error) {
+	defer func() { metrics.TokenValidationsTotal.WithLabelValues(metrics.Result(err)).Inc() }()
+
 	claims, err := s.security.ValidateToken(token)
 	if err != nil {
-		return nil, fmt.Errorf("invalid token: %w", err)
+		if errdefs.Is(err, errdefs.ErrExpiredToken) || errdefs.Is(err, errdefs.ErrInvalidToken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrInvalidToken, err)
 	}
 
-	user, err := s.repo.GetUserByID(ctx, claims.UserID)
+	revoked, err := s.revocations.IsRevoked(ctx, claims.TokenID, claims.UserID, claims.IssuedAt)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, fmt.Errorf("token revoked: %w", errdefs.ErrInvalidToken)
+	}
+
+	if claims.SessionID != uuid.Nil {
+		if err := s.checkSession(ctx, claims.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err = s.repo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		// Токен удаленного пользователя недействителен, а сбой БД - не повод разлогинивать
+		if errdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("user not found: %w", errdefs.ErrInvalidToken)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	if !user.IsActive {
-		return nil, fmt.Errorf("user account is inactive")
+		return nil, fmt.Errorf("user account is inactive: %w", errdefs.ErrAccountDisabled)
 	}
 
 	return user, nil
 }
 
-// Выход из системы
+// Обновление пары токенов по refresh токену (ротация).
+// Предъявление уже использованного токена означает его утечку - отзываем всё семейство.
+func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	stored, err := s.refreshTokens.GetByHash(ctx, s.security.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			return nil, errdefs.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if stored.IsRevoked() {
+		return nil, errdefs.ErrInvalidToken
+	}
+	if stored.IsUsed() {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+	if stored.IsExpired() {
+		return nil, errdefs.ErrExpiredToken
+	}
+
+	// Параллельный запрос мог успеть обменять этот же токен
+	marked, err := s.refreshTokens.MarkUsed(ctx, stored.ID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark refresh token as used: %w", err)
+	}
+	if !marked {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !user.CanLogin() {
+		s.revokeSession(ctx, stored.FamilyID)
+		return nil, errdefs.ErrAccountDisabled
+	}
+
+	tokens, err := s.issueTokens(ctx, user.ID, stored.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Продление сессии вместе с новым refresh токеном
+	now := time.Now()
+	s.sessions.Touch(ctx, stored.FamilyID, now, now.Add(s.security.RefreshTokenExpiration()))
+
+	return tokens, nil
+}
+
+// Выход из системы: отзыв access токена и семейства refresh токенов этого входа
 func (s *UserService) Logout(ctx context.Context, token string) error {
-	return s.security.InvalidateToken(token)
+	claims, err := s.security.ValidateToken(token)
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+
+	if err := s.revocations.RevokeToken(ctx, claims.TokenID, claims.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	if claims.SessionID != uuid.Nil {
+		if err := s.revokeSession(ctx, claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	s.recordAudit(ctx, models.AuditLogout, claims.UserID, nil, nil)
+	return nil
+}
+
+// Выход на всех устройствах: отзыв всех токенов пользователя, выданных раньше before.
+// Нулевое или будущее время заменяется текущим.
+func (s *UserService) LogoutAll(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	now := time.Now()
+	if before.IsZero() || before.After(now) {
+		before = now
+	}
+
+	// Запись нужна, пока не истекут все access токены, выданные до before
+	expiresAt := before.Add(s.security.AccessTokenExpiration())
+	if err := s.revocations.RevokeUserTokens(ctx, userID, before, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	if err := s.refreshTokens.RevokeAllForUser(ctx, userID, before); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := s.sessions.RevokeAllForUser(ctx, userID, before); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	s.recordAudit(ctx, models.AuditLogoutAll, userID, nil, nil)
+	return nil
 }
 
 // Получение профиля пользователя
 func (s *UserService) GetUserProfile(ctx context.Context, userID uuid.UUID) (*models.User, error) {
-	return s.repo.GetUserByID(ctx, userID)
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Флаг 2FA не хранится в DB manager и вычисляется по факторам пользователя
+	user.TwoFactorEnabled, err = s.hasSecondFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
 // Обновление профиля пользователя
 func (s *UserService) UpdateProfile(ctx context.Context, userID uuid.UUID, username *string, oldPassword *string, newPassword *string) error {
+	err := validation.Struct(models.UpdateProfileRequest{Username: username, OldPassword: oldPassword, NewPassword: newPassword})
+	if err != nil {
+		return err
+	}
+
 	user, err := s.repo.GetUserByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("user not found: %w", err)
 	}
 
-	// Обновление username
-	if username != nil {
-		if err := s.validateUsername(*username); err != nil {
+	// Все проверки выполняются до первой записи, чтобы профиль не обновился частично
+	var newPasswordHash string
+	if newPassword != nil {
+		if oldPassword == nil {
+			return errdefs.InvalidField("old_password", "required", "old password is required to change password")
+		}
+
+		identity := []string{user.Username, user.Email}
+		if username != nil {
+			identity = append(identity, *username)
+		}
+		if err := s.checkPassword("new_password", *newPassword, identity...); err != nil {
 			return err
 		}
 
+		// Проверка старого пароля
+		err = s.comparePassword(ctx, user.PasswordHash, *oldPassword)
+		if err != nil {
+			err = errdefs.Public(errdefs.ErrInvalidCredentials, "auth.invalid_old_password", "invalid old password")
+			s.recordAudit(ctx, models.AuditPasswordChange, userID, err, nil)
+			return err
+		}
+
+		// Хеширование нового пароля
+		newPasswordHash, err = s.hashPassword(ctx, *newPassword)
+		if err != nil {
+			return fmt.Errorf("failed to hash new password: %w", err)
+		}
+	}
+
+	if username != nil {
 		usernameExists, err := s.repo.CheckUsernameExists(ctx, *username)
 		if err != nil {
 			return fmt.Errorf("failed to check username existence: %w", err)
 		}
 		if usernameExists && *username != user.Username {
-			return fmt.Errorf("username already exists")
+			return errdefs.Public(errdefs.ErrConflict, "auth.username_taken", "username already exists")
 		}
+	}
 
+	// Обновление username
+	if username != nil {
 		err = s.repo.UpdateUsername(ctx, userID, *username)
 		if err != nil {
 			return fmt.Errorf("failed to update username: %w", err)
 		}
+		s.recordEvent(ctx, models.EventUsernameChanged, userID, map[string]string{"username": *username})
+		s.recordAudit(ctx, models.AuditUsernameChange, userID, nil, map[string]string{
+			"old_username": user.Username,
+			"new_username": *username,
+		})
 	}
 
 	// Обновление пароля
 	if newPassword != nil {
-		if oldPassword == nil {
-			return fmt.Errorf("old password is required to change password")
-		}
-
-		// Проверка старого пароля
-		err = s.security.ComparePassword(user.PasswordHash, *oldPassword)
-		if err != nil {
-			return fmt.Errorf("invalid old password")
-		}
-
-		// Валидация нового пароля
-		if err := s.validatePassword(*newPassword); err != nil {
-			return err
-		}
-
-		// Хеширование нового пароля
-		newPasswordHash, err := s.security.HashPassword(*newPassword)
-		if err != nil {
-			return fmt.Errorf("failed to hash new password: %w", err)
-		}
-
 		err = s.repo.UpdatePassword(ctx, userID, newPasswordHash)
 		if err != nil {
 			return fmt.Errorf("failed to update password: %w", err)
 		}
+
+		s.recordEvent(ctx, models.EventPasswordChanged, userID, nil)
+		s.recordAudit(ctx, models.AuditPasswordChange, userID, nil, nil)
+		s.sendSecurityAlert(ctx, user, "Пароль аккаунта был изменен.")
 	}
 
 	return nil
 }
 
-// Верификация email
-func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
-	userID, err := s.security.ValidateVerificationToken(token)
+// Верификация email. Токен одноразовый и действует только для адреса,
+// на который был отправлен.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (err error) {
+	defer func() { metrics.VerificationAttemptsTotal.WithLabelValues(metrics.Result(err)).Inc() }()
+
+	claims, err := s.security.ValidateVerificationToken(token)
 	if err != nil {
 		return fmt.Errorf("invalid verification token: %w", err)
 	}
 
-	err = s.repo.UpdateEmailVerification(ctx, userID, true)
+	user, err := s.repo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return errdefs.ErrVerificationEmailMismatch
+	}
+
+	fresh, err := s.verificationNonces.Consume(ctx, claims.Nonce, claims.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to consume verification token: %w", err)
+	}
+	if !fresh {
+		return errdefs.ErrVerificationTokenUsed
+	}
+
+	err = s.repo.UpdateEmailVerification(ctx, user.ID, true)
 	if err != nil {
 		return fmt.Errorf("failed to update email verification: %w", err)
 	}
+	s.recordEvent(ctx, models.EventEmailVerified, user.ID, map[string]string{"email": user.Email})
+	s.recordAudit(ctx, models.AuditEmailVerify, user.ID, nil, map[string]string{"email": user.Email})
 
 	return nil
 }
 
 // Отправка email для верификации
 func (s *UserService) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
-	// В реальном приложении здесь была бы отправка email
-	// Пока просто возвращаем успех
-	return nil
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if user.IsEmailVerified {
+		return nil
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// Повторная отправка письма для верификации по запросу пользователя.
+// Ограничена по пользователю и по адресу, чтобы ее нельзя было использовать для спама.
+func (s *UserService) ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if user.IsEmailVerified {
+		return errdefs.Public(errdefs.ErrConflict, "auth.email_already_verified", "email is already verified")
+	}
+
+	for _, key := range []string{"user:" + user.ID.String(), "email:" + strings.ToLower(user.Email)} {
+		allowed, retryAfter, err := s.resendLimiter.Allow(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to check resend limit: %w", err)
+		}
+		if !allowed {
+			return &errdefs.RateLimitError{RetryAfter: retryAfter}
+		}
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *UserService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.security.GenerateVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	link, err := tokenLink(s.verifyURL, token)
+	if err != nil {
+		return err
+	}
+	return s.sendEmail(ctx, user, mail.TemplateVerification, mail.TemplateData{Link: link})
 }
 
 // Обновление использования хранилища
@@ -303,35 +651,44 @@ func (s *UserService) GetUserByID(ctx context.Context, userID uuid.UUID) (*model
 	return s.repo.GetUserByID(ctx, userID)
 }
 
-// Валидация данных регистрации
-func (s *UserService) validateRegistrationData(email, username, password string) error {
-	if email == "" || username == "" || password == "" {
-		return fmt.Errorf("all fields are required")
+// Выдача access токена и refresh токена из семейства familyID (сессии)
+func (s *UserService) issueTokens(ctx context.Context, userID, familyID uuid.UUID) (*models.TokenPair, error) {
+	accessToken, err := s.security.GenerateToken(userID, familyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	if !strings.Contains(email, "@") {
-		return fmt.Errorf("invalid email format")
+	refreshToken, err := s.security.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	if len(username) < 3 || len(username) > 50 {
-		return fmt.Errorf("username must be between 3 and 50 characters")
+	now := time.Now()
+	err = s.refreshTokens.Create(ctx, &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: s.security.HashRefreshToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.security.RefreshTokenExpiration()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
-	return s.validatePassword(password)
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.security.AccessTokenExpiration(),
+	}, nil
 }
 
-// Валидация пароля
-func (s *UserService) validatePassword(password string) error {
-	if len(password) < 6 {
-		return fmt.Errorf("password must be at least 6 characters")
+// Отзыв семейства refresh токенов при обнаружении повторного использования
+func (s *UserService) revokeReusedFamily(ctx context.Context, token *models.RefreshToken) error {
+	if err := s.revokeSession(ctx, token.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
 	}
-	return nil
-}
-
-// Валидация username
-func (s *UserService) validateUsername(username string) error {
-	if len(username) < 3 || len(username) > 50 {
-		return fmt.Errorf("username must be between 3 and 50 characters")
-	}
-	return nil
+	logger.GetLoggerFromCtx(ctx).Warn(ctx, "Refresh token reuse detected, family revoked",
+		zap.Stringer("user_id", token.UserID), zap.Stringer("family_id", token.FamilyID))
+	return errdefs.ErrTokenReused
 }
@@ -0,0 +1,1337 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homecloud-auth-service/internal/audit"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/mail"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/repository"
+	"homecloud-auth-service/internal/security"
+	"homecloud-auth-service/internal/transport/grpc/fileClient"
+)
+
+// fakeUserRepository - хранилище пользователей в памяти для тестов сервиса
+type fakeUserRepository struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*models.User
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{users: make(map[uuid.UUID]*models.User)}
+}
+
+func (r *fakeUserRepository) get(id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) CreateUser(ctx context.Context, user *models.User) (uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *user
+	r.users[user.ID] = &copied
+	return user.ID, nil
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(id)
+}
+
+func (r *fakeUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, user := range r.users {
+		if user.Email == email {
+			return r.get(id)
+		}
+	}
+	return nil, errdefs.ErrNotFound
+}
+
+func (r *fakeUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *fakeUserRepository) update(id uuid.UUID, fn func(u *models.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return errdefs.ErrNotFound
+	}
+	fn(user)
+	return nil
+}
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	return r.update(id, func(u *models.User) { u.PasswordHash = passwordHash })
+}
+
+func (r *fakeUserRepository) UpdateUsername(ctx context.Context, id uuid.UUID, username string) error {
+	return r.update(id, func(u *models.User) { u.Username = username })
+}
+
+func (r *fakeUserRepository) UpdateEmailVerification(ctx context.Context, id uuid.UUID, isVerified bool) error {
+	return r.update(id, func(u *models.User) { u.IsEmailVerified = isVerified })
+}
+
+func (r *fakeUserRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	return r.update(id, func(u *models.User) { u.LastLoginAt = &now })
+}
+
+func (r *fakeUserRepository) UpdateFailedLoginAttempts(ctx context.Context, id uuid.UUID, attempts int) error {
+	return r.update(id, func(u *models.User) { u.FailedLoginAttempts = attempts })
+}
+
+func (r *fakeUserRepository) UpdateLockedUntil(ctx context.Context, id uuid.UUID, lockedUntil *time.Time) error {
+	return r.update(id, func(u *models.User) { u.LockedUntil = lockedUntil })
+}
+
+func (r *fakeUserRepository) UpdateStorageUsage(ctx context.Context, id uuid.UUID, usedSpace int64) error {
+	return r.update(id, func(u *models.User) { u.UsedSpace = usedSpace })
+}
+
+func (r *fakeUserRepository) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := r.GetUserByEmail(ctx, email)
+	return err == nil, nil
+}
+
+func (r *fakeUserRepository) CheckUsernameExists(ctx context.Context, username string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newTestUserService(t *testing.T, opts ...Option) (*UserService, *fakeUserRepository) {
+	t.Helper()
+	repo := newFakeUserRepository()
+	sec := security.NewSecurity("test-secret", nil, time.Minute, time.Hour, "test-verification-secret", time.Hour, "test-reset-secret", time.Hour)
+	return NewUserService(repo, sec, fileClient.NewMockFileServiceClient(false), opts...), repo
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "rotation@example.com", "rotation", "password123")
+	require.NoError(t, err)
+
+	_, first, err := svc.Login(ctx, "rotation@example.com", "password123")
+	require.NoError(t, err)
+	require.NotEmpty(t, first.RefreshToken)
+
+	second, err := svc.RefreshToken(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEmpty(t, second.AccessToken)
+
+	third, err := svc.RefreshToken(ctx, second.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEmpty(t, third.RefreshToken)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "reuse@example.com", "reuse", "password123")
+	require.NoError(t, err)
+
+	_, stolen, err := svc.Login(ctx, "reuse@example.com", "password123")
+	require.NoError(t, err)
+	_, other, err := svc.Login(ctx, "reuse@example.com", "password123")
+	require.NoError(t, err)
+
+	rotated, err := svc.RefreshToken(ctx, stolen.RefreshToken)
+	require.NoError(t, err)
+
+	// Повторное предъявление старого токена отзывает всё семейство
+	_, err = svc.RefreshToken(ctx, stolen.RefreshToken)
+	assert.ErrorIs(t, err, errdefs.ErrTokenReused)
+
+	_, err = svc.RefreshToken(ctx, rotated.RefreshToken)
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+
+	// Другие входы пользователя не затрагиваются
+	_, err = svc.RefreshToken(ctx, other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestRefreshTokenUnknown(t *testing.T) {
+	svc, _ := newTestUserService(t)
+
+	_, err := svc.RefreshToken(context.Background(), "unknown-token")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "logout@example.com", "logout", "password123")
+	require.NoError(t, err)
+	_, tokens, err := svc.Login(ctx, "logout@example.com", "password123")
+	require.NoError(t, err)
+
+	_, err = svc.ValidateToken(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Logout(ctx, tokens.AccessToken))
+
+	_, err = svc.ValidateToken(ctx, tokens.AccessToken)
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+
+	_, err = svc.RefreshToken(ctx, tokens.RefreshToken)
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+}
+
+func TestLogoutAllRevokesEarlierTokens(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "everywhere@example.com", "everywhere", "password123")
+	require.NoError(t, err)
+	_, phone, err := svc.Login(ctx, "everywhere@example.com", "password123")
+	require.NoError(t, err)
+	_, laptop, err := svc.Login(ctx, "everywhere@example.com", "password123")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.LogoutAll(ctx, user.ID, time.Now()))
+
+	for _, tokens := range []*models.TokenPair{phone, laptop} {
+		_, err = svc.ValidateToken(ctx, tokens.AccessToken)
+		assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+		_, err = svc.RefreshToken(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+	}
+}
+
+func TestLoginInSameSecondAfterLogoutAll(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "same-second@example.com", "samesecond", "password123")
+	require.NoError(t, err)
+
+	// Вход сразу после "выйти везде" почти всегда попадает в ту же секунду
+	require.NoError(t, svc.LogoutAll(ctx, user.ID, time.Now()))
+	_, tokens, err := svc.Login(ctx, "same-second@example.com", "password123")
+	require.NoError(t, err)
+
+	validated, err := svc.ValidateToken(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	_, err = svc.RefreshToken(ctx, tokens.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestRevokeSessionKillsOnlyThatDevice(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "devices@example.com", "devices", "password123")
+	require.NoError(t, err)
+
+	// Регистрация сессию не открывает
+	sessions, err := svc.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	phoneCtx := models.WithClientInfo(ctx, models.ClientInfo{DeviceName: "phone", UserAgent: "HomeCloud-Android", IP: "10.0.0.2"})
+	_, phone, err := svc.Login(phoneCtx, "devices@example.com", "password123")
+	require.NoError(t, err)
+	_, laptop, err := svc.Login(ctx, "devices@example.com", "password123")
+	require.NoError(t, err)
+
+	sessions, err = svc.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	var phoneSession *models.Session
+	for _, session := range sessions {
+		if session.DeviceName == "phone" {
+			phoneSession = session
+		}
+	}
+	require.NotNil(t, phoneSession)
+	assert.Equal(t, "10.0.0.2", phoneSession.IP)
+
+	// Чужой пользователь не может завершить сессию
+	assert.ErrorIs(t, svc.RevokeSession(ctx, uuid.New(), phoneSession.ID), errdefs.ErrNotFound)
+
+	require.NoError(t, svc.RevokeSession(ctx, user.ID, phoneSession.ID))
+
+	_, err = svc.ValidateToken(ctx, phone.AccessToken)
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+	_, err = svc.RefreshToken(ctx, phone.RefreshToken)
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+
+	_, err = svc.ValidateToken(ctx, laptop.AccessToken)
+	assert.NoError(t, err)
+
+	sessions, err = svc.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+// totpAt вычисляет TOTP код секрета для шага step (RFC 6238)
+func totpAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	svc, repo := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "totp@example.com", "totp", "password123")
+	require.NoError(t, err)
+
+	secret, uri, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, uri, "otpauth://totp/")
+	assert.Contains(t, uri, secret)
+
+	// До подтверждения вход по-прежнему выдает токены
+	_, plain, err := svc.Login(ctx, "totp@example.com", "password123")
+	require.NoError(t, err)
+	require.NotNil(t, plain)
+
+	step := time.Now().Unix() / 30
+	assert.ErrorIs(t, svc.ConfirmTOTP(ctx, user.ID, "000000"), errdefs.ErrInvalidMFACode)
+	require.NoError(t, svc.ConfirmTOTP(ctx, user.ID, totpAt(t, secret, step)))
+
+	profile, err := svc.GetUserProfile(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, profile.TwoFactorEnabled)
+	// DB manager флаг 2FA не хранит: вход опирается только на факторы сервиса авторизации
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.TwoFactorEnabled)
+
+	// Пароль верный - вместо токенов MFA challenge
+	_, tokens, err := svc.Login(ctx, "totp@example.com", "password123")
+	var mfa *errdefs.MFARequiredError
+	require.ErrorAs(t, err, &mfa)
+	assert.Nil(t, tokens)
+	assert.Equal(t, []string{models.MFAMethodTOTP}, mfa.Methods)
+
+	// Код, уже принятый при подтверждении, повторно не принимается
+	_, _, err = svc.LoginMFA(ctx, mfa.ChallengeToken, "", totpAt(t, secret, step))
+	assert.ErrorIs(t, err, errdefs.ErrInvalidMFACode)
+
+	// Access токен не заменяет challenge
+	_, _, err = svc.LoginMFA(ctx, plain.AccessToken, "", totpAt(t, secret, step+1))
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+
+	loggedIn, tokens, err := svc.LoginMFA(ctx, mfa.ChallengeToken, models.MFAMethodTOTP, totpAt(t, secret, step+1))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, loggedIn.ID)
+	_, err = svc.ValidateToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+
+	// Challenge действует для одного входа: повтор отклоняется до проверки кода
+	_, _, err = svc.LoginMFA(ctx, mfa.ChallengeToken, models.MFAMethodTOTP, "000000")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+	_, err = svc.BeginWebAuthnLogin(ctx, "", mfa.ChallengeToken)
+	assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+
+	// Отключение требует пароль
+	assert.ErrorIs(t, svc.DisableTOTP(ctx, user.ID, "wrong-password"), errdefs.ErrInvalidCredentials)
+	require.NoError(t, svc.DisableTOTP(ctx, user.ID, "password123"))
+
+	_, tokens, err = svc.Login(ctx, "totp@example.com", "password123")
+	require.NoError(t, err)
+	assert.NotNil(t, tokens)
+}
+
+// unavailableWebAuthnRepository имитирует сбой хранилища passkey при чтении
+type unavailableWebAuthnRepository struct {
+	*repository.MemoryWebAuthnRepository
+}
+
+func (r unavailableWebAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	return nil, errdefs.ErrUnavailable
+}
+
+func TestTwoFactorKeptWhenFactorLookupFails(t *testing.T) {
+	svc, _ := newTestUserService(t,
+		WithWebAuthnRepository(unavailableWebAuthnRepository{repository.NewMemoryWebAuthnRepository()}))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "flaky@example.com", "flaky", "password123")
+	require.NoError(t, err)
+	secret, _, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.ConfirmTOTP(ctx, user.ID, totpAt(t, secret, time.Now().Unix()/30)))
+	_, err = svc.RegenerateRecoveryCodes(ctx, user.ID, "password123")
+	require.NoError(t, err)
+
+	// Неизвестно, остались ли passkey: 2FA и резервные коды не трогаются
+	assert.ErrorIs(t, svc.DisableTOTP(ctx, user.ID, "password123"), errdefs.ErrUnavailable)
+
+	remaining, err := svc.RecoveryCodesRemaining(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecoveryCodeCount, remaining)
+
+	// Без ответа хранилища вход не выдает токены в обход второго фактора
+	_, tokens, err := svc.Login(ctx, "flaky@example.com", "password123")
+	assert.ErrorIs(t, err, errdefs.ErrUnavailable)
+	assert.Nil(t, tokens)
+}
+
+func TestPasskeyChangesRequirePassword(t *testing.T) {
+	webAuthnRepo := repository.NewMemoryWebAuthnRepository()
+	svc, _ := newTestUserService(t, WithWebAuthnRepository(webAuthnRepo))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "passkey@example.com", "passkey", "password123")
+	require.NoError(t, err)
+	require.NoError(t, webAuthnRepo.CreateCredential(ctx, &models.WebAuthnCredential{
+		ID: "cred-1", UserID: user.ID, Name: "laptop", CreatedAt: time.Now(),
+	}))
+	_, err = svc.RegenerateRecoveryCodes(ctx, user.ID, "password123")
+	require.NoError(t, err)
+
+	// Одного access токена недостаточно, чтобы добавить способ входа
+	begin, err := svc.BeginWebAuthnRegistration(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = svc.FinishWebAuthnRegistration(ctx, user.ID, begin.ChallengeID, "phone", "wrong-password", &models.AttestationCredential{})
+	assert.ErrorIs(t, err, errdefs.ErrInvalidCredentials)
+
+	// ... или снять последний второй фактор
+	assert.ErrorIs(t, svc.DeleteWebAuthnCredential(ctx, user.ID, "cred-1", "wrong-password"), errdefs.ErrInvalidCredentials)
+	_, err = webAuthnRepo.GetCredential(ctx, "cred-1")
+	require.NoError(t, err)
+	remaining, err := svc.RecoveryCodesRemaining(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecoveryCodeCount, remaining)
+
+	require.NoError(t, svc.DeleteWebAuthnCredential(ctx, user.ID, "cred-1", "password123"))
+	remaining, err = svc.RecoveryCodesRemaining(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
+
+func TestTwoFactorFailedCodesLockAccount(t *testing.T) {
+	svc, repo := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "lock@example.com", "lock", "password123")
+	require.NoError(t, err)
+	secret, _, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.ConfirmTOTP(ctx, user.ID, totpAt(t, secret, time.Now().Unix()/30)))
+
+	// Новый challenge не сбрасывает счетчик неудачных попыток
+	for i := 0; i < 5; i++ {
+		_, _, err := svc.Login(ctx, "lock@example.com", "password123")
+		var mfa *errdefs.MFARequiredError
+		require.ErrorAs(t, err, &mfa)
+		_, _, err = svc.LoginMFA(ctx, mfa.ChallengeToken, "", "000000")
+		require.ErrorIs(t, err, errdefs.ErrInvalidMFACode)
+	}
+
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.CanLogin())
+}
+
+func TestRecoveryCodeLogin(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "recovery@example.com", "recovery", "password123")
+	require.NoError(t, err)
+
+	// Резервные коды выдаются только при включенной 2FA
+	_, err = svc.RegenerateRecoveryCodes(ctx, user.ID, "password123")
+	assert.ErrorIs(t, err, errdefs.ErrConflict)
+
+	secret, _, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.ConfirmTOTP(ctx, user.ID, totpAt(t, secret, time.Now().Unix()/30)))
+
+	// Без пароля украденный access токен не выпустит коды в обход второго фактора
+	_, err = svc.RegenerateRecoveryCodes(ctx, user.ID, "wrong-password")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidCredentials)
+
+	old, err := svc.RegenerateRecoveryCodes(ctx, user.ID, "password123")
+	require.NoError(t, err)
+	codes, err := svc.RegenerateRecoveryCodes(ctx, user.ID, "password123")
+	require.NoError(t, err)
+	require.Len(t, codes, models.RecoveryCodeCount)
+
+	login := func() string {
+		_, _, err := svc.Login(ctx, "recovery@example.com", "password123")
+		var mfa *errdefs.MFARequiredError
+		require.ErrorAs(t, err, &mfa)
+		return mfa.ChallengeToken
+	}
+
+	// Коды предыдущего набора больше не действуют
+	_, _, err = svc.LoginMFA(ctx, login(), models.MFAMethodRecoveryCode, old[0])
+	assert.ErrorIs(t, err, errdefs.ErrInvalidMFACode)
+
+	// Регистр и дефис при вводе не важны
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	_, tokens, err := svc.LoginMFA(ctx, login(), models.MFAMethodRecoveryCode, typed)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	// Код одноразовый
+	_, _, err = svc.LoginMFA(ctx, login(), models.MFAMethodRecoveryCode, codes[0])
+	assert.ErrorIs(t, err, errdefs.ErrInvalidMFACode)
+
+	remaining, err := svc.RecoveryCodesRemaining(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecoveryCodeCount-1, remaining)
+
+	require.NoError(t, svc.DisableTOTP(ctx, user.ID, "password123"))
+	remaining, err = svc.RecoveryCodesRemaining(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
+
+// outboxMailer запоминает отправленные письма
+type outboxMailer struct {
+	mu       sync.Mutex
+	messages []*models.EmailMessage
+}
+
+func (m *outboxMailer) Send(ctx context.Context, message *models.EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *outboxMailer) last(t *testing.T) *models.EmailMessage {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	require.NotEmpty(t, m.messages)
+	return m.messages[len(m.messages)-1]
+}
+
+func linkTokenFrom(t *testing.T, message *models.EmailMessage) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(message.Text, "token=")
+	require.True(t, ok, "link with token not found in %q", message.Text)
+	token, _, _ := strings.Cut(rest, "\n")
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, _ := newTestUserService(t, WithMailer(mailer), WithPasswordResetURL("https://cloud.example.com/reset"))
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "reset@example.com", "reset", "password123")
+	require.NoError(t, err)
+	_, session, err := svc.Login(ctx, "reset@example.com", "password123")
+	require.NoError(t, err)
+
+	// Для неизвестного email письмо не отправляется, но и ошибки нет
+	sent := len(mailer.messages)
+	require.NoError(t, svc.RequestPasswordReset(ctx, "nobody@example.com"))
+	assert.Len(t, mailer.messages, sent)
+
+	require.NoError(t, svc.RequestPasswordReset(ctx, "reset@example.com"))
+	message := mailer.last(t)
+	assert.Equal(t, "reset@example.com", message.To)
+	assert.Contains(t, message.Text, "https://cloud.example.com/reset?token=")
+	token := linkTokenFrom(t, message)
+
+	err = svc.ResetPassword(ctx, token, "123")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidInput)
+
+	require.NoError(t, svc.ResetPassword(ctx, token, "new-password123"))
+
+	// Токен одноразовый, старые сессии завершены
+	assert.ErrorIs(t, svc.ResetPassword(ctx, token, "other-password123"), errdefs.ErrInvalidToken)
+	_, err = svc.ValidateToken(ctx, session.AccessToken)
+	assert.Error(t, err)
+	_, err = svc.RefreshToken(ctx, session.RefreshToken)
+	assert.Error(t, err)
+
+	_, _, err = svc.Login(ctx, "reset@example.com", "password123")
+	assert.Error(t, err)
+	_, _, err = svc.Login(ctx, "reset@example.com", "new-password123")
+	assert.NoError(t, err)
+
+	// Владелец аккаунта получает уведомление о сбросе
+	assert.Equal(t, mail.TemplateSecurityAlert, templateOf(mailer.last(t)))
+}
+
+func TestRegisterSendsVerificationEmail(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, repo := newTestUserService(t, WithMailer(mailer), WithVerificationURL("https://cloud.example.com/verify"))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "verify@example.com", "verify", "password123")
+	require.NoError(t, err)
+
+	message := mailer.last(t)
+	assert.Equal(t, "verify@example.com", message.To)
+	assert.Contains(t, message.HTML, "https://cloud.example.com/verify?token=")
+
+	token := linkTokenFrom(t, message)
+	require.NoError(t, svc.VerifyEmail(ctx, token))
+	assert.ErrorIs(t, svc.VerifyEmail(ctx, token), errdefs.ErrVerificationTokenUsed)
+
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsEmailVerified)
+
+	// Подтвержденному пользователю повторное письмо не отправляется
+	sent := len(mailer.messages)
+	require.NoError(t, svc.SendVerificationEmail(ctx, user.ID))
+	assert.Len(t, mailer.messages, sent)
+}
+
+// templateOf определяет шаблон письма по теме
+func templateOf(message *models.EmailMessage) string {
+	for _, name := range []string{mail.TemplateVerification, mail.TemplatePasswordReset, mail.TemplateSecurityAlert, mail.TemplateEmailChange, mail.TemplateEmailChanged} {
+		if rendered, err := mail.Render(name, message.To, mail.TemplateData{}); err == nil && rendered.Subject == message.Subject {
+			return name
+		}
+	}
+	return ""
+}
+
+func TestPasswordResetTokenInvalidatedByPasswordChange(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, _ := newTestUserService(t, WithMailer(mailer))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "change@example.com", "change", "password123")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.RequestPasswordReset(ctx, "change@example.com"))
+	token := linkTokenFrom(t, mailer.last(t))
+
+	oldPassword, newPassword := "password123", "changed-password123"
+	require.NoError(t, svc.UpdateProfile(ctx, user.ID, nil, &oldPassword, &newPassword))
+
+	assert.ErrorIs(t, svc.ResetPassword(ctx, token, "another-password123"), errdefs.ErrInvalidToken)
+}
+
+func TestPasswordResetTokenConsumedOnceConcurrently(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, _ := newTestUserService(t, WithMailer(mailer))
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "race@example.com", "race", "password123")
+	require.NoError(t, err)
+	require.NoError(t, svc.RequestPasswordReset(ctx, "race@example.com"))
+	token := linkTokenFrom(t, mailer.last(t))
+
+	// Параллельные запросы с одним токеном проходят проверку отпечатка одновременно,
+	// пароль должен смениться только один раз
+	const attempts = 8
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = svc.ResetPassword(ctx, token, fmt.Sprintf("race-password-%d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, errdefs.ErrInvalidToken)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestResendVerificationThrottled(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, repo := newTestUserService(t, WithMailer(mailer),
+		WithVerificationResendLimiter(repository.NewMemoryRateLimiter(2, time.Hour)))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "resend@example.com", "resend", "password123")
+	require.NoError(t, err)
+	sent := len(mailer.messages)
+
+	require.NoError(t, svc.ResendVerificationEmail(ctx, user.ID))
+	require.NoError(t, svc.ResendVerificationEmail(ctx, user.ID))
+	assert.Len(t, mailer.messages, sent+2)
+
+	err = svc.ResendVerificationEmail(ctx, user.ID)
+	var limited *errdefs.RateLimitError
+	require.ErrorAs(t, err, &limited)
+	assert.ErrorIs(t, err, errdefs.ErrRateLimited)
+	assert.Positive(t, limited.RetryAfter)
+	assert.Len(t, mailer.messages, sent+2)
+
+	// Подтвержденный email повторно не подтверждается
+	require.NoError(t, repo.UpdateEmailVerification(ctx, user.ID, true))
+	assert.ErrorIs(t, svc.ResendVerificationEmail(ctx, user.ID), errdefs.ErrConflict)
+}
+
+func TestPasswordResetRequestsThrottled(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, _ := newTestUserService(t, WithMailer(mailer),
+		WithVerificationResendLimiter(repository.NewMemoryRateLimiter(2, time.Hour)))
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "flood@example.com", "flood", "password123")
+	require.NoError(t, err)
+	sent := len(mailer.messages)
+
+	// Лимит общий для любого написания адреса
+	require.NoError(t, svc.RequestPasswordReset(ctx, "Flood@Example.com"))
+	require.NoError(t, svc.RequestPasswordReset(ctx, "flood@example.com"))
+	assert.Len(t, mailer.messages, sent+1)
+
+	// Сверх лимита письма не уходят, но ответ тот же, что и для неизвестного адреса
+	require.NoError(t, svc.RequestPasswordReset(ctx, "flood@example.com"))
+	assert.Len(t, mailer.messages, sent+1)
+}
+
+func TestPasswordResetRequestReportsStorageErrors(t *testing.T) {
+	repo := unavailableUserRepository{newFakeUserRepository()}
+	sec := security.NewSecurity("test-secret", nil, time.Minute, time.Hour, "test-verification-secret", time.Hour, "test-reset-secret", time.Hour)
+	svc := NewUserService(repo, sec, fileClient.NewMockFileServiceClient(false))
+
+	err := svc.RequestPasswordReset(context.Background(), "outage@example.com")
+	assert.True(t, errdefs.IsUnavailable(err), "got %v", err)
+}
+
+func TestVerifyEmailRejectsTokenForOldAddress(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, repo := newTestUserService(t, WithMailer(mailer))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "old@example.com", "moved", "password123")
+	require.NoError(t, err)
+	token := linkTokenFrom(t, mailer.last(t))
+
+	require.NoError(t, repo.update(user.ID, func(u *models.User) { u.Email = "new@example.com" }))
+
+	assert.ErrorIs(t, svc.VerifyEmail(ctx, token), errdefs.ErrVerificationEmailMismatch)
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsEmailVerified)
+}
+
+func TestEmailChange(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, repo := newTestUserService(t, WithMailer(mailer),
+		WithEmailChangeURLs("https://cloud.example.com/email/confirm", "https://cloud.example.com/email/revert"))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "before@example.com", "mover", "password123")
+	require.NoError(t, err)
+	_, err = svc.Register(ctx, "taken@example.com", "other", "password123")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateEmailVerification(ctx, user.ID, true))
+
+	assert.ErrorIs(t, svc.RequestEmailChange(ctx, user.ID, "wrong-password", "after@example.com"), errdefs.ErrInvalidCredentials)
+	assert.ErrorIs(t, svc.RequestEmailChange(ctx, user.ID, "password123", "taken@example.com"), errdefs.ErrConflict)
+	assert.ErrorIs(t, svc.RequestEmailChange(ctx, user.ID, "password123", "not-an-email"), errdefs.ErrInvalidInput)
+
+	require.NoError(t, svc.RequestEmailChange(ctx, user.ID, "password123", "after@example.com"))
+	message := mailer.last(t)
+	assert.Equal(t, "after@example.com", message.To)
+	assert.Equal(t, mail.TemplateEmailChange, templateOf(message))
+	assert.Contains(t, message.Text, "https://cloud.example.com/email/confirm?token=")
+	confirmToken := linkTokenFrom(t, message)
+
+	// До подтверждения аккаунт остается на прежнем адресе
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "before@example.com", stored.Email)
+	assert.True(t, stored.IsEmailVerified)
+
+	_, session, err := svc.Login(ctx, "before@example.com", "password123")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.ConfirmEmailChange(ctx, confirmToken))
+	assert.ErrorIs(t, svc.ConfirmEmailChange(ctx, confirmToken), errdefs.ErrVerificationEmailMismatch)
+
+	stored, err = repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "after@example.com", stored.Email)
+	assert.True(t, stored.IsEmailVerified)
+
+	notice := mailer.last(t)
+	assert.Equal(t, "before@example.com", notice.To)
+	assert.Equal(t, mail.TemplateEmailChanged, templateOf(notice))
+	assert.Contains(t, notice.Text, "after@example.com")
+	revertToken := linkTokenFrom(t, notice)
+
+	// Ссылка подтверждения не подходит для отмены
+	assert.ErrorIs(t, svc.RevertEmailChange(ctx, confirmToken), errdefs.ErrInvalidToken)
+
+	require.NoError(t, svc.RevertEmailChange(ctx, revertToken))
+	assert.ErrorIs(t, svc.RevertEmailChange(ctx, revertToken), errdefs.ErrVerificationEmailMismatch)
+
+	stored, err = repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "before@example.com", stored.Email)
+	assert.True(t, stored.IsEmailVerified)
+
+	// Отмена завершает все сессии
+	_, err = svc.ValidateToken(ctx, session.AccessToken)
+	assert.Error(t, err)
+	assert.Equal(t, mail.TemplateSecurityAlert, templateOf(mailer.last(t)))
+}
+
+func TestEmailChangeConfirmRechecksUniqueness(t *testing.T) {
+	mailer := &outboxMailer{}
+	svc, _ := newTestUserService(t, WithMailer(mailer))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "first@example.com", "first", "password123")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.RequestEmailChange(ctx, user.ID, "password123", "wanted@example.com"))
+	token := linkTokenFrom(t, mailer.last(t))
+
+	// Адрес занял другой аккаунт, пока письмо ждало подтверждения
+	_, err = svc.Register(ctx, "wanted@example.com", "second", "password123")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.ConfirmEmailChange(ctx, token), errdefs.ErrConflict)
+}
+
+func TestUserLifecycleEvents(t *testing.T) {
+	mailer := &outboxMailer{}
+	outbox := repository.NewMemoryOutboxRepository()
+	svc, _ := newTestUserService(t, WithMailer(mailer), WithOutbox(outbox))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "events@example.com", "events", "password123")
+	require.NoError(t, err)
+	require.NoError(t, svc.VerifyEmail(ctx, linkTokenFrom(t, mailer.last(t))))
+
+	oldPassword, newPassword := "password123", "new-password123"
+	require.NoError(t, svc.UpdateProfile(ctx, user.ID, nil, &oldPassword, &newPassword))
+
+	for i := 0; i < 5; i++ {
+		_, _, err := svc.Login(ctx, "events@example.com", "wrong-password")
+		require.Error(t, err)
+	}
+
+	records, err := outbox.ListDue(ctx, time.Now().Add(time.Minute), 0)
+	require.NoError(t, err)
+	var types []string
+	for _, record := range records {
+		assert.Equal(t, user.ID, record.Event.UserID)
+		types = append(types, record.Event.Type)
+	}
+	assert.Equal(t, []string{
+		models.EventUserRegistered,
+		models.EventEmailVerified,
+		models.EventPasswordChanged,
+		models.EventUserLocked,
+	}, types)
+	assert.Equal(t, "events@example.com", records[0].Event.Data["email"])
+}
+
+func TestLoginFromNewDeviceRecordsEvent(t *testing.T) {
+	outbox := repository.NewMemoryOutboxRepository()
+	svc, _ := newTestUserService(t, WithOutbox(outbox))
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "device@example.com", "device", "password123")
+	require.NoError(t, err)
+
+	// Первый вход после регистрации задает известное устройство
+	_, _, err = svc.Login(ctx, "device@example.com", "password123")
+	require.NoError(t, err)
+
+	phoneCtx := models.WithClientInfo(ctx, models.ClientInfo{DeviceName: "phone", UserAgent: "HomeCloud-Android", IP: "10.0.0.2"})
+	for i := 0; i < 2; i++ {
+		_, _, err = svc.Login(phoneCtx, "device@example.com", "password123")
+		require.NoError(t, err)
+	}
+
+	records, err := outbox.ListDue(ctx, time.Now().Add(time.Minute), 0)
+	require.NoError(t, err)
+	var newDevice []*models.UserEvent
+	for _, record := range records {
+		if record.Event.Type == models.EventLoginNewDevice {
+			newDevice = append(newDevice, &record.Event)
+		}
+	}
+	require.Len(t, newDevice, 1)
+	assert.Equal(t, "10.0.0.2", newDevice[0].Data["ip"])
+	assert.Equal(t, "phone", newDevice[0].Data["device_name"])
+}
+
+func TestWebhookReplayOnlyDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryWebhookRepository()
+	svc := NewWebhookService(repo, audit.NewLogger(repository.NewMemoryAuditRepository()))
+
+	_, err := svc.CreateSubscription(ctx, &models.CreateWebhookRequest{URL: "ftp://example.com"})
+	assert.ErrorIs(t, err, errdefs.ErrInvalidInput)
+	_, err = svc.CreateSubscription(ctx, &models.CreateWebhookRequest{URL: "https://example.com", Events: []string{"user.unknown"}})
+	assert.ErrorIs(t, err, errdefs.ErrInvalidInput)
+
+	subscription, err := svc.CreateSubscription(ctx, &models.CreateWebhookRequest{URL: "https://example.com/hook", Events: []string{models.EventUserLocked}})
+	require.NoError(t, err)
+	assert.Len(t, subscription.Secret, 64, "secret is generated when not given")
+
+	// Административные события доступны без подписки на "*"
+	_, err = svc.CreateSubscription(ctx, &models.CreateWebhookRequest{URL: "https://example.com/admin",
+		Events: []string{models.EventUserDisabled, models.EventUserEnabled, models.EventQuotaChanged}})
+	require.NoError(t, err)
+
+	delivery := &models.WebhookDelivery{ID: uuid.New(), SubscriptionID: subscription.ID, Status: models.WebhookDeliveryPending}
+	require.NoError(t, repo.CreateDelivery(ctx, delivery))
+	assert.ErrorIs(t, svc.ReplayDelivery(ctx, delivery.ID), errdefs.ErrConflict)
+
+	delivery.Status = models.WebhookDeliveryDead
+	delivery.Attempts = 8
+	require.NoError(t, repo.UpdateDelivery(ctx, delivery))
+	dead, err := svc.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+
+	require.NoError(t, svc.ReplayDelivery(ctx, delivery.ID))
+	replayed, err := repo.GetDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+	assert.ErrorIs(t, svc.ReplayDelivery(ctx, uuid.New()), errdefs.ErrNotFound)
+}
+
+func TestAdminAccountChangesRecordEvents(t *testing.T) {
+	outbox := repository.NewMemoryOutboxRepository()
+	svc, repo := newTestUserService(t, WithOutbox(outbox))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "admin-ops@example.com", "adminops", "password123")
+	require.NoError(t, err)
+	_, tokens, err := svc.Login(ctx, "admin-ops@example.com", "password123")
+	require.NoError(t, err)
+
+	active, inactive := true, false
+	quota, negative := int64(1<<40), int64(-1)
+	// Недопустимая квота отклоняет весь запрос: блокировка тоже не применяется
+	assert.ErrorIs(t, svc.AdminUpdateUser(ctx, user.ID, &inactive, &negative), errdefs.ErrInvalidInput)
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsActive)
+
+	require.NoError(t, svc.AdminUpdateUser(ctx, user.ID, nil, &quota))
+	// Повторная установка того же значения не порождает событие
+	require.NoError(t, svc.AdminUpdateUser(ctx, user.ID, &active, &quota))
+
+	require.NoError(t, svc.AdminUpdateUser(ctx, user.ID, &inactive, nil))
+	_, err = svc.ValidateToken(ctx, tokens.AccessToken)
+	assert.Error(t, err, "disabling the account ends its sessions")
+	require.NoError(t, svc.AdminUpdateUser(ctx, user.ID, &active, nil))
+
+	records, err := outbox.ListDue(ctx, time.Now().Add(time.Minute), 0)
+	require.NoError(t, err)
+	var types []string
+	for _, record := range records {
+		types = append(types, record.Event.Type)
+	}
+	assert.Equal(t, []string{
+		models.EventUserRegistered,
+		models.EventQuotaChanged,
+		models.EventUserDisabled,
+		models.EventUserEnabled,
+	}, types)
+	assert.Equal(t, "1099511627776", records[1].Event.Data["storage_quota"])
+}
+
+// failingRevocationStore имитирует недоступное хранилище отзыва токенов
+type failingRevocationStore struct {
+	*repository.MemoryTokenRevocationStore
+}
+
+func (s failingRevocationStore) RevokeUserTokens(ctx context.Context, userID uuid.UUID, issuedBefore time.Time, expiresAt time.Time) error {
+	return errdefs.ErrUnavailable
+}
+
+func TestAdminDisableRecordsEventWhenLogoutFails(t *testing.T) {
+	outbox := repository.NewMemoryOutboxRepository()
+	svc, repo := newTestUserService(t, WithOutbox(outbox),
+		WithTokenRevocationStore(failingRevocationStore{repository.NewMemoryTokenRevocationStore()}))
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "half-disabled@example.com", "halfdisabled", "password123")
+	require.NoError(t, err)
+
+	inactive := false
+	assert.ErrorIs(t, svc.AdminUpdateUser(ctx, user.ID, &inactive, nil), errdefs.ErrUnavailable)
+
+	// Блокировка сохранена, значит событие о ней должно дойти до подписчиков
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsActive)
+
+	records, err := outbox.ListDue(ctx, time.Now().Add(time.Minute), 0)
+	require.NoError(t, err)
+	var types []string
+	for _, record := range records {
+		types = append(types, record.Event.Type)
+	}
+	assert.Contains(t, types, models.EventUserDisabled)
+}
+
+func TestSecurityActionsAreAudited(t *testing.T) {
+	auditLog := audit.NewLogger(repository.NewMemoryAuditRepository())
+	svc, _ := newTestUserService(t, WithAuditLogger(auditLog))
+	ctx := models.WithClientInfo(context.Background(), models.ClientInfo{IP: "192.0.2.7", UserAgent: "HomeCloud-iOS", RequestID: "req-42"})
+
+	user, err := svc.Register(ctx, "audited@example.com", "audited", "password123")
+	require.NoError(t, err)
+
+	_, _, err = svc.Login(ctx, "nobody@example.com", "password123")
+	require.Error(t, err)
+	for i := 0; i < 5; i++ {
+		_, _, err = svc.Login(ctx, "audited@example.com", "wrong-password")
+		require.Error(t, err)
+	}
+
+	// Изменение профиля выполняет сам пользователь через защищенный маршрут
+	actorCtx := models.WithClientInfo(ctx, models.ClientInfo{IP: "192.0.2.7", ActorID: user.ID})
+	newName := "audited2"
+	require.NoError(t, svc.UpdateProfile(actorCtx, user.ID, &newName, nil, nil))
+
+	records, err := auditLog.Query(ctx, models.AuditFilter{})
+	require.NoError(t, err)
+	var actions []string
+	for _, record := range records {
+		actions = append(actions, record.Action+":"+record.Outcome)
+	}
+	assert.Equal(t, []string{
+		"login:failure",
+		"login:failure", "login:failure", "login:failure", "login:failure", "login:failure",
+		"account.lockout:success",
+		"username.change:success",
+	}, actions)
+
+	unknown := records[0]
+	assert.Equal(t, uuid.Nil, unknown.TargetUserID)
+	assert.Equal(t, "nobody@example.com", unknown.Details["email"])
+	assert.Equal(t, "192.0.2.7", unknown.IP)
+	assert.Equal(t, "HomeCloud-iOS", unknown.UserAgent)
+	assert.Equal(t, "req-42", unknown.RequestID)
+	assert.Equal(t, "invalid password", records[1].Reason)
+	assert.Equal(t, user.ID, records[len(records)-1].ActorID)
+
+	byUser, err := auditLog.Query(ctx, models.AuditFilter{UserID: user.ID})
+	require.NoError(t, err)
+	assert.Len(t, byUser, len(records)-1)
+
+	verified, err := auditLog.Verify(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(records), verified)
+}
+
+func TestCredentialsNeverReachLogs(t *testing.T) {
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	ctx := logger.CtxWWithLogger(context.Background(), logger.FromZap(zap.New(core)))
+
+	svc, repo := newTestUserService(t)
+	user, err := svc.Register(ctx, "logged@example.com", "logged", "password123")
+	require.NoError(t, err)
+
+	_, _, err = svc.Login(ctx, "logged@example.com", "wrong-password")
+	require.Error(t, err)
+	_, tokens, err := svc.Login(ctx, "logged@example.com", "password123")
+	require.NoError(t, err)
+
+	// Повторное использование refresh токена пишет предупреждение в лог
+	rotated, err := svc.RefreshToken(ctx, tokens.RefreshToken)
+	require.NoError(t, err)
+	_, err = svc.RefreshToken(ctx, tokens.RefreshToken)
+	require.Error(t, err)
+
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+
+	output := buf.String()
+	require.NotEmpty(t, output)
+	assert.Contains(t, output, "logged@example.com")
+	for _, secret := range []string{"password123", "wrong-password", stored.PasswordHash, tokens.AccessToken, tokens.RefreshToken, rotated.RefreshToken} {
+		assert.NotContains(t, output, secret)
+	}
+}
+
+func TestLoginMetricsByOutcome(t *testing.T) {
+	svc, repo := newTestUserService(t)
+	ctx := context.Background()
+	user, err := svc.Register(ctx, "metrics@example.com", "metrics", "password123")
+	require.NoError(t, err)
+
+	counter := func(outcome string) float64 {
+		return testutil.ToFloat64(metrics.LoginsTotal.WithLabelValues(outcome))
+	}
+	before := map[string]float64{}
+	for _, outcome := range []string{metrics.LoginSuccess, metrics.LoginBadPassword, metrics.LoginLocked, metrics.LoginInactive, metrics.LoginUnknownUser} {
+		before[outcome] = counter(outcome)
+	}
+	lockoutsBefore := testutil.ToFloat64(metrics.LockoutsTotal)
+	validBefore := testutil.ToFloat64(metrics.TokenValidationsTotal.WithLabelValues(metrics.ResultSuccess))
+	invalidBefore := testutil.ToFloat64(metrics.TokenValidationsTotal.WithLabelValues(metrics.ResultFailure))
+
+	_, tokens, err := svc.Login(ctx, "metrics@example.com", "password123")
+	require.NoError(t, err)
+	_, _, err = svc.Login(ctx, "unknown@example.com", "password123")
+	require.Error(t, err)
+	for i := 0; i < 5; i++ {
+		_, _, err = svc.Login(ctx, "metrics@example.com", "wrong-password")
+		require.Error(t, err)
+	}
+	_, _, err = svc.Login(ctx, "metrics@example.com", "password123")
+	require.Error(t, err)
+
+	repo.mu.Lock()
+	repo.users[user.ID].IsActive = false
+	repo.users[user.ID].LockedUntil = nil
+	repo.mu.Unlock()
+	_, _, err = svc.Login(ctx, "metrics@example.com", "password123")
+	require.Error(t, err)
+
+	_, err = svc.ValidateToken(ctx, "not-a-token")
+	require.Error(t, err)
+	repo.mu.Lock()
+	repo.users[user.ID].IsActive = true
+	repo.mu.Unlock()
+	_, err = svc.ValidateToken(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, counter(metrics.LoginSuccess)-before[metrics.LoginSuccess])
+	assert.Equal(t, 1.0, counter(metrics.LoginUnknownUser)-before[metrics.LoginUnknownUser])
+	assert.Equal(t, 5.0, counter(metrics.LoginBadPassword)-before[metrics.LoginBadPassword])
+	assert.Equal(t, 1.0, counter(metrics.LoginLocked)-before[metrics.LoginLocked])
+	assert.Equal(t, 1.0, counter(metrics.LoginInactive)-before[metrics.LoginInactive])
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.LockoutsTotal)-lockoutsBefore)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TokenValidationsTotal.WithLabelValues(metrics.ResultSuccess))-validBefore)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TokenValidationsTotal.WithLabelValues(metrics.ResultFailure))-invalidBefore)
+}
+
+func TestTracedUserServiceSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	inner, _ := newTestUserService(t)
+	svc := NewTracedUserService(inner)
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "traced@example.com", "traced", "password123")
+	require.NoError(t, err)
+	_, _, err = svc.Login(ctx, "traced@example.com", "wrong-password")
+	require.Error(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	var bcrypt []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if strings.HasPrefix(span.Name(), "bcrypt.") {
+			bcrypt = append(bcrypt, span)
+			continue
+		}
+		spans[span.Name()] = span
+	}
+	register, ok := spans["UserService.Register"]
+	require.True(t, ok)
+	login, ok := spans["UserService.Login"]
+	require.True(t, ok)
+	assert.Equal(t, "Error", login.Status().Code.String())
+
+	require.Len(t, bcrypt, 2)
+	assert.Equal(t, "bcrypt.hash", bcrypt[0].Name())
+	assert.Equal(t, register.SpanContext().SpanID(), bcrypt[0].Parent().SpanID())
+	assert.Equal(t, "bcrypt.compare", bcrypt[1].Name())
+	assert.Equal(t, login.SpanContext().SpanID(), bcrypt[1].Parent().SpanID())
+}
+
+// unavailableUserRepository имитирует недоступный DB manager
+type unavailableUserRepository struct {
+	*fakeUserRepository
+}
+
+func (r unavailableUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return nil, status.Error(codes.Unavailable, "dial tcp 10.0.0.5:50051: connection refused")
+}
+
+func TestDomainErrorsAreTyped(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, "typed@example.com", "typed", "password123")
+	require.NoError(t, err)
+
+	_, err = svc.Register(ctx, "typed@example.com", "typed2", "password123")
+	assert.ErrorIs(t, err, errdefs.ErrConflict)
+	assert.Equal(t, codes.AlreadyExists, errdefs.GRPCCode(err))
+	assert.Equal(t, "email already exists", errdefs.PublicMessage(err))
+	assert.Equal(t, "auth.email_taken", errdefs.Code(err))
+
+	_, err = svc.Register(ctx, "other@example.com", "ab", "password123")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidInput)
+	assert.Equal(t, "username must be at least 3 characters", errdefs.PublicMessage(err))
+
+	_, err = svc.Register(ctx, "other@example.com", "../etc", "password123")
+	assert.Equal(t, []string{"invalid_characters"}, fieldCodes(err))
+
+	// Нарушения по всем полям возвращаются сразу
+	_, err = svc.Register(ctx, "no-at-sign", "", "123")
+	assert.Equal(t, errdefs.CodeValidationFailed, errdefs.Code(err))
+	assert.Equal(t, []errdefs.FieldViolation{
+		{Field: "email", Code: "invalid_format", Message: "email must be a valid email address"},
+		{Field: "username", Code: "required", Message: "username is required"},
+		{Field: "password", Code: "too_short", Message: "password must be at least 6 characters"},
+	}, errdefs.Fields(err))
+
+	_, _, err = svc.Login(ctx, "typed@example.com", "wrong-password")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidCredentials)
+	_, _, err = svc.Login(ctx, "nobody@example.com", "password123")
+	assert.ErrorIs(t, err, errdefs.ErrInvalidCredentials)
+
+	_, err = svc.ValidateToken(ctx, "not-a-jwt")
+	assert.Equal(t, codes.Unauthenticated, errdefs.GRPCCode(err))
+}
+
+func TestLoginReportsStorageOutage(t *testing.T) {
+	repo := unavailableUserRepository{newFakeUserRepository()}
+	sec := security.NewSecurity("test-secret", nil, time.Minute, time.Hour, "test-verification-secret", time.Hour, "test-reset-secret", time.Hour)
+	svc := NewUserService(repo, sec, fileClient.NewMockFileServiceClient(false))
+
+	_, _, err := svc.Login(context.Background(), "user@example.com", "password123")
+	assert.NotErrorIs(t, err, errdefs.ErrInvalidCredentials)
+	assert.Equal(t, codes.Unavailable, errdefs.GRPCCode(err))
+	assert.Equal(t, http.StatusServiceUnavailable, errdefs.HTTPStatus(err))
+	assert.NotContains(t, errdefs.PublicMessage(err), "10.0.0.5")
+}
+
+func fieldCodes(err error) []string {
+	var result []string
+	for _, field := range errdefs.Fields(err) {
+		result = append(result, field.Code)
+	}
+	return result
+}
+
+func TestPasswordPolicyEnforced(t *testing.T) {
+	policy := security.NewPasswordPolicy(models.PasswordPolicy{
+		MinLength:           10,
+		MinCharacterClasses: 2,
+		ForbidUserInfo:      true,
+	}, security.CommonPasswords())
+	mailer := &outboxMailer{}
+	svc, repo := newTestUserService(t, WithPasswordPolicy(policy), WithMailer(mailer))
+	ctx := context.Background()
+
+	assert.Equal(t, 10, svc.PasswordPolicy().MinLength)
+	assert.True(t, svc.PasswordPolicy().CommonPasswords)
+
+	// Нарушения политики приходят вместе с остальными полями
+	_, err := svc.Register(ctx, "bad-email", "policy", "policy-2024")
+	assert.Equal(t, errdefs.CodeValidationFailed, errdefs.Code(err))
+	assert.Equal(t, []string{"invalid_format", "contains_user_info"}, fieldCodes(err))
+
+	_, err = svc.Register(ctx, "policy@example.com", "policy", "password123")
+	assert.Equal(t, []string{"common_password"}, fieldCodes(err))
+
+	user, err := svc.Register(ctx, "policy@example.com", "policy", "long-enough-42")
+	require.NoError(t, err)
+
+	// Слабый новый пароль отклоняется до смены username
+	newUsername, oldPassword, weak := "renamed", "long-enough-42", "renamed-2024"
+	err = svc.UpdateProfile(ctx, user.ID, &newUsername, &oldPassword, &weak)
+	assert.Equal(t, []string{"contains_user_info"}, fieldCodes(err))
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "policy", stored.Username)
+
+	require.NoError(t, svc.RequestPasswordReset(ctx, "policy@example.com"))
+	token := linkTokenFrom(t, mailer.last(t))
+	err = svc.ResetPassword(ctx, token, "qwertyuiop")
+	assert.Equal(t, []string{"too_few_character_classes", "common_password"}, fieldCodes(err))
+	assert.Equal(t, "new_password", errdefs.Fields(err)[0].Field)
+	require.NoError(t, svc.ResetPassword(ctx, token, "another-strong-7"))
+}
+
+func TestUpdateProfileWrongOldPasswordKeepsUsername(t *testing.T) {
+	svc, repo := newTestUserService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "rename@example.com", "rename", "password123")
+	require.NoError(t, err)
+
+	// Неверный или отсутствующий старый пароль отклоняет весь запрос, username не меняется
+	newUsername, wrongPassword, newPassword := "renamed", "wrong-password", "changed-password123"
+	err = svc.UpdateProfile(ctx, user.ID, &newUsername, &wrongPassword, &newPassword)
+	assert.Equal(t, "auth.invalid_old_password", errdefs.Code(err))
+	err = svc.UpdateProfile(ctx, user.ID, &newUsername, nil, &newPassword)
+	assert.Equal(t, []string{"required"}, fieldCodes(err))
+
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rename", stored.Username)
+	assert.NoError(t, svc.comparePassword(ctx, stored.PasswordHash, "password123"))
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/security"
+
+	"github.com/google/uuid"
+)
+
+// Время на прохождение церемонии WebAuthn в браузере
+const webAuthnTimeout = 5 * time.Minute
+
+// Начало регистрации passkey для вошедшего пользователя
+func (s *UserService) BeginWebAuthnRegistration(ctx context.Context, userID uuid.UUID) (*models.WebAuthnRegisterBeginResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	challenge, err := s.newWebAuthnChallenge(ctx, models.WebAuthnCeremonyRegistration, user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Повторная регистрация того же аутентификатора не нужна
+	exclude, err := s.credentialDescriptors(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnRegisterBeginResponse{
+		ChallengeID: challenge.ID,
+		PublicKey: models.PublicKeyCredentialCreationOptions{
+			Challenge: challenge.Challenge,
+			RP:        models.RelyingParty{ID: s.webAuthn.RPID(), Name: s.webAuthn.RPName()},
+			User: models.WebAuthnUserEntity{
+				ID:          user.ID[:],
+				Name:        user.Email,
+				DisplayName: user.Username,
+			},
+			PubKeyCredParams: []models.CredentialParameter{
+				{Type: "public-key", Alg: security.COSEAlgES256},
+				{Type: "public-key", Alg: security.COSEAlgEdDSA},
+				{Type: "public-key", Alg: security.COSEAlgRS256},
+			},
+			Timeout:            webAuthnTimeout.Milliseconds(),
+			Attestation:        "none",
+			ExcludeCredentials: exclude,
+			AuthenticatorSelection: models.AuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: "preferred",
+			},
+		},
+	}, nil
+}
+
+// Завершение регистрации passkey. Наличие ключа включает 2FA для входа по паролю.
+// Passkey - новый способ входа без пароля, поэтому требуется текущий пароль пользователя.
+func (s *UserService) FinishWebAuthnRegistration(ctx context.Context, userID, challengeID uuid.UUID, name, password string, credential *models.AttestationCredential) (*models.WebAuthnCredential, error) {
+	if err := s.confirmPassword(ctx, userID, password); err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.consumeWebAuthnChallenge(ctx, challengeID, models.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID != userID {
+		return nil, fmt.Errorf("challenge belongs to another user: %w", errdefs.ErrWebAuthnFailed)
+	}
+
+	attested, err := s.webAuthn.VerifyRegistration(challenge.Challenge, credential.Response.ClientDataJSON, credential.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+	stored := &models.WebAuthnCredential{
+		ID:         models.Base64URL(attested.ID).String(),
+		UserID:     userID,
+		Name:       name,
+		PublicKey:  attested.PublicKey,
+		Algorithm:  attested.Algorithm,
+		SignCount:  attested.SignCount,
+		AAGUID:     attested.AAGUID,
+		Transports: credential.Response.Transports,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.webAuthnRepo.CreateCredential(ctx, stored); err != nil {
+		if errdefs.Is(err, errdefs.ErrConflict) {
+			return nil, errdefs.Public(errdefs.ErrConflict, "auth.passkey_already_registered", "credential already registered")
+		}
+		return nil, fmt.Errorf("failed to store credential: %w", err)
+	}
+
+	if err := s.syncTwoFactor(ctx, userID); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Начало входа по passkey.
+// С mfaToken - второй фактор после пароля; с email или без него - вход без пароля.
+func (s *UserService) BeginWebAuthnLogin(ctx context.Context, email, mfaToken string) (*models.WebAuthnLoginBeginResponse, error) {
+	ceremony := models.WebAuthnCeremonyLogin
+	userID := uuid.Nil
+	var mfaChallenge *security.ChallengeClaims
+
+	switch {
+	case mfaToken != "":
+		var err error
+		mfaChallenge, err = s.validateMFAChallenge(ctx, mfaToken)
+		if err != nil {
+			return nil, err
+		}
+		ceremony, userID = models.WebAuthnCeremonyMFA, mfaChallenge.UserID
+	case email != "":
+		// Для неизвестного email выдаем challenge без ключей,
+		// чтобы ответ не раскрывал наличие аккаунта
+		if user, err := s.repo.GetUserByEmail(ctx, email); err == nil {
+			userID = user.ID
+		}
+	}
+
+	challenge, err := s.newWebAuthnChallenge(ctx, ceremony, userID, mfaChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	allow := []models.CredentialDescriptor{}
+	if userID != uuid.Nil {
+		if allow, err = s.credentialDescriptors(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	userVerification := "preferred"
+	if ceremony == models.WebAuthnCeremonyLogin {
+		userVerification = "required"
+	}
+
+	return &models.WebAuthnLoginBeginResponse{
+		ChallengeID: challenge.ID,
+		PublicKey: models.PublicKeyCredentialRequestOptions{
+			Challenge:        challenge.Challenge,
+			RPID:             s.webAuthn.RPID(),
+			Timeout:          webAuthnTimeout.Milliseconds(),
+			AllowCredentials: allow,
+			UserVerification: userVerification,
+		},
+	}, nil
+}
+
+// Завершение входа по passkey: проверка подписи и счетчика, открытие сессии
+func (s *UserService) FinishWebAuthnLogin(ctx context.Context, challengeID uuid.UUID, assertion *models.AssertionCredential) (*models.User, *models.TokenPair, error) {
+	challenge, err := s.consumeWebAuthnChallenge(ctx, challengeID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge.Ceremony == models.WebAuthnCeremonyRegistration {
+		return nil, nil, fmt.Errorf("unexpected ceremony: %w", errdefs.ErrWebAuthnFailed)
+	}
+
+	credential, err := s.webAuthnRepo.GetCredential(ctx, assertion.ID)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			return nil, nil, fmt.Errorf("unknown credential: %w", errdefs.ErrWebAuthnFailed)
+		}
+		return nil, nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	if challenge.UserID != uuid.Nil && credential.UserID != challenge.UserID {
+		return nil, nil, fmt.Errorf("credential belongs to another user: %w", errdefs.ErrWebAuthnFailed)
+	}
+	if handle := assertion.Response.UserHandle; len(handle) > 0 && string(handle) != string(credential.UserID[:]) {
+		return nil, nil, fmt.Errorf("user handle mismatch: %w", errdefs.ErrWebAuthnFailed)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, credential.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !user.CanLogin() {
+		return nil, nil, errdefs.ErrAccountDisabled
+	}
+
+	signCount, userVerified, err := s.webAuthn.VerifyAssertion(
+		challenge.Challenge,
+		credential.PublicKey,
+		credential.Algorithm,
+		credential.SignCount,
+		assertion.Response.ClientDataJSON,
+		assertion.Response.AuthenticatorData,
+		assertion.Response.Signature,
+	)
+	if err != nil {
+		s.recordAudit(ctx, models.AuditLogin, user.ID, err, map[string]string{"method": models.MFAMethodWebAuthn})
+		if challenge.Ceremony == models.WebAuthnCeremonyMFA {
+			metrics.LoginsTotal.WithLabelValues(metrics.LoginBadSecondFactor).Inc()
+			s.registerFailedLogin(ctx, user)
+		} else {
+			metrics.LoginsTotal.WithLabelValues(metrics.LoginBadPasskey).Inc()
+		}
+		return nil, nil, err
+	}
+	// Вход без пароля допустим только с проверкой пользователя (PIN, биометрия)
+	if challenge.Ceremony == models.WebAuthnCeremonyLogin && !userVerified {
+		return nil, nil, fmt.Errorf("user verification required: %w", errdefs.ErrWebAuthnFailed)
+	}
+
+	if err := s.webAuthnRepo.UpdateCredentialUsage(ctx, credential.ID, signCount, time.Now()); err != nil {
+		return nil, nil, fmt.Errorf("failed to update credential: %w", err)
+	}
+	if challenge.MFATokenID != "" {
+		if err := s.consumeMFAChallenge(ctx, challenge.MFATokenID, challenge.MFATokenExpiresAt); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tokens, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Список passkey пользователя
+func (s *UserService) ListWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// Удаление passkey пользователя. Требует текущий пароль: удаление последнего ключа
+// выключает 2FA. Если других факторов не осталось, резервные коды удаляются.
+func (s *UserService) DeleteWebAuthnCredential(ctx context.Context, userID uuid.UUID, credentialID, password string) error {
+	if err := s.confirmPassword(ctx, userID, password); err != nil {
+		return err
+	}
+
+	credential, err := s.webAuthnRepo.GetCredential(ctx, credentialID)
+	if err != nil {
+		return err
+	}
+	// Чужой ключ неотличим от несуществующего
+	if credential.UserID != userID {
+		return errdefs.ErrNotFound
+	}
+
+	if err := s.webAuthnRepo.DeleteCredential(ctx, credentialID); err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return s.syncTwoFactor(ctx, userID)
+}
+
+// mfa - MFA challenge токен для церемонии второго фактора, иначе nil
+func (s *UserService) newWebAuthnChallenge(ctx context.Context, ceremony string, userID uuid.UUID, mfa *security.ChallengeClaims) (*models.WebAuthnChallenge, error) {
+	value, err := s.webAuthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &models.WebAuthnChallenge{
+		ID:        uuid.New(),
+		Challenge: value,
+		Ceremony:  ceremony,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(webAuthnTimeout),
+	}
+	if mfa != nil {
+		challenge.MFATokenID, challenge.MFATokenExpiresAt = mfa.TokenID, mfa.ExpiresAt
+	}
+	if err := s.webAuthnRepo.SaveChallenge(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to save webauthn challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge забирает challenge. Пустой ceremony - любая церемония.
+func (s *UserService) consumeWebAuthnChallenge(ctx context.Context, id uuid.UUID, ceremony string) (*models.WebAuthnChallenge, error) {
+	challenge, err := s.webAuthnRepo.ConsumeChallenge(ctx, id)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			return nil, fmt.Errorf("unknown challenge: %w", errdefs.ErrWebAuthnFailed)
+		}
+		return nil, fmt.Errorf("failed to get webauthn challenge: %w", err)
+	}
+	if challenge.IsExpired() {
+		return nil, fmt.Errorf("challenge expired: %w", errdefs.ErrWebAuthnFailed)
+	}
+	if ceremony != "" && challenge.Ceremony != ceremony {
+		return nil, fmt.Errorf("unexpected ceremony: %w", errdefs.ErrWebAuthnFailed)
+	}
+	return challenge, nil
+}
+
+func (s *UserService) credentialDescriptors(ctx context.Context, userID uuid.UUID) ([]models.CredentialDescriptor, error) {
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	descriptors := make([]models.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.ID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, models.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         id,
+			Transports: credential.Transports,
+		})
+	}
+	return descriptors, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий, на которые можно подписать вебхук
+var webhookEventTypes = map[string]bool{
+	models.EventUserRegistered:  true,
+	models.EventEmailVerified:   true,
+	models.EventEmailChanged:    true,
+	models.EventUsernameChanged: true,
+	models.EventPasswordChanged: true,
+	models.EventUserLocked:      true,
+	models.EventLoginNewDevice:  true,
+	models.EventUserDisabled:    true,
+	models.EventUserEnabled:     true,
+	models.EventQuotaChanged:    true,
+	"*":                         true,
+}
+
+// WebhookService управляет подписками на вебхуки. Доставку выполняет events.WebhookDeliverer.
+type WebhookService struct {
+	repo     interfaces.WebhookRepository
+	auditLog interfaces.AuditLogger
+}
+
+func NewWebhookService(repo interfaces.WebhookRepository, auditLog interfaces.AuditLogger) *WebhookService {
+	return &WebhookService{repo: repo, auditLog: auditLog}
+}
+
+// Создание подписки. Секрет возвращается вызывающему только здесь.
+func (s *WebhookService) CreateSubscription(ctx context.Context, req *models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errdefs.InvalidField("url", "invalid_url", "webhook url must be an absolute http(s) url")
+	}
+	for _, eventType := range req.Events {
+		if !webhookEventTypes[eventType] {
+			return nil, errdefs.InvalidField("events", "unknown_event", "unknown event type %q", eventType)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subscription := &models.WebhookSubscription{
+		ID:        uuid.New(),
+		URL:       target.String(),
+		Events:    req.Events,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	s.recordAudit(ctx, "create", subscription.ID, map[string]string{
+		"url":    subscription.URL,
+		"events": strings.Join(subscription.Events, ","),
+	})
+	return subscription, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	subscriptions, err := s.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteSubscription(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	s.recordAudit(ctx, "delete", id, nil)
+	return nil
+}
+
+func (s *WebhookService) ListDeadLetters(ctx context.Context) ([]*models.WebhookDelivery, error) {
+	deliveries, err := s.repo.ListDeliveriesByStatus(ctx, models.WebhookDeliveryDead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Повторная отправка доставки из dead letter: счетчик попыток сбрасывается,
+// доставка уходит при следующем запуске отправщика
+func (s *WebhookService) ReplayDelivery(ctx context.Context, id uuid.UUID) error {
+	delivery, err := s.repo.GetDelivery(ctx, id)
+	if err != nil {
+		return fmt.Errorf("webhook delivery not found: %w", err)
+	}
+	if delivery.Status != models.WebhookDeliveryDead {
+		return errdefs.Public(errdefs.ErrConflict, "webhooks.delivery_pending", "webhook delivery is still pending")
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	s.recordAudit(ctx, "replay", delivery.SubscriptionID, map[string]string{"delivery_id": id.String()})
+	return nil
+}
+
+// Действия с вебхуками не относятся к конкретному пользователю, цель записи пустая
+func (s *WebhookService) recordAudit(ctx context.Context, operation string, subscriptionID uuid.UUID, details map[string]string) {
+	if details == nil {
+		details = make(map[string]string)
+	}
+	details["operation"] = operation
+	details["subscription_id"] = subscriptionID.String()
+	s.auditLog.Record(ctx, &models.AuditRecord{
+		Action:  models.AuditAdminWebhook,
+		Outcome: models.AuditSuccess,
+		Details: details,
+	})
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
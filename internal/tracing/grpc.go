@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataCarrier позволяет propagator читать и писать метаданные gRPC
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// StartServerSpan продолжает трассу из входящих метаданных и открывает спан вызова
+func StartServerSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(rpcAttributes(fullMethod)...),
+	)
+}
+
+// EndRPCSpan закрывает спан gRPC вызова с кодом ответа
+func EndRPCSpan(span trace.Span, err error) {
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+	End(span, err)
+}
+
+// ClientInterceptor открывает спан на каждый исходящий вызов к сервису service
+// и передает trace context в метаданных (W3C traceparent)
+func ClientInterceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, strings.TrimPrefix(method, "/"),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(append(rpcAttributes(method), attribute.String("peer.service", service))...),
+		)
+
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		EndRPCSpan(span, err)
+		return err
+	}
+}
+
+// Атрибуты rpc.* из полного имени метода вида /package.Service/Method
+func rpcAttributes(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if ok {
+		attrs = append(attrs, semconv.RPCService(service), semconv.RPCMethod(method))
+	}
+	return attrs
+}
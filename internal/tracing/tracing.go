@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"homecloud-auth-service/config"
+)
+
+// Имя инструментирующей библиотеки для всех спанов сервиса
+const instrumentationName = "homecloud-auth-service"
+
+const defaultServiceName = "homecloud-auth-service"
+
+// Провайдер, созданный Init; nil, если экспорт выключен
+var provider *sdktrace.TracerProvider
+
+// Init настраивает глобальный TracerProvider и W3C propagator по конфигурации.
+// Без экспортера спаны не записываются, но trace context по-прежнему передается дальше
+func Init(ctx context.Context, cfg config.TracingConfig) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "otlp":
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		otlp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create otlp exporter: %w", err)
+		}
+		exporter = otlp
+	case "stdout":
+		stdout, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		exporter = stdout
+	case "", "none":
+		return nil
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// Shutdown отправляет накопленные спаны и останавливает экспорт
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Start открывает спан через глобальный TracerProvider
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End закрывает спан, отмечая ошибку, если она есть
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"homecloud-auth-service/config"
+)
+
+// Спаны пишутся в память; propagator настраивается так же, как при запуске сервиса
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if err := Init(context.Background(), config.TracingConfig{Exporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestClientInterceptorPropagatesTraceContext(t *testing.T) {
+	recorder := newRecorder(t)
+	ctx, parent := Start(context.Background(), "UserService.Register")
+
+	var outgoing metadata.MD
+	err := ClientInterceptor("file_service")(ctx, "/file.FileService/CreateUserDirectory", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			outgoing, _ = metadata.FromOutgoingContext(ctx)
+			return errors.New("unavailable")
+		})
+	parent.End()
+	if err == nil {
+		t.Fatal("interceptor must return the call error")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	client := spans[0]
+	if client.Name() != "file.FileService/CreateUserDirectory" || client.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("unexpected client span %q (%v)", client.Name(), client.SpanKind())
+	}
+	if client.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("client span must be a child of the service span")
+	}
+	if client.Status().Code != codes.Error {
+		t.Fatal("failed call must mark the span as error")
+	}
+
+	traceparent := outgoing.Get("traceparent")
+	if len(traceparent) != 1 {
+		t.Fatalf("traceparent not injected: %v", outgoing)
+	}
+	want := "00-" + client.SpanContext().TraceID().String() + "-" + client.SpanContext().SpanID().String() + "-01"
+	if traceparent[0] != want {
+		t.Fatalf("traceparent = %q, want %q", traceparent[0], want)
+	}
+}
+
+func TestServerSpansContinueIncomingTrace(t *testing.T) {
+	recorder := newRecorder(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", traceparent))
+	_, span := StartServerSpan(ctx, "/auth.AuthService/Login")
+	EndRPCSpan(span, nil)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/users/42", nil)
+	req.Header.Set("traceparent", traceparent)
+	_, span = StartHTTPSpan(req, "/api/v1/users/{id}")
+	EndHTTPSpan(span, http.StatusInternalServerError)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("span %q does not continue the incoming trace", span.Name())
+		}
+	}
+	if spans[0].Name() != "auth.AuthService/Login" || spans[0].Status().Code == codes.Error {
+		t.Errorf("unexpected rpc span %q (%v)", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "PATCH /api/v1/users/{id}" || spans[1].Status().Code != codes.Error {
+		t.Errorf("unexpected http span %q (%v)", spans[1].Name(), spans[1].Status())
+	}
+}
+
+func TestInitRejectsUnknownExporter(t *testing.T) {
+	if err := Init(context.Background(), config.TracingConfig{Exporter: "jaeger"}); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
@@ -4,39 +4,53 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
 	"homecloud-auth-service/config"
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/events"
 	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
 	"homecloud-auth-service/internal/security"
 	pb "homecloud-auth-service/internal/transport/grpc/protos"
+	"homecloud-auth-service/internal/validation"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type AuthServer struct {
 	pb.UnimplementedAuthServiceServer
 	userService interfaces.UserService
 	sec         *security.Security
+	watcher     interfaces.EventWatcher
 	cfg         *config.GrpcConfig
 	contxt      *context.Context
 }
 
-func NewAuthServer(ctx *context.Context, userService interfaces.UserService, sec *security.Security, cfg *config.GrpcConfig) *AuthServer {
+func NewAuthServer(ctx *context.Context, userService interfaces.UserService, sec *security.Security, watcher interfaces.EventWatcher, cfg *config.GrpcConfig) *AuthServer {
 	return &AuthServer{
 		userService: userService,
 		sec:         sec,
+		watcher:     watcher,
 		cfg:         cfg,
 		contxt:      ctx,
 	}
 }
 
 func (s *AuthServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
-	user, _, err := s.userService.Register(ctx, req.Email, req.Username, req.Password)
+	err := validation.Struct(models.RegisterRequest{Email: req.Email, Username: req.Username, Password: req.Password})
 	if err != nil {
-		return nil, fmt.Errorf("registration failed: %v", err)
+		return nil, rpcError(ctx, "registration failed", err)
+	}
+
+	user, err := s.userService.Register(ctx, req.Email, req.Username, req.Password)
+	if err != nil {
+		return nil, rpcError(ctx, "registration failed", err)
 	}
 
 	return &pb.RegisterResponse{
@@ -57,11 +71,51 @@ func (s *AuthServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb
 }
 
 func (s *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
-	user, token, err := s.userService.Login(ctx, req.Email, req.Password)
+	err := validation.Struct(models.LoginRequest{Email: req.Email, Password: req.Password, DeviceName: req.DeviceName})
 	if err != nil {
-		return nil, fmt.Errorf("login failed: %v", err)
+		return nil, rpcError(ctx, "login failed", err)
 	}
 
+	client := models.ClientInfoFromContext(ctx)
+	client.DeviceName = req.DeviceName
+	ctx = models.WithClientInfo(ctx, client)
+
+	user, tokens, err := s.userService.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		// Пароль верный, но нужен второй фактор
+		var mfa *errdefs.MFARequiredError
+		if errdefs.As(err, &mfa) {
+			return &pb.LoginResponse{
+				MfaRequired: true,
+				MfaToken:    mfa.ChallengeToken,
+				MfaMethods:  mfa.Methods,
+			}, nil
+		}
+		return nil, rpcError(ctx, "login failed", err)
+	}
+
+	return loginResponse(user, tokens), nil
+}
+
+func (s *AuthServer) LoginMFA(ctx context.Context, req *pb.LoginMFARequest) (*pb.LoginResponse, error) {
+	err := validation.Struct(models.MFALoginRequest{MFAToken: req.MfaToken, Method: req.Method, Code: req.Code, DeviceName: req.DeviceName})
+	if err != nil {
+		return nil, rpcError(ctx, "login failed", err)
+	}
+
+	client := models.ClientInfoFromContext(ctx)
+	client.DeviceName = req.DeviceName
+	ctx = models.WithClientInfo(ctx, client)
+
+	user, tokens, err := s.userService.LoginMFA(ctx, req.MfaToken, req.Method, req.Code)
+	if err != nil {
+		return nil, rpcError(ctx, "login failed", err)
+	}
+
+	return loginResponse(user, tokens), nil
+}
+
+func loginResponse(user *models.User, tokens *models.TokenPair) *pb.LoginResponse {
 	return &pb.LoginResponse{
 		User: &pb.AuthUser{
 			Id:              user.ID.String(),
@@ -76,14 +130,16 @@ func (s *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Login
 			CreatedAt:       user.CreatedAt.String(),
 			UpdatedAt:       user.UpdatedAt.String(),
 		},
-		Token: token,
-	}, nil
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}
 }
 
 func (s *AuthServer) GetUserProfile(ctx context.Context, req *pb.GetUserProfileRequest) (*pb.GetUserProfileResponse, error) {
 	user, err := s.userService.GetUserProfile(ctx, parseUUID(req.UserId))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user profile: %v", err)
+		return nil, rpcError(ctx, "failed to get user profile", err)
 	}
 
 	return &pb.GetUserProfileResponse{
@@ -115,9 +171,14 @@ func (s *AuthServer) UpdateUserProfile(ctx context.Context, req *pb.UpdateUserPr
 		newPassword = &req.NewPassword
 	}
 
-	err := s.userService.UpdateProfile(ctx, parseUUID(req.UserId), username, oldPassword, newPassword)
+	err := validation.Struct(models.UpdateProfileRequest{Username: username, OldPassword: oldPassword, NewPassword: newPassword})
+	if err != nil {
+		return nil, rpcError(ctx, "failed to update user profile", err)
+	}
+
+	err = s.userService.UpdateProfile(ctx, parseUUID(req.UserId), username, oldPassword, newPassword)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user profile: %v", err)
+		return nil, rpcError(ctx, "failed to update user profile", err)
 	}
 
 	return &pb.UpdateUserProfileResponse{}, nil
@@ -126,53 +187,279 @@ func (s *AuthServer) UpdateUserProfile(ctx context.Context, req *pb.UpdateUserPr
 func (s *AuthServer) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.VerifyEmailResponse, error) {
 	err := s.userService.VerifyEmail(ctx, req.Token)
 	if err != nil {
-		return nil, fmt.Errorf("email verification failed: %v", err)
+		return nil, rpcError(ctx, "email verification failed", err)
 	}
 
 	return &pb.VerifyEmailResponse{}, nil
 }
 
+func (s *AuthServer) ResendVerification(ctx context.Context, req *pb.ResendVerificationRequest) (*pb.ResendVerificationResponse, error) {
+	user, err := s.authenticate(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "token validation failed", err)
+	}
+
+	err = s.userService.ResendVerificationEmail(ctx, user.ID)
+	if err != nil {
+		return nil, rpcError(ctx, "resend verification failed", err)
+	}
+
+	return &pb.ResendVerificationResponse{}, nil
+}
+
+func (s *AuthServer) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordRequest) (*pb.ForgotPasswordResponse, error) {
+	err := validation.Struct(models.ForgotPasswordRequest{Email: req.Email})
+	if err != nil {
+		return nil, rpcError(ctx, "password reset request failed", err)
+	}
+
+	err = s.userService.RequestPasswordReset(ctx, req.Email)
+	if err != nil {
+		return nil, rpcError(ctx, "password reset request failed", err)
+	}
+
+	return &pb.ForgotPasswordResponse{}, nil
+}
+
+func (s *AuthServer) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	err := validation.Struct(models.ResetPasswordRequest{Token: req.Token, NewPassword: req.NewPassword})
+	if err != nil {
+		return nil, rpcError(ctx, "password reset failed", err)
+	}
+
+	err = s.userService.ResetPassword(ctx, req.Token, req.NewPassword)
+	if err != nil {
+		return nil, rpcError(ctx, "password reset failed", err)
+	}
+
+	return &pb.ResetPasswordResponse{}, nil
+}
+
+func (s *AuthServer) ChangeEmail(ctx context.Context, req *pb.ChangeEmailRequest) (*pb.ChangeEmailResponse, error) {
+	user, err := s.authenticate(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "token validation failed", err)
+	}
+
+	err = validation.Struct(models.ChangeEmailRequest{NewEmail: req.NewEmail, Password: req.Password})
+	if err != nil {
+		return nil, rpcError(ctx, "email change request failed", err)
+	}
+
+	err = s.userService.RequestEmailChange(ctx, user.ID, req.Password, req.NewEmail)
+	if err != nil {
+		return nil, rpcError(ctx, "email change request failed", err)
+	}
+
+	return &pb.ChangeEmailResponse{}, nil
+}
+
+func (s *AuthServer) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error) {
+	err := s.userService.ConfirmEmailChange(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "email change confirmation failed", err)
+	}
+
+	return &pb.ConfirmEmailChangeResponse{}, nil
+}
+
+func (s *AuthServer) RevertEmailChange(ctx context.Context, req *pb.RevertEmailChangeRequest) (*pb.RevertEmailChangeResponse, error) {
+	err := s.userService.RevertEmailChange(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "email change revert failed", err)
+	}
+
+	return &pb.RevertEmailChangeResponse{}, nil
+}
+
 func (s *AuthServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
 	err := s.userService.Logout(ctx, req.Token)
 	if err != nil {
-		return nil, fmt.Errorf("logout failed: %v", err)
+		return nil, rpcError(ctx, "logout failed", err)
 	}
 
 	return &pb.LogoutResponse{}, nil
 }
 
+func (s *AuthServer) LogoutAll(ctx context.Context, req *pb.LogoutAllRequest) (*pb.LogoutAllResponse, error) {
+	user, err := s.authenticate(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "token validation failed", err)
+	}
+
+	var before time.Time
+	if req.Before > 0 {
+		before = time.Unix(req.Before, 0)
+	}
+
+	err = s.userService.LogoutAll(ctx, user.ID, before)
+	if err != nil {
+		return nil, rpcError(ctx, "logout failed", err)
+	}
+
+	return &pb.LogoutAllResponse{}, nil
+}
+
 func (s *AuthServer) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
-	claims, err := s.sec.ValidateToken(req.Token)
+	// Проверка через сервис учитывает отозванные токены и статус пользователя
+	user, err := s.authenticate(ctx, req.Token)
 	if err != nil {
-		return nil, fmt.Errorf("token validation failed: %v", err)
+		return nil, rpcError(ctx, "token validation failed", err)
 	}
 
 	return &pb.ValidateTokenResponse{
 		User: &pb.AuthUser{
-			Id: claims.UserID.String(),
+			Id:              user.ID.String(),
+			Email:           user.Email,
+			Username:        user.Username,
+			IsActive:        user.IsActive,
+			IsEmailVerified: user.IsEmailVerified,
+			StorageQuota:    user.StorageQuota,
+			UsedSpace:       user.UsedSpace,
+			Role:            user.Role,
+			IsAdmin:         user.IsAdmin,
+			CreatedAt:       user.CreatedAt.String(),
+			UpdatedAt:       user.UpdatedAt.String(),
 		},
 	}, nil
 }
 
+func (s *AuthServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	tokens, err := s.userService.RefreshToken(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "token refresh failed", err)
+	}
+
+	return &pb.RefreshTokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}, nil
+}
+
+func (s *AuthServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	user, err := s.authenticate(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "token validation failed", err)
+	}
+
+	sessions, err := s.userService.ListSessions(ctx, user.ID)
+	if err != nil {
+		return nil, rpcError(ctx, "failed to list sessions", err)
+	}
+
+	resp := &pb.ListSessionsResponse{}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &pb.Session{
+			Id:         session.ID.String(),
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			Ip:         session.IP,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	return resp, nil
+}
+
+func (s *AuthServer) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	user, err := s.authenticate(ctx, req.Token)
+	if err != nil {
+		return nil, rpcError(ctx, "token validation failed", err)
+	}
+
+	err = s.userService.RevokeSession(ctx, user.ID, parseUUID(req.SessionId))
+	if err != nil {
+		return nil, rpcError(ctx, "failed to revoke session", err)
+	}
+
+	return &pb.RevokeSessionResponse{}, nil
+}
+
+// Виды изменений ленты в enum протокола
+var userChangeKinds = map[string]pb.UserChangeKind{
+	models.UserChangeCreated:      pb.UserChangeKind_USER_CREATED,
+	models.UserChangeUpdated:      pb.UserChangeKind_USER_UPDATED,
+	models.UserChangeDisabled:     pb.UserChangeKind_USER_DISABLED,
+	models.UserChangeQuotaChanged: pb.UserChangeKind_USER_QUOTA_CHANGED,
+}
+
+func (s *AuthServer) WatchUserEvents(req *pb.WatchUserEventsRequest, stream pb.AuthService_WatchUserEventsServer) error {
+	ctx := stream.Context()
+	err := s.watcher.Watch(ctx, req.Cursor, func(cursor string, entry *models.FeedEntry) error {
+		change := &pb.UserChangeEvent{
+			Cursor: cursor,
+			Kind:   userChangeKinds[entry.Kind],
+			Event:  events.EventToProto(&entry.Event),
+		}
+		// Текущее состояние избавляет клиента от отдельного GetUserProfile.
+		// Пользователя может уже не быть - тогда отправляется только событие.
+		if user, err := s.userService.GetUserByID(ctx, entry.Event.UserID); err == nil {
+			change.User = &pb.AuthUser{
+				Id:              user.ID.String(),
+				Email:           user.Email,
+				Username:        user.Username,
+				IsActive:        user.IsActive,
+				IsEmailVerified: user.IsEmailVerified,
+				StorageQuota:    user.StorageQuota,
+				UsedSpace:       user.UsedSpace,
+				Role:            user.Role,
+				IsAdmin:         user.IsAdmin,
+				CreatedAt:       user.CreatedAt.String(),
+				UpdatedAt:       user.UpdatedAt.String(),
+			}
+		}
+		return stream.Send(change)
+	})
+
+	if err != nil {
+		return rpcError(ctx, "watch user events failed", err)
+	}
+	return nil
+}
+
+// rpcError переводит ошибку сервиса в статус gRPC по таблице errdefs.
+// Клиент получает только безопасный текст, подробности серверных ошибок остаются в логе
+func rpcError(ctx context.Context, op string, err error) error {
+	if errdefs.IsServerError(err) {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, op, zap.Error(err))
+	}
+	return errdefs.GRPCStatus(err).Err()
+}
+
+// authenticate проверяет токен и запоминает пользователя для access-лога
+func (s *AuthServer) authenticate(ctx context.Context, token string) (*models.User, error) {
+	user, err := s.userService.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetAccessUserID(ctx, user.ID.String())
+	return user, nil
+}
+
 func parseUUID(id string) uuid.UUID {
 	u, _ := uuid.Parse(id)
 	return u
 }
 
 func (s *AuthServer) StartAuthServer() error {
+	ctx := *s.contxt
+	lg := logger.GetLoggerFromCtx(ctx)
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
-	fmt.Printf("Starting gRPC Auth Server on %s...\n", addr)
+	lg.Info(ctx, "Starting gRPC auth server", zap.String("addr", addr))
 
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		fmt.Printf("Failed to listen on %s: %v\n", addr, err)
+		lg.Error(ctx, "Failed to listen", zap.String("addr", addr), zap.Error(err))
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 
-	fmt.Printf("Successfully listening on %s\n", addr)
-
 	// Создаем gRPC сервер
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(requestUnaryInterceptor(lg), clientInfoInterceptor),
+		grpc.ChainStreamInterceptor(requestStreamInterceptor(lg)),
+	)
 
 	// Регистрируем сервис
 	pb.RegisterAuthServiceServer(grpcServer, s)
@@ -180,13 +467,10 @@ func (s *AuthServer) StartAuthServer() error {
 	// Включаем reflection для отладки
 	reflection.Register(grpcServer)
 
-	fmt.Printf("Successfully listening on %s\n", addr)
-	fmt.Printf("gRPC services registered and reflection enabled\n")
-
 	// Запускаем сервер
-	fmt.Printf("gRPC auth server is now serving on %s\n", addr)
+	lg.Info(ctx, "gRPC auth server is serving", zap.String("addr", addr))
 	if err := grpcServer.Serve(listener); err != nil {
-		fmt.Printf("gRPC server failed to serve: %v\n", err)
+		lg.Error(ctx, "gRPC server failed to serve", zap.Error(err))
 		return fmt.Errorf("failed to serve: %w", err)
 	}
 
@@ -194,7 +478,7 @@ func (s *AuthServer) StartAuthServer() error {
 }
 
 func (s *AuthServer) StopAuthServer() {
-	fmt.Printf("Shutdown signal received, stopping gRPC auth server...\n")
+	ctx := *s.contxt
 	// Здесь можно добавить graceful shutdown логику
-	fmt.Printf("gRPC auth server stopped gracefully on :%d\n", s.cfg.Port)
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "gRPC auth server stopped", zap.Int("port", s.cfg.Port))
 }
@@ -0,0 +1,124 @@
+package authServer
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/tracing"
+	pb "homecloud-auth-service/internal/transport/grpc/protos"
+)
+
+// Ключи метаданных gRPC передаются в нижнем регистре
+var requestIDMetadataKey = strings.ToLower(logger.RequestIDHeader)
+
+// requestUnaryInterceptor принимает x-request-id из метаданных или генерирует новый, возвращает его
+// в заголовках ответа, кладет ID и логгер в контекст и пишет строку access-лога
+func requestUnaryInterceptor(lg *logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, access := requestContext(ctx, lg)
+		ctx, span := tracing.StartServerSpan(ctx, info.FullMethod)
+
+		resp, err := handler(ctx, req)
+		tracing.EndRPCSpan(span, err)
+
+		userID := access.UserID()
+		if userID == "" {
+			userID = userIDFromMessages(req, resp)
+		}
+		logAccess(ctx, lg, info.FullMethod, err, start, userID)
+		return resp, err
+	}
+}
+
+// requestStreamInterceptor делает то же для потоковых вызовов; строка лога пишется по завершении потока
+func requestStreamInterceptor(lg *logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, access := requestContext(ss.Context(), lg)
+		ctx, span := tracing.StartServerSpan(ctx, info.FullMethod)
+
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		tracing.EndRPCSpan(span, err)
+
+		logAccess(ctx, lg, info.FullMethod, err, start, access.UserID())
+		return err
+	}
+}
+
+func requestContext(ctx context.Context, lg *logger.Logger) (context.Context, *logger.AccessInfo) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	requestID = logger.RequestIDOrNew(requestID)
+	// Ошибка возможна только если заголовки уже отправлены, чего до вызова обработчика не бывает
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, requestID))
+
+	ctx = logger.WithRequestID(logger.CtxWWithLogger(ctx, lg), requestID)
+	return logger.WithAccessInfo(ctx)
+}
+
+func logAccess(ctx context.Context, lg *logger.Logger, method string, err error, start time.Time, userID string) {
+	latency := time.Since(start)
+	code := status.Code(err).String()
+	metrics.GRPCRequestDuration.WithLabelValues(method, code).Observe(latency.Seconds())
+	lg.Info(ctx, "grpc request",
+		zap.String("method", method),
+		zap.String("code", code),
+		zap.Duration("latency", latency),
+		zap.String("user_id", userID),
+	)
+}
+
+// Пользователь из полей user_id запроса или user ответа, если обработчик его не отметил
+func userIDFromMessages(req, resp interface{}) string {
+	if withUser, ok := resp.(interface{ GetUser() *pb.AuthUser }); ok && withUser.GetUser() != nil {
+		return withUser.GetUser().GetId()
+	}
+	if withUserID, ok := req.(interface{ GetUserId() string }); ok {
+		return withUserID.GetUserId()
+	}
+	return ""
+}
+
+// contextStream подменяет контекст потока
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientInfoInterceptor сохраняет в контексте адрес, user-agent клиента и ID запроса
+func clientInfoInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var client models.ClientInfo
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			client.UserAgent = ua[0]
+		}
+	}
+	client.RequestID = logger.RequestIDFromCtx(ctx)
+	return handler(models.WithClientInfo(ctx, client), req)
+}
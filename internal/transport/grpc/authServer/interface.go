@@ -11,15 +11,24 @@ import (
 type AuthServiceServer interface {
 	// User operations
 	Register(ctx context.Context, email, username, password string) (*models.User, error)
-	Login(ctx context.Context, email, password string) (*models.User, string, error) // user, token, error
+	Login(ctx context.Context, email, password string) (*models.User, *models.TokenPair, error) // user, tokens, error
+	LoginMFA(ctx context.Context, mfaToken, method, code string) (*models.User, *models.TokenPair, error)
 	GetUserProfile(ctx context.Context, userID uuid.UUID) (*models.User, error)
 	UpdateUserProfile(ctx context.Context, userID uuid.UUID, username, oldPassword, newPassword *string) error
 	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, password, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	RevertEmailChange(ctx context.Context, token string) error
 	Logout(ctx context.Context, token string) error
+	// Лента изменений пользователей с продолжением с курсора
+	WatchUserEvents(ctx context.Context, cursor string, send func(cursor string, entry *models.FeedEntry) error) error
 
 	// Token operations
 	ValidateToken(ctx context.Context, token string) (*models.User, error)
-	RefreshToken(ctx context.Context, token string) (string, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*models.TokenPair, error)
 
 	// Server management
 	Start() error
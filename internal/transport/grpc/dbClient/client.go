@@ -6,11 +6,15 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/metrics"
 	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/tracing"
 	pb "homecloud-auth-service/internal/transport/grpc/protos"
 )
 
@@ -20,18 +24,25 @@ type DBServiceClientImpl struct {
 }
 
 // NewDBServiceClient создает новый клиент для взаимодействия с сервисом БД
-func NewDBServiceClient(host string, port int) (*DBServiceClientImpl, error) {
+func NewDBServiceClient(ctx context.Context, host string, port int) (*DBServiceClientImpl, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
 	addr := fmt.Sprintf("%s:%d", host, port)
-	fmt.Printf("Connecting to DB Manager at %s...\n", addr)
-
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	lg.Info(ctx, "Connecting to DB Manager", zap.String("addr", addr))
+
+	conn, err := grpc.Dial(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			tracing.ClientInterceptor("db_manager"),
+			metrics.ClientInterceptor("db_manager"),
+		),
+	)
 	if err != nil {
-		fmt.Printf("Failed to connect to DB Manager at %s: %v\n", addr, err)
+		lg.Error(ctx, "Failed to connect to DB Manager", zap.String("addr", addr), zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to db manager: %w", err)
 	}
 
 	client := pb.NewDBServiceClient(conn)
-	fmt.Printf("Successfully connected to DB Manager at %s\n", addr)
+	lg.Info(ctx, "Connected to DB Manager", zap.String("addr", addr))
 
 	return &DBServiceClientImpl{
 		conn:   conn,
@@ -11,7 +11,7 @@ import (
 
 func TestDBServiceClient(t *testing.T) {
 	// Создаем клиент с заглушками
-	client, err := NewDBServiceClient("localhost", 50051)
+	client, err := NewDBServiceClient(context.Background(), "localhost", 50051)
 	assert.NoError(t, err)
 	defer client.Close()
 
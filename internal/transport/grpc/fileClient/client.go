@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"time"
 
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/tracing"
 	"homecloud-auth-service/internal/transport/grpc/fileClient/protos"
 )
 
@@ -19,18 +23,25 @@ type FileServiceClientImpl struct {
 }
 
 // NewFileServiceClient создает новый клиент файлового сервиса
-func NewFileServiceClient(host string, port int) (*FileServiceClientImpl, error) {
+func NewFileServiceClient(ctx context.Context, host string, port int) (*FileServiceClientImpl, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
 	address := fmt.Sprintf("%s:%d", host, port)
-	fmt.Printf("Connecting to File Service at %s...\n", address)
+	lg.Info(ctx, "Connecting to File Service", zap.String("addr", address))
 
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			tracing.ClientInterceptor("file_service"),
+			metrics.ClientInterceptor("file_service"),
+		),
+	)
 	if err != nil {
-		fmt.Printf("Failed to connect to File Service at %s: %v\n", address, err)
+		lg.Error(ctx, "Failed to connect to File Service", zap.String("addr", address), zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to file service: %w", err)
 	}
 
 	client := protos.NewFileServiceClient(conn)
-	fmt.Printf("Successfully connected to File Service at %s\n", address)
+	lg.Info(ctx, "Connected to File Service", zap.String("addr", address))
 
 	return &FileServiceClientImpl{
 		conn:   conn,
@@ -57,6 +68,24 @@ func (c *FileServiceClientImpl) CreateUserDirectory(ctx context.Context, userID,
 	return response.Success, response.Message, response.DirectoryPath, nil
 }
 
+// DeleteUserDirectory удаляет домашнюю директорию пользователя
+func (c *FileServiceClientImpl) DeleteUserDirectory(ctx context.Context, userID string) (bool, string, error) {
+	// Добавляем таймаут к контексту
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	request := &protos.DeleteUserDirectoryRequest{
+		UserId: userID,
+	}
+
+	response, err := c.client.DeleteUserDirectory(ctx, request)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to delete user directory: %w", err)
+	}
+
+	return response.Success, response.Message, nil
+}
+
 // Close закрывает соединение с сервисом
 func (c *FileServiceClientImpl) Close() error {
 	if c.conn != nil {
@@ -9,6 +9,9 @@ type FileServiceClient interface {
 	// CreateUserDirectory создает домашнюю директорию для пользователя
 	CreateUserDirectory(ctx context.Context, userID, username string) (bool, string, string, error)
 
+	// DeleteUserDirectory удаляет домашнюю директорию пользователя, отсутствие директории - не ошибка
+	DeleteUserDirectory(ctx context.Context, userID string) (bool, string, error)
+
 	// Close закрывает соединение с сервисом
 	Close() error
 }
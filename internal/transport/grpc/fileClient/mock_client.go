@@ -30,6 +30,15 @@ func (m *MockFileServiceClient) CreateUserDirectory(ctx context.Context, userID,
 	return true, "Mock: Directory created successfully", path, nil
 }
 
+// DeleteUserDirectory удаляет домашнюю директорию пользователя (mock)
+func (m *MockFileServiceClient) DeleteUserDirectory(ctx context.Context, userID string) (bool, string, error) {
+	if m.shouldFail {
+		return false, "Mock: Directory deletion failed", fmt.Errorf("mock directory deletion error")
+	}
+
+	return true, "Mock: Directory deleted successfully", nil
+}
+
 // Close закрывает соединение с сервисом (mock)
 func (m *MockFileServiceClient) Close() error {
 	return nil
@@ -135,6 +135,104 @@ func (x *CreateUserDirectoryResponse) GetDirectoryPath() string {
 	return ""
 }
 
+// Запрос на удаление директории пользователя
+type DeleteUserDirectoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // UUID пользователя
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserDirectoryRequest) Reset() {
+	*x = DeleteUserDirectoryRequest{}
+	mi := &file_file_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDirectoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDirectoryRequest) ProtoMessage() {}
+
+func (x *DeleteUserDirectoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDirectoryRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserDirectoryRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeleteUserDirectoryRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+// Ответ на удаление директории пользователя
+type DeleteUserDirectoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"` // Успешность операции
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`  // Сообщение о результате
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserDirectoryResponse) Reset() {
+	*x = DeleteUserDirectoryResponse{}
+	mi := &file_file_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDirectoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDirectoryResponse) ProtoMessage() {}
+
+func (x *DeleteUserDirectoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDirectoryResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserDirectoryResponse) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeleteUserDirectoryResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeleteUserDirectoryResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 var File_file_service_proto protoreflect.FileDescriptor
 
 const file_file_service_proto_rawDesc = "" +
@@ -146,9 +244,15 @@ const file_file_service_proto_rawDesc = "" +
 	"\x1bCreateUserDirectoryResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
-	"\x0edirectory_path\x18\x03 \x01(\tR\rdirectoryPath2w\n" +
+	"\x0edirectory_path\x18\x03 \x01(\tR\rdirectoryPath\"5\n" +
+	"\x1aDeleteUserDirectoryRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"Q\n" +
+	"\x1bDeleteUserDirectoryResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage2\xe1\x01\n" +
 	"\vFileService\x12h\n" +
-	"\x13CreateUserDirectory\x12'.fileservice.CreateUserDirectoryRequest\x1a(.fileservice.CreateUserDirectoryResponseB\n" +
+	"\x13CreateUserDirectory\x12'.fileservice.CreateUserDirectoryRequest\x1a(.fileservice.CreateUserDirectoryResponse\x12h\n" +
+	"\x13DeleteUserDirectory\x12'.fileservice.DeleteUserDirectoryRequest\x1a(.fileservice.DeleteUserDirectoryResponseB\n" +
 	"Z\b./protosb\x06proto3"
 
 var (
@@ -163,16 +267,20 @@ func file_file_service_proto_rawDescGZIP() []byte {
 	return file_file_service_proto_rawDescData
 }
 
-var file_file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_file_service_proto_goTypes = []any{
 	(*CreateUserDirectoryRequest)(nil),  // 0: fileservice.CreateUserDirectoryRequest
 	(*CreateUserDirectoryResponse)(nil), // 1: fileservice.CreateUserDirectoryResponse
+	(*DeleteUserDirectoryRequest)(nil),  // 2: fileservice.DeleteUserDirectoryRequest
+	(*DeleteUserDirectoryResponse)(nil), // 3: fileservice.DeleteUserDirectoryResponse
 }
 var file_file_service_proto_depIdxs = []int32{
 	0, // 0: fileservice.FileService.CreateUserDirectory:input_type -> fileservice.CreateUserDirectoryRequest
-	1, // 1: fileservice.FileService.CreateUserDirectory:output_type -> fileservice.CreateUserDirectoryResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	2, // 1: fileservice.FileService.DeleteUserDirectory:input_type -> fileservice.DeleteUserDirectoryRequest
+	1, // 2: fileservice.FileService.CreateUserDirectory:output_type -> fileservice.CreateUserDirectoryResponse
+	3, // 3: fileservice.FileService.DeleteUserDirectory:output_type -> fileservice.DeleteUserDirectoryResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -189,7 +297,7 @@ func file_file_service_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_file_service_proto_rawDesc), len(file_file_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	FileService_CreateUserDirectory_FullMethodName = "/fileservice.FileService/CreateUserDirectory"
+	FileService_DeleteUserDirectory_FullMethodName = "/fileservice.FileService/DeleteUserDirectory"
 )
 
 // FileServiceClient is the client API for FileService service.
@@ -30,6 +31,9 @@ const (
 type FileServiceClient interface {
 	// Создание директории для пользователя при регистрации
 	CreateUserDirectory(ctx context.Context, in *CreateUserDirectoryRequest, opts ...grpc.CallOption) (*CreateUserDirectoryResponse, error)
+	// Удаление директории пользователя (откат неудачной регистрации).
+	// Отсутствие директории не считается ошибкой.
+	DeleteUserDirectory(ctx context.Context, in *DeleteUserDirectoryRequest, opts ...grpc.CallOption) (*DeleteUserDirectoryResponse, error)
 }
 
 type fileServiceClient struct {
@@ -50,6 +54,16 @@ func (c *fileServiceClient) CreateUserDirectory(ctx context.Context, in *CreateU
 	return out, nil
 }
 
+func (c *fileServiceClient) DeleteUserDirectory(ctx context.Context, in *DeleteUserDirectoryRequest, opts ...grpc.CallOption) (*DeleteUserDirectoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserDirectoryResponse)
+	err := c.cc.Invoke(ctx, FileService_DeleteUserDirectory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FileServiceServer is the server API for FileService service.
 // All implementations must embed UnimplementedFileServiceServer
 // for forward compatibility.
@@ -58,6 +72,9 @@ func (c *fileServiceClient) CreateUserDirectory(ctx context.Context, in *CreateU
 type FileServiceServer interface {
 	// Создание директории для пользователя при регистрации
 	CreateUserDirectory(context.Context, *CreateUserDirectoryRequest) (*CreateUserDirectoryResponse, error)
+	// Удаление директории пользователя (откат неудачной регистрации).
+	// Отсутствие директории не считается ошибкой.
+	DeleteUserDirectory(context.Context, *DeleteUserDirectoryRequest) (*DeleteUserDirectoryResponse, error)
 	mustEmbedUnimplementedFileServiceServer()
 }
 
@@ -71,6 +88,9 @@ type UnimplementedFileServiceServer struct{}
 func (UnimplementedFileServiceServer) CreateUserDirectory(context.Context, *CreateUserDirectoryRequest) (*CreateUserDirectoryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateUserDirectory not implemented")
 }
+func (UnimplementedFileServiceServer) DeleteUserDirectory(context.Context, *DeleteUserDirectoryRequest) (*DeleteUserDirectoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserDirectory not implemented")
+}
 func (UnimplementedFileServiceServer) mustEmbedUnimplementedFileServiceServer() {}
 func (UnimplementedFileServiceServer) testEmbeddedByValue()                     {}
 
@@ -110,6 +130,24 @@ func _FileService_CreateUserDirectory_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FileService_DeleteUserDirectory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserDirectoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).DeleteUserDirectory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileService_DeleteUserDirectory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).DeleteUserDirectory(ctx, req.(*DeleteUserDirectoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FileService_ServiceDesc is the grpc.ServiceDesc for FileService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -121,6 +159,10 @@ var FileService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateUserDirectory",
 			Handler:    _FileService_CreateUserDirectory_Handler,
 		},
+		{
+			MethodName: "DeleteUserDirectory",
+			Handler:    _FileService_DeleteUserDirectory_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "file_service.proto",
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const problemContentType = "application/problem+json"
+
+// Тип проблемы строится из машинного кода, например urn:homecloud:problem:auth.email_taken
+const problemTypePrefix = "urn:homecloud:problem:"
+
+// Ошибки разбора запроса, общие для обработчиков
+var (
+	errInvalidBody  = errdefs.Public(errdefs.ErrInvalidInput, "request.invalid_body", "invalid request body")
+	errMissingToken = errdefs.Public(errdefs.ErrUnauthorized, "auth.missing_token", "authorization header required")
+	errUnauthorized = errdefs.Public(errdefs.ErrUnauthorized, "auth.unauthorized", "unauthorized")
+	errForbidden    = errdefs.Public(errdefs.ErrForbidden, "auth.forbidden", "forbidden")
+	errNoSigningKey = errdefs.Public(errdefs.ErrNotFound, "keys.asymmetric_disabled", "asymmetric signing is disabled")
+	errNoRoute      = errdefs.Public(errdefs.ErrNotFound, "request.route_not_found", "route not found")
+	errNoMethod     = errdefs.Public(errdefs.ErrInvalidInput, "request.method_not_allowed", "method not allowed")
+)
+
+// writeError отвечает статусом из таблицы errdefs. Клиент получает только безопасный текст,
+// подробности серверных ошибок остаются в логе
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeErrorStatus(w, r, err, errdefs.HTTPStatus(err))
+}
+
+// writeErrorStatus - то же, но со статусом, который выбрал обработчик
+func writeErrorStatus(w http.ResponseWriter, r *http.Request, err error, status int) {
+	if status >= http.StatusInternalServerError {
+		logger.GetLoggerFromCtx(r.Context()).Error(r.Context(), "Request failed",
+			zap.String("path", r.URL.Path), zap.Error(err))
+	}
+
+	var limited *errdefs.RateLimitError
+	if errdefs.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	}
+
+	code := errdefs.Code(err)
+	problem := models.Problem{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    errdefs.PublicMessage(err),
+		Code:      code,
+		RequestID: logger.RequestIDFromCtx(r.Context()),
+	}
+	for _, field := range errdefs.Fields(err) {
+		problem.Errors = append(problem.Errors, models.ProblemField{
+			Field:   field.Field,
+			Code:    field.Code,
+			Message: field.Message,
+		})
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
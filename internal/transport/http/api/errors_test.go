@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Fatalf("content type = %q", ct)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, rec.Body.String())
+	}
+	return problem
+}
+
+func TestWriteErrorProblemWithStableCode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", nil)
+	req = req.WithContext(logger.WithRequestID(req.Context(), "req-7"))
+	rec := httptest.NewRecorder()
+
+	err := fmt.Errorf("register: %w", errdefs.Public(errdefs.ErrConflict, "auth.email_taken", "email already exists"))
+	writeError(rec, req, err)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	problem := decodeProblem(t, rec)
+	if problem.Code != "auth.email_taken" || problem.Type != "urn:homecloud:problem:auth.email_taken" {
+		t.Fatalf("unexpected code/type: %+v", problem)
+	}
+	if problem.Status != http.StatusConflict || problem.Title != "Conflict" || problem.Detail != "email already exists" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if problem.RequestID != "req-7" {
+		t.Fatalf("request id = %q", problem.RequestID)
+	}
+}
+
+func TestWriteErrorFieldViolations(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", nil)
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, &errdefs.ValidationError{Fields: []errdefs.FieldViolation{
+		{Field: "email", Code: "invalid_format", Message: "invalid email format"},
+		{Field: "password", Code: "too_short", Message: "password must be at least 6 characters"},
+	}})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	problem := decodeProblem(t, rec)
+	if problem.Code != errdefs.CodeValidationFailed || len(problem.Errors) != 2 {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if problem.Errors[1] != (models.ProblemField{Field: "password", Code: "too_short", Message: "password must be at least 6 characters"}) {
+		t.Fatalf("unexpected field error: %+v", problem.Errors[1])
+	}
+}
+
+func TestWriteErrorHidesInternals(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, errors.New("pq: connection to 10.0.0.5 refused"))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Fatalf("problem leaks internals: %s", rec.Body.String())
+	}
+	if problem := decodeProblem(t, rec); problem.Code != "service.internal" {
+		t.Fatalf("code = %q", problem.Code)
+	}
+}
+
+func TestWriteErrorRetryAfter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/verify/resend", nil)
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, &errdefs.RateLimitError{RetryAfter: 90 * time.Second})
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "90" {
+		t.Fatalf("status = %d, retry-after = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if problem := decodeProblem(t, rec); problem.Code != "request.rate_limited" {
+		t.Fatalf("code = %q", problem.Code)
+	}
+}
+
+func TestUnknownRouteIsProblem(t *testing.T) {
+	router := SetupRoutes(NewHandler(nil, nil, nil, nil), nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil))
+	if rec.Code != http.StatusNotFound || decodeProblem(t, rec).Code != "request.route_not_found" {
+		t.Fatalf("unexpected 404 response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/users/42", nil))
+	if rec.Code != http.StatusMethodNotAllowed || decodeProblem(t, rec).Code != "request.method_not_allowed" {
+		t.Fatalf("unexpected 405 response: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// Сервис-заглушка: принимает единственный токен, остальные методы не реализованы
+type tokenUserService struct {
+	interfaces.UserService
+	token string
+	user  *models.User
+}
+
+func (s *tokenUserService) ValidateToken(ctx context.Context, token string) (*models.User, error) {
+	if token != s.token {
+		return nil, errdefs.Public(errdefs.ErrUnauthorized, "auth.token_invalid", "invalid token")
+	}
+	return s.user, nil
+}
+
+func (s *tokenUserService) RecoveryCodesRemaining(ctx context.Context, userID uuid.UUID) (int, error) {
+	return 0, nil
+}
+
+func TestProtectedAuthRoutesReachable(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "user@example.com", Username: "user"}
+	router := SetupRoutes(NewHandler(&tokenUserService{token: "good", user: user}, nil, nil, nil), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer good")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "user@example.com") {
+		t.Fatalf("unexpected /me response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Без токена защищенный маршрут отвечает 401, а не 404 открытого подроутера
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/logout", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected /logout response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Неизвестный путь под /auth по-прежнему отвечает 404 в формате problem+json
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/auth/unknown", nil))
+	if rec.Code != http.StatusNotFound || decodeProblem(t, rec).Code != "request.route_not_found" {
+		t.Fatalf("unexpected 404 response: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecoveryCodesRequirePassword(t *testing.T) {
+	// Сервис не реализует RegenerateRecoveryCodes: запрос без пароля до него не доходит
+	user := &models.User{ID: uuid.New(), Email: "user@example.com", Username: "user"}
+	router := SetupRoutes(NewHandler(&tokenUserService{token: "good", user: user}, nil, nil, nil), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/2fa/recovery-codes", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	problem := decodeProblem(t, rec)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "password" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+}
+
+func TestPasskeyDeleteRequiresPassword(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "user@example.com", Username: "user"}
+	router := SetupRoutes(NewHandler(&tokenUserService{token: "good", user: user}, nil, nil, nil), nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/webauthn/credentials/cred-1", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	problem := decodeProblem(t, rec)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "password" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+}
+
+func TestRequestBodyValidatedBeforeService(t *testing.T) {
+	// Сервис не задан: до него запрос доходить не должен
+	router := SetupRoutes(NewHandler(nil, nil, nil, nil), nil)
+
+	body := strings.NewReader(`{"email":"user@@example.com","username":"../root","password":"secret1"}`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", body))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	problem := decodeProblem(t, rec)
+	if problem.Code != errdefs.CodeValidationFailed || len(problem.Errors) != 2 {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if problem.Errors[0].Field != "email" || problem.Errors[1].Code != "invalid_characters" {
+		t.Fatalf("unexpected field errors: %+v", problem.Errors)
+	}
+}
+
+func TestAuditQueryViolationNamesParameter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHandler(nil, nil, nil, nil).ListAuditRecords(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit?user_id=42", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if problem := decodeProblem(t, rec); len(problem.Errors) != 1 || problem.Errors[0].Field != "user_id" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
+	"homecloud-auth-service/internal/errdefs"
 	"homecloud-auth-service/internal/interfaces"
+	"homecloud-auth-service/internal/logger"
 	"homecloud-auth-service/internal/models"
 
 	"github.com/google/uuid"
@@ -14,11 +18,17 @@ import (
 
 type Handler struct {
 	userService interfaces.UserService
+	keys        interfaces.KeyManager // nil, если токены подписываются HS256
+	webhooks    interfaces.WebhookService
+	auditLog    interfaces.AuditLogger
 }
 
-func NewHandler(userService interfaces.UserService) *Handler {
+func NewHandler(userService interfaces.UserService, keys interfaces.KeyManager, webhooks interfaces.WebhookService, auditLog interfaces.AuditLogger) *Handler {
 	return &Handler{
 		userService: userService,
+		keys:        keys,
+		webhooks:    webhooks,
+		auditLog:    auditLog,
 	}
 }
 
@@ -50,14 +60,14 @@ func getUserFromContext(r *http.Request) (*models.User, error) {
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	user, _, err := h.userService.Register(r.Context(), req.Email, req.Username, req.Password)
+	user, err := h.userService.Register(r.Context(), req.Email, req.Username, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
@@ -78,19 +88,63 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	user, token, err := h.userService.Login(r.Context(), req.Email, req.Password)
+	client := models.ClientInfoFromContext(r.Context())
+	client.DeviceName = req.DeviceName
+	ctx := models.WithClientInfo(r.Context(), client)
+
+	user, tokens, err := h.userService.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		// Пароль верный, но нужен второй фактор - токены выдаст /login/mfa
+		var mfa *errdefs.MFARequiredError
+		if errdefs.As(err, &mfa) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.LoginResponse{
+				MFARequired: true,
+				MFAToken:    mfa.ChallengeToken,
+				MFAMethods:  mfa.Methods,
+			})
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	writeLoginResponse(w, user, tokens)
+}
+
+// Второй шаг входа при включенной 2FA
+// POST /api/v1/auth/login/mfa
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	client := models.ClientInfoFromContext(r.Context())
+	client.DeviceName = req.DeviceName
+	ctx := models.WithClientInfo(r.Context(), client)
+
+	user, tokens, err := h.userService.LoginMFA(ctx, req.MFAToken, req.Method, req.Code)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
+	writeLoginResponse(w, user, tokens)
+}
+
+func writeLoginResponse(w http.ResponseWriter, user *models.User, tokens *models.TokenPair) {
 	response := models.LoginResponse{
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
 		User: &models.UserInfo{
 			ID:       user.ID,
 			Email:    user.Email,
@@ -103,24 +157,56 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	tokens, err := h.userService.RefreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response := models.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // Получение профиля пользователя
 // GET /api/v1/auth/me
 func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	user, err := getUserFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	recoveryCodes, err := h.userService.RecoveryCodesRemaining(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	response := models.ProfileResponse{
-		ID:              user.ID,
-		Email:           user.Email,
-		Username:        user.Username,
-		Role:            user.Role,
-		IsActive:        user.IsActive,
-		IsEmailVerified: user.IsEmailVerified,
-		StorageQuota:    user.StorageQuota,
-		UsedSpace:       user.UsedSpace,
+		ID:                     user.ID,
+		Email:                  user.Email,
+		Username:               user.Username,
+		Role:                   user.Role,
+		IsActive:               user.IsActive,
+		IsEmailVerified:        user.IsEmailVerified,
+		StorageQuota:           user.StorageQuota,
+		UsedSpace:              user.UsedSpace,
+		TwoFactorEnabled:       user.TwoFactorEnabled,
+		RecoveryCodesRemaining: recoveryCodes,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -132,26 +218,415 @@ func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	token, err := extractToken(r)
 	if err != nil {
-		http.Error(w, "Authorization header required", http.StatusBadRequest)
+		writeError(w, r, errMissingToken)
 		return
 	}
 
 	err = h.userService.Logout(r.Context(), token)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Выход на всех устройствах
+// POST /api/v1/auth/logout-all
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	// Тело запроса необязательно
+	var req models.LogoutAllRequest
+	if err := decodeOptionalRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var before time.Time
+	if req.Before != nil {
+		before = *req.Before
+	}
+
+	err = h.userService.LogoutAll(r.Context(), user.ID, before)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// Список активных сессий пользователя
+// GET /api/v1/auth/sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	sessions, err := h.userService.ListSessions(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response := models.SessionListResponse{Sessions: make([]models.SessionResponse, 0, len(sessions))}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, models.SessionResponse{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Завершение сессии
+// DELETE /api/v1/auth/sessions/{id}
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errdefs.InvalidField("id", "invalid_format", "invalid session ID"))
+		return
+	}
+
+	err = h.userService.RevokeSession(r.Context(), user.ID, sessionID)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			writeError(w, r, errdefs.Public(errdefs.ErrNotFound, "auth.session_not_found", "session not found"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Начало подключения TOTP: секрет и otpauth:// ссылка для QR-кода
+// POST /api/v1/auth/2fa/totp/enroll
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	secret, uri, err := h.userService.EnrollTOTP(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+// Подтверждение подключения TOTP кодом из приложения
+// POST /api/v1/auth/2fa/totp/confirm
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.userService.ConfirmTOTP(r.Context(), user.ID, req.Code)
+	if err != nil {
+		// Неверный код при подключении - ошибка ввода, а не повод считать сессию недействительной
+		switch {
+		case errdefs.Is(err, errdefs.ErrInvalidMFACode):
+			writeErrorStatus(w, r, err, http.StatusBadRequest)
+		case errdefs.Is(err, errdefs.ErrNotFound):
+			writeError(w, r, errdefs.Public(errdefs.ErrNotFound, "auth.totp_not_enrolled", "totp enrollment not started"))
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Отключение 2FA по паролю
+// POST /api/v1/auth/2fa/totp/disable
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	var req models.DisableTwoFactorRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.userService.DisableTOTP(r.Context(), user.ID, req.Password)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Новый набор резервных кодов 2FA по паролю, предыдущий перестает действовать
+// POST /api/v1/auth/2fa/recovery-codes
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	var req models.RegenerateRecoveryCodesRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	codes, err := h.userService.RegenerateRecoveryCodes(r.Context(), user.ID, req.Password)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{Codes: codes})
+}
+
+// Начало регистрации passkey: параметры для navigator.credentials.create()
+// POST /api/v1/auth/webauthn/register/begin
+func (h *Handler) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	options, err := h.userService.BeginWebAuthnRegistration(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// Завершение регистрации passkey ответом браузера
+// POST /api/v1/auth/webauthn/register/finish
+func (h *Handler) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	var req models.WebAuthnRegisterFinishRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	credential, err := h.userService.FinishWebAuthnRegistration(r.Context(), user.ID, req.ChallengeID, req.Name, req.Password, &req.Credential)
+	if err != nil {
+		// Пользователь уже вошел: неудачная проверка ключа - ошибка запроса, а не входа
+		if errdefs.Is(err, errdefs.ErrWebAuthnFailed) {
+			writeErrorStatus(w, r, err, http.StatusBadRequest)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webAuthnCredentialResponse(credential))
+}
+
+// Начало входа по passkey: без пароля или вторым фактором по mfa_token
+// POST /api/v1/auth/webauthn/login/begin
+func (h *Handler) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.WebAuthnLoginBeginRequest
+	if err := decodeOptionalRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	options, err := h.userService.BeginWebAuthnLogin(r.Context(), req.Email, req.MFAToken)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// Завершение входа по passkey, выдает токены
+// POST /api/v1/auth/webauthn/login/finish
+func (h *Handler) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.WebAuthnLoginFinishRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	client := models.ClientInfoFromContext(r.Context())
+	client.DeviceName = req.DeviceName
+	ctx := models.WithClientInfo(r.Context(), client)
+
+	user, tokens, err := h.userService.FinishWebAuthnLogin(ctx, req.ChallengeID, &req.Credential)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeLoginResponse(w, user, tokens)
+}
+
+// Список passkey текущего пользователя
+// GET /api/v1/auth/webauthn/credentials
+func (h *Handler) ListWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	credentials, err := h.userService.ListWebAuthnCredentials(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response := models.WebAuthnCredentialListResponse{Credentials: make([]models.WebAuthnCredentialResponse, 0, len(credentials))}
+	for _, credential := range credentials {
+		response.Credentials = append(response.Credentials, webAuthnCredentialResponse(credential))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Удаление passkey
+// DELETE /api/v1/auth/webauthn/credentials/{id}
+func (h *Handler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	var req models.DeleteWebAuthnCredentialRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.userService.DeleteWebAuthnCredential(r.Context(), user.ID, mux.Vars(r)["id"], req.Password)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			writeError(w, r, errdefs.Public(errdefs.ErrNotFound, "auth.passkey_not_found", "credential not found"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func webAuthnCredentialResponse(credential *models.WebAuthnCredential) models.WebAuthnCredentialResponse {
+	return models.WebAuthnCredentialResponse{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
+
+// Запрос письма для сброса пароля. Ответ не зависит от того, есть ли такой email.
+// POST /api/v1/auth/password/forgot
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.userService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Установка нового пароля по токену из письма, все сессии завершаются
+// POST /api/v1/auth/password/reset
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err := h.userService.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Требования к паролю, чтобы клиент проверял его при вводе
+// GET /api/v1/auth/password-policy
+func (h *Handler) PasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.userService.PasswordPolicy())
+}
+
 // Обновление профиля пользователя
 // PATCH /api/v1/users/{id}
 func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	// Получение пользователя из контекста (аутентифицированный пользователь)
 	currentUser, err := getUserFromContext(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, r, errUnauthorized)
 		return
 	}
 
@@ -159,31 +634,31 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userIDStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeError(w, r, errdefs.InvalidField("id", "required", "user ID is required"))
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, r, errdefs.InvalidField("id", "invalid_format", "invalid user ID"))
 		return
 	}
 
 	// Проверка, что пользователь обновляет свой профиль
 	if currentUser.ID != userID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeError(w, r, errForbidden)
 		return
 	}
 
 	var req models.UpdateProfileRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	err = h.userService.UpdateProfile(r.Context(), userID, req.Username, req.OldPassword, req.NewPassword)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
@@ -195,40 +670,400 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
 	if token == "" {
-		http.Error(w, "Verification token is required", http.StatusBadRequest)
+		writeError(w, r, errdefs.InvalidField("token", "required", "verification token is required"))
 		return
 	}
 
 	err := h.userService.VerifyEmail(r.Context(), token)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeLinkError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Повторная отправка письма для верификации email
+// POST /api/v1/auth/verify/resend
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	err = h.userService.ResendVerificationEmail(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Запрос смены email: ссылка подтверждения уходит на новый адрес
+// POST /api/v1/auth/email/change
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		writeError(w, r, errUnauthorized)
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.userService.RequestEmailChange(r.Context(), user.ID, req.Password, req.NewEmail)
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrInvalidCredentials) {
+			writeError(w, r, errdefs.Public(errdefs.ErrInvalidCredentials, "auth.invalid_password", "invalid password"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Подтверждение нового email по ссылке из письма
+// GET /api/v1/auth/email/confirm?token=...
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, r, errdefs.InvalidField("token", "required", "email change token is required"))
+		return
+	}
+
+	if err := h.userService.ConfirmEmailChange(r.Context(), token); err != nil {
+		writeLinkError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// Отмена смены email по ссылке «это был не я», все сессии завершаются
+// GET /api/v1/auth/email/revert?token=...
+func (h *Handler) RevertEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, r, errdefs.InvalidField("token", "required", "email revert token is required"))
+		return
+	}
+
+	if err := h.userService.RevertEmailChange(r.Context(), token); err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Ошибки ссылок из писем. Битая ссылка - ошибка запроса, а не аутентификации
+func writeLinkError(w http.ResponseWriter, r *http.Request, err error) {
+	if errdefs.Is(err, errdefs.ErrInvalidToken) {
+		writeErrorStatus(w, r, err, http.StatusBadRequest)
+		return
+	}
+	writeError(w, r, err)
+}
+
 // Middleware для аутентификации
 func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token, err := extractToken(r)
 		if err != nil {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			writeError(w, r, errMissingToken)
 			return
 		}
 
 		user, err := h.userService.ValidateToken(r.Context(), token)
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			// По коду клиент отличает протухший токен от заблокированного аккаунта,
+			// а сбой хранилища - от недействительного токена
+			writeError(w, r, err)
 			return
 		}
 
-		// Добавляем пользователя в контекст
-		ctx := context.WithValue(r.Context(), "user", user)
+		// Добавляем пользователя в контекст; он же исполнитель действий для журнала аудита
+		logger.SetAccessUserID(r.Context(), user.ID.String())
+		client := models.ClientInfoFromContext(r.Context())
+		client.ActorID = user.ID
+		ctx := models.WithClientInfo(r.Context(), client)
+		ctx = context.WithValue(ctx, "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// Middleware для маршрутов администратора, применяется после AuthMiddleware
+func (h *Handler) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := getUserFromContext(r)
+		if err != nil {
+			writeError(w, r, errUnauthorized)
+			return
+		}
+		if !user.IsAdmin {
+			writeError(w, r, errForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// Открытые ключи для офлайн-проверки access токенов
+// GET /.well-known/jwks.json
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if h.keys == nil {
+		writeError(w, r, errNoSigningKey)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
+
+// Ручная ротация ключа подписи
+// POST /api/v1/admin/keys/rotate
+func (h *Handler) RotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	if h.keys == nil {
+		writeError(w, r, errNoSigningKey)
+		return
+	}
+
+	key, err := h.keys.Rotate()
+	if err != nil {
+		h.auditLog.Record(r.Context(), &models.AuditRecord{
+			Action:  models.AuditAdminKeyRotate,
+			Outcome: models.AuditFailure,
+			Reason:  err.Error(),
+		})
+		writeError(w, r, err)
+		return
+	}
+	h.auditLog.Record(r.Context(), &models.AuditRecord{
+		Action:  models.AuditAdminKeyRotate,
+		Outcome: models.AuditSuccess,
+		Details: map[string]string{"kid": key.ID},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RotateKeyResponse{
+		KeyID:     key.ID,
+		Algorithm: key.Algorithm,
+		CreatedAt: key.CreatedAt,
+	})
+}
+
+// Блокировка аккаунта и изменение квоты администратором
+// PATCH /api/v1/admin/users/{id}
+func (h *Handler) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errdefs.InvalidField("id", "invalid_format", "invalid user ID"))
+		return
+	}
+
+	var req models.AdminUpdateUserRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if req.IsActive == nil && req.StorageQuota == nil {
+		writeError(w, r, errdefs.Public(errdefs.ErrInvalidInput, "request.nothing_to_update", "nothing to update"))
+		return
+	}
+
+	if err := h.userService.AdminUpdateUser(r.Context(), userID, req.IsActive, req.StorageQuota); err != nil {
+		switch {
+		case errdefs.Is(err, errdefs.ErrNotFound):
+			writeError(w, r, errdefs.Public(errdefs.ErrNotFound, "users.not_found", "user not found"))
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Подписка на вебхуки. Секрет подписи возвращается только в этом ответе.
+// POST /api/v1/admin/webhooks
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWebhookRequest
+	if err := decodeRequest(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	subscription, err := h.webhooks.CreateSubscription(r.Context(), &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response := webhookResponse(subscription)
+	response.Secret = subscription.Secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Список подписок на вебхуки без секретов
+// GET /api/v1/admin/webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.webhooks.ListSubscriptions(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response := models.WebhookListResponse{Webhooks: make([]models.WebhookResponse, 0, len(subscriptions))}
+	for _, subscription := range subscriptions {
+		response.Webhooks = append(response.Webhooks, webhookResponse(subscription))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Удаление подписки вместе с ее недоставленными вебхуками
+// DELETE /api/v1/admin/webhooks/{id}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errdefs.InvalidField("id", "invalid_format", "invalid webhook ID"))
+		return
+	}
+
+	if err := h.webhooks.DeleteSubscription(r.Context(), id); err != nil {
+		if errdefs.Is(err, errdefs.ErrNotFound) {
+			writeError(w, r, errdefs.Public(errdefs.ErrNotFound, "webhooks.not_found", "webhook not found"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Доставки, исчерпавшие попытки
+// GET /api/v1/admin/webhooks/dead-letters
+func (h *Handler) ListWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.webhooks.ListDeadLetters(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WebhookDeliveryListResponse{Deliveries: deliveries})
+}
+
+// Повторная отправка доставки из dead letter
+// POST /api/v1/admin/webhooks/deliveries/{id}/replay
+func (h *Handler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errdefs.InvalidField("id", "invalid_format", "invalid delivery ID"))
+		return
+	}
+
+	if err := h.webhooks.ReplayDelivery(r.Context(), id); err != nil {
+		switch {
+		case errdefs.Is(err, errdefs.ErrNotFound):
+			writeError(w, r, errdefs.Public(errdefs.ErrNotFound, "webhooks.delivery_not_found", "delivery not found"))
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func webhookResponse(subscription *models.WebhookSubscription) models.WebhookResponse {
+	events := subscription.Events
+	if events == nil {
+		events = []string{}
+	}
+	return models.WebhookResponse{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    events,
+		CreatedAt: subscription.CreatedAt,
+	}
+}
+
+// Журнал аудита, отфильтрованный по пользователю (исполнитель или цель) и периоду
+// GET /api/v1/admin/audit?user_id=...&from=...&to=...&limit=...
+func (h *Handler) ListAuditRecords(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{Limit: 100}
+
+	if raw := query.Get("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			writeError(w, r, errdefs.InvalidField("user_id", "invalid_format", "invalid user ID"))
+			return
+		}
+		filter.UserID = userID
+	}
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, r, errdefs.InvalidField(name, "invalid_format", "invalid %s time, RFC 3339 expected", name))
+			return
+		}
+		*target = parsed
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > 1000 {
+			writeError(w, r, errdefs.InvalidField("limit", "out_of_range", "limit must be between 1 and 1000"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	records, err := h.auditLog.Query(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if records == nil {
+		records = []*models.AuditRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AuditListResponse{Records: records})
+}
+
+// Проверка цепочки хешей журнала аудита
+// GET /api/v1/admin/audit/verify
+func (h *Handler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	verified, err := h.auditLog.Verify(r.Context())
+	response := models.AuditVerifyResponse{Valid: err == nil, Verified: verified}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // Health check endpoint
 // GET /health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/metrics"
+	"homecloud-auth-service/internal/models"
+	"homecloud-auth-service/internal/tracing"
+)
+
+// RequestLogMiddleware принимает X-Request-ID клиента или генерирует новый, возвращает его в ответе,
+// кладет ID и логгер в контекст и пишет строку access-лога для каждого запроса, включая 404.
+// Роутер нужен, чтобы записать шаблон маршрута вместо пути с идентификаторами
+func RequestLogMiddleware(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+		lg := logger.GetLoggerFromCtx(ctx)
+
+		requestID := logger.RequestIDOrNew(r.Header.Get(logger.RequestIDHeader))
+		ctx = logger.WithRequestID(logger.CtxWWithLogger(ctx, lg), requestID)
+		ctx, access := logger.WithAccessInfo(ctx)
+		w.Header().Set(logger.RequestIDHeader, requestID)
+
+		route := "unmatched"
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			if template, err := match.Route.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := tracing.StartHTTPSpan(r.WithContext(ctx), route)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		router.ServeHTTP(recorder, r.WithContext(ctx))
+		tracing.EndHTTPSpan(span, recorder.status)
+
+		latency := time.Since(start)
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Observe(latency.Seconds())
+		lg.Info(ctx, "http request",
+			zap.String("method", r.Method),
+			zap.String("route", route),
+			zap.Int("status", recorder.status),
+			zap.Duration("latency", latency),
+			zap.String("user_id", access.UserID()),
+		)
+	})
+}
+
+// statusRecorder запоминает код ответа для access-лога
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush нужен потоковым ответам
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// TrustedProxies - адреса обратных прокси, которым разрешено передавать IP клиента в заголовках
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies разбирает список адресов и подсетей CIDR из конфигурации
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) contains(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientInfoMiddleware сохраняет в контексте IP, User-Agent клиента и ID запроса
+func ClientInfoMiddleware(proxies TrustedProxies) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := logger.RequestIDFromCtx(r.Context())
+			if requestID == "" {
+				requestID = r.Header.Get(logger.RequestIDHeader)
+			}
+			ctx := models.WithClientInfo(r.Context(), models.ClientInfo{
+				IP:        proxies.clientIP(r),
+				UserAgent: r.UserAgent(),
+				RequestID: requestID,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Определение IP клиента. Заголовки X-Forwarded-For и X-Real-IP учитываются, только если
+// запрос пришел от доверенного прокси: иначе клиент подставил бы в сессию и аудит любой адрес.
+// В X-Forwarded-For берется самый правый адрес, не принадлежащий доверенным прокси -
+// левее него значения записаны самим клиентом
+func (p TrustedProxies) clientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if ip := net.ParseIP(remote); ip == nil || !p.contains(ip) {
+		return remote
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				// Мусор в цепочке: дальше влево доверять нечему
+				return remote
+			}
+			if !p.contains(ip) {
+				return hop
+			}
+			remote = hop
+		}
+		return remote
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remote
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"homecloud-auth-service/internal/logger"
+	"homecloud-auth-service/internal/models"
+)
+
+func newAccessLogRouter(t *testing.T) (http.Handler, *bytes.Buffer, *string) {
+	t.Helper()
+	var seenRequestID string
+	router := mux.NewRouter()
+	router.Use(ClientInfoMiddleware(nil))
+	router.HandleFunc("/api/v1/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		seenRequestID = models.ClientInfoFromContext(r.Context()).RequestID
+		logger.SetAccessUserID(r.Context(), mux.Vars(r)["id"])
+		w.WriteHeader(http.StatusAccepted)
+	}).Methods("PATCH")
+
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	lg := logger.FromZap(zap.New(core))
+	handler := RequestLogMiddleware(router)
+	withLogger := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(logger.CtxWWithLogger(r.Context(), lg)))
+	})
+	return withLogger, &buf, &seenRequestID
+}
+
+func decodeAccessLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var line map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &line); err != nil {
+		t.Fatalf("access log is not JSON: %v\n%s", err, buf.String())
+	}
+	return line
+}
+
+func TestRequestLogMiddlewareKeepsClientRequestID(t *testing.T) {
+	handler, buf, seen := newAccessLogRouter(t)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/users/42", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Fatalf("response request id = %q", got)
+	}
+	if *seen != "req-42" {
+		t.Fatalf("handler saw request id %q", *seen)
+	}
+	line := decodeAccessLine(t, buf)
+	want := map[string]any{
+		"msg":       "http request",
+		"method":    "PATCH",
+		"route":     "/api/v1/users/{id}",
+		"status":    float64(http.StatusAccepted),
+		"user_id":   "42",
+		"RequestID": "req-42",
+	}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("%s = %v, want %v", key, line[key], value)
+		}
+	}
+	if _, ok := line["latency"]; !ok {
+		t.Error("latency missing")
+	}
+}
+
+func TestRequestLogMiddlewareGeneratesRequestID(t *testing.T) {
+	handler, buf, _ := newAccessLogRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil).WithContext(context.Background())
+	req.Header.Set("X-Request-ID", "not valid\r\n")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	generated := rec.Header().Get("X-Request-ID")
+	if generated == "" || strings.ContainsAny(generated, " \r\n") {
+		t.Fatalf("expected generated request id, got %q", generated)
+	}
+	line := decodeAccessLine(t, buf)
+	if line["status"] != float64(http.StatusNotFound) || line["route"] != "unmatched" || line["RequestID"] != generated {
+		t.Fatalf("unexpected access line: %v", line)
+	}
+}
+
+func TestClientIPIgnoresSpoofedForwardingHeaders(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("parse proxies: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"untrusted peer sends XFF", "203.0.113.9:5000", "1.2.3.4", "", "203.0.113.9"},
+		{"untrusted peer sends X-Real-IP", "203.0.113.9:5000", "", "1.2.3.4", "203.0.113.9"},
+		{"trusted proxy, client prepends fake hop", "10.0.0.2:5000", "1.2.3.4, 198.51.100.7", "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.2:5000", "1.2.3.4, 198.51.100.7, 192.168.1.1, 10.0.0.3", "", "198.51.100.7"},
+		{"garbage hop", "10.0.0.2:5000", "1.2.3.4, not-an-ip", "", "10.0.0.2"},
+		{"trusted proxy, X-Real-IP", "192.168.1.1:5000", "", "198.51.100.7", "198.51.100.7"},
+		{"trusted proxy, no headers", "10.0.0.2:5000", "", "", "10.0.0.2"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := proxies.clientIP(req); got != tc.want {
+			t.Errorf("%s: client ip = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Error("expected invalid proxy entry to fail")
+	}
+}
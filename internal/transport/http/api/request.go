@@ -0,0 +1,26 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"homecloud-auth-service/internal/validation"
+)
+
+// decodeRequest разбирает JSON-тело и проверяет его по тегам validate модели.
+// Нарушения возвращаются по всем полям сразу
+func decodeRequest(r *http.Request, dst interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		return errInvalidBody
+	}
+	return validation.Struct(dst)
+}
+
+// decodeOptionalRequest - то же для запросов, где тело можно не передавать
+func decodeOptionalRequest(r *http.Request, dst interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil && err != io.EOF {
+		return errInvalidBody
+	}
+	return validation.Struct(dst)
+}
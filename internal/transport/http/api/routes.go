@@ -4,14 +4,25 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"homecloud-auth-service/internal/metrics"
 )
 
-func SetupRoutes(handler *Handler) *mux.Router {
+// proxies - доверенные обратные прокси, от которых принимается IP клиента в заголовках
+func SetupRoutes(handler *Handler, proxies TrustedProxies) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(ClientInfoMiddleware(proxies))
+	withProblemFallbacks(router)
 
 	// Health check
 	router.HandleFunc("/health", handler.HealthCheck).Methods("GET")
 
+	// Метрики Prometheus
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Открытые ключи подписи токенов
+	router.HandleFunc("/.well-known/jwks.json", handler.JWKS).Methods("GET")
+
 	// API v1
 	apiV1 := router.PathPrefix("/api/v1").Subrouter()
 
@@ -19,15 +30,35 @@ func SetupRoutes(handler *Handler) *mux.Router {
 	auth := apiV1.PathPrefix("/auth").Subrouter()
 	auth.HandleFunc("/register", handler.Register).Methods("POST")
 	auth.HandleFunc("/login", handler.Login).Methods("POST")
+	auth.HandleFunc("/login/mfa", handler.LoginMFA).Methods("POST")
+	auth.HandleFunc("/webauthn/login/begin", handler.BeginWebAuthnLogin).Methods("POST")
+	auth.HandleFunc("/webauthn/login/finish", handler.FinishWebAuthnLogin).Methods("POST")
+	auth.HandleFunc("/refresh", handler.Refresh).Methods("POST")
+	auth.HandleFunc("/password/forgot", handler.ForgotPassword).Methods("POST")
+	auth.HandleFunc("/password/reset", handler.ResetPassword).Methods("POST")
+	auth.HandleFunc("/password-policy", handler.PasswordPolicy).Methods("GET")
 	auth.HandleFunc("/verify", handler.VerifyEmail).Methods("GET")
+	auth.HandleFunc("/email/confirm", handler.ConfirmEmailChange).Methods("GET")
+	auth.HandleFunc("/email/revert", handler.RevertEmailChange).Methods("GET")
 
-	// Защищенные маршруты (требуют авторизации)
-	protected := apiV1.PathPrefix("/auth").Subrouter()
-	protected.Use(mux.MiddlewareFunc(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(handler.AuthMiddleware(next.ServeHTTP))
-	}))
-	protected.HandleFunc("/me", handler.GetProfile).Methods("GET")
-	protected.HandleFunc("/logout", handler.Logout).Methods("POST")
+	// Защищенные маршруты (требуют авторизации). Они делят префикс /auth с открытыми,
+	// поэтому живут в том же подроутере, а авторизация навешивается на каждый маршрут
+	authed := handler.AuthMiddleware
+	auth.HandleFunc("/me", authed(handler.GetProfile)).Methods("GET")
+	auth.HandleFunc("/logout", authed(handler.Logout)).Methods("POST")
+	auth.HandleFunc("/verify/resend", authed(handler.ResendVerification)).Methods("POST")
+	auth.HandleFunc("/email/change", authed(handler.ChangeEmail)).Methods("POST")
+	auth.HandleFunc("/logout-all", authed(handler.LogoutAll)).Methods("POST")
+	auth.HandleFunc("/sessions", authed(handler.ListSessions)).Methods("GET")
+	auth.HandleFunc("/sessions/{id}", authed(handler.RevokeSession)).Methods("DELETE")
+	auth.HandleFunc("/2fa/totp/enroll", authed(handler.EnrollTOTP)).Methods("POST")
+	auth.HandleFunc("/2fa/totp/confirm", authed(handler.ConfirmTOTP)).Methods("POST")
+	auth.HandleFunc("/2fa/totp/disable", authed(handler.DisableTOTP)).Methods("POST")
+	auth.HandleFunc("/2fa/recovery-codes", authed(handler.RegenerateRecoveryCodes)).Methods("POST")
+	auth.HandleFunc("/webauthn/register/begin", authed(handler.BeginWebAuthnRegistration)).Methods("POST")
+	auth.HandleFunc("/webauthn/register/finish", authed(handler.FinishWebAuthnRegistration)).Methods("POST")
+	auth.HandleFunc("/webauthn/credentials", authed(handler.ListWebAuthnCredentials)).Methods("GET")
+	auth.HandleFunc("/webauthn/credentials/{id}", authed(handler.DeleteWebAuthnCredential)).Methods("DELETE")
 
 	// Управление пользователями (требуют авторизации)
 	users := apiV1.PathPrefix("/users").Subrouter()
@@ -36,5 +67,41 @@ func SetupRoutes(handler *Handler) *mux.Router {
 	}))
 	users.HandleFunc("/{id}", handler.UpdateProfile).Methods("PATCH")
 
+	// Администрирование (требуют прав администратора)
+	admin := apiV1.PathPrefix("/admin").Subrouter()
+	admin.Use(mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(handler.AuthMiddleware(handler.AdminMiddleware(next.ServeHTTP)))
+	}))
+	admin.HandleFunc("/keys/rotate", handler.RotateSigningKey).Methods("POST")
+	admin.HandleFunc("/users/{id}", handler.AdminUpdateUser).Methods("PATCH")
+	admin.HandleFunc("/audit", handler.ListAuditRecords).Methods("GET")
+	admin.HandleFunc("/audit/verify", handler.VerifyAuditLog).Methods("GET")
+	admin.HandleFunc("/webhooks", handler.CreateWebhook).Methods("POST")
+	admin.HandleFunc("/webhooks", handler.ListWebhooks).Methods("GET")
+	admin.HandleFunc("/webhooks/dead-letters", handler.ListWebhookDeadLetters).Methods("GET")
+	admin.HandleFunc("/webhooks/deliveries/{id}/replay", handler.ReplayWebhookDelivery).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}", handler.DeleteWebhook).Methods("DELETE")
+
+	withMethodFallback(apiV1, auth, users, admin)
+
 	return router
 }
+
+// Ответы 404 и 405 тоже в формате problem+json
+func withProblemFallbacks(router *mux.Router) {
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, errNoRoute)
+	})
+	withMethodFallback(router)
+}
+
+// mux теряет несовпадение метода, когда после подроутера проверяются другие маршруты,
+// поэтому обработчик 405 ставится и на подроутеры. NotFoundHandler на них ставить нельзя:
+// подроутер с ним считается совпавшим для любого пути под своим префиксом
+func withMethodFallback(routers ...*mux.Router) {
+	for _, router := range routers {
+		router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeErrorStatus(w, r, errNoMethod, http.StatusMethodNotAllowed)
+		})
+	}
+}
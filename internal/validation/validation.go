@@ -0,0 +1,359 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+
+	"homecloud-auth-service/internal/errdefs"
+)
+
+// Struct проверяет поля структуры по тегам validate и возвращает *errdefs.ValidationError
+// со всеми нарушениями или nil. Поля называются по тегу json, вложенные - через точку.
+//
+// Поддерживаемые правила: required, omitempty, min, max, len, numeric, email, url,
+// oneof, eq, username. Неизвестное правило - ошибка в модели, поэтому Struct паникует
+func Struct(v interface{}) error {
+	var fields []errdefs.FieldViolation
+	walk(reflect.ValueOf(v), "", &fields)
+	if len(fields) > 0 {
+		return &errdefs.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// Join объединяет нарушения нескольких проверок, чтобы клиент получил их сразу.
+// Ошибка, не связанная с валидацией, возвращается как есть
+func Join(errs ...error) error {
+	var fields []errdefs.FieldViolation
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var invalid *errdefs.ValidationError
+		if !errdefs.As(err, &invalid) {
+			return err
+		}
+		fields = append(fields, invalid.Fields...)
+	}
+	if len(fields) > 0 {
+		return &errdefs.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// rule проверяет значение; nil - значение подходит
+type rule func(field string, v reflect.Value, param string) *errdefs.FieldViolation
+
+var rules map[string]rule
+
+func init() {
+	rules = map[string]rule{
+		"min":      checkMin,
+		"max":      checkMax,
+		"len":      checkLen,
+		"numeric":  checkNumeric,
+		"email":    checkEmail,
+		"url":      checkURL,
+		"oneof":    checkOneOf,
+		"eq":       checkEq,
+		"username": checkUsername,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func walk(v reflect.Value, prefix string, out *[]errdefs.FieldViolation) {
+	v = indirect(v)
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous {
+			walk(fv, prefix, out)
+			continue
+		}
+
+		name := prefix + fieldName(sf)
+		tag := sf.Tag.Get("validate")
+		if tag != "" && tag != "-" {
+			if violation := check(name, fv, tag); violation != nil {
+				*out = append(*out, *violation)
+				continue
+			}
+		}
+		// Необязательную вложенную структуру, которую не прислали, не проверяем
+		if inner := indirect(fv); !inner.IsValid() || (inner.IsZero() && hasRule(tag, "omitempty")) {
+			continue
+		}
+		walk(fv, name+".", out)
+	}
+}
+
+// check применяет правила тега по порядку и возвращает первое нарушение
+func check(field string, v reflect.Value, tag string) *errdefs.FieldViolation {
+	v = indirect(v)
+	zero := !v.IsValid() || v.IsZero()
+
+	for _, r := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(r, "=")
+		switch key {
+		case "omitempty":
+			if zero {
+				return nil
+			}
+		case "required":
+			if zero {
+				return violation(field, "required", "%s is required", field)
+			}
+		default:
+			fn, ok := rules[key]
+			if !ok {
+				panic(fmt.Sprintf("validation: unknown rule %q on field %s", key, field))
+			}
+			if !v.IsValid() {
+				continue
+			}
+			if violation := fn(field, v, param); violation != nil {
+				return violation
+			}
+		}
+	}
+	return nil
+}
+
+func hasRule(tag, name string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func violation(field, code, format string, args ...interface{}) *errdefs.FieldViolation {
+	return &errdefs.FieldViolation{Field: field, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Размер значения для min/max/len: символы строки, элементы коллекции или само число
+func size(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters", true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), "items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
+
+func limit(field string, v reflect.Value, param string) (float64, float64, string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid parameter %q on field %s", param, field))
+	}
+	actual, unit, ok := size(v)
+	if !ok {
+		panic(fmt.Sprintf("validation: size rule on unsupported field %s", field))
+	}
+	if unit != "" {
+		unit = " " + unit
+	}
+	return actual, n, unit
+}
+
+func checkMin(field string, v reflect.Value, param string) *errdefs.FieldViolation {
+	if actual, n, unit := limit(field, v, param); actual < n {
+		if v.Kind() == reflect.String {
+			return violation(field, "too_short", "%s must be at least %s%s", field, param, unit)
+		}
+		return violation(field, "too_small", "%s must be at least %s%s", field, param, unit)
+	}
+	return nil
+}
+
+func checkMax(field string, v reflect.Value, param string) *errdefs.FieldViolation {
+	if actual, n, unit := limit(field, v, param); actual > n {
+		if v.Kind() == reflect.String {
+			return violation(field, "too_long", "%s must be at most %s%s", field, param, unit)
+		}
+		return violation(field, "too_large", "%s must be at most %s%s", field, param, unit)
+	}
+	return nil
+}
+
+func checkLen(field string, v reflect.Value, param string) *errdefs.FieldViolation {
+	if actual, n, unit := limit(field, v, param); actual != n {
+		return violation(field, "length", "%s must be exactly %s%s", field, param, unit)
+	}
+	return nil
+}
+
+func checkNumeric(field string, v reflect.Value, _ string) *errdefs.FieldViolation {
+	s := v.String()
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return violation(field, "not_numeric", "%s must contain only digits", field)
+		}
+	}
+	return nil
+}
+
+func checkEmail(field string, v reflect.Value, _ string) *errdefs.FieldViolation {
+	if !IsEmail(v.String()) {
+		return violation(field, "invalid_format", "%s must be a valid email address", field)
+	}
+	return nil
+}
+
+func checkURL(field string, v reflect.Value, _ string) *errdefs.FieldViolation {
+	parsed, err := url.Parse(v.String())
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return violation(field, "invalid_url", "%s must be an absolute http(s) url", field)
+	}
+	return nil
+}
+
+func checkOneOf(field string, v reflect.Value, param string) *errdefs.FieldViolation {
+	allowed := strings.Fields(param)
+	for _, option := range allowed {
+		if v.String() == option {
+			return nil
+		}
+	}
+	return violation(field, "unsupported", "%s must be one of: %s", field, strings.Join(allowed, ", "))
+}
+
+func checkEq(field string, v reflect.Value, param string) *errdefs.FieldViolation {
+	if v.String() != param {
+		return violation(field, "invalid_value", "%s must be %q", field, param)
+	}
+	return nil
+}
+
+func checkUsername(field string, v reflect.Value, _ string) *errdefs.FieldViolation {
+	if !IsUsername(v.String()) {
+		return violation(field, "invalid_characters",
+			"%s may contain only latin letters, digits, '.', '_' and '-', and must start and end with a letter or digit", field)
+	}
+	return nil
+}
+
+// IsEmail проверяет синтаксис адреса: dot-atom в локальной части (RFC 5322, с UTF-8 по RFC 6531)
+// и домен, который переводится в ASCII по IDNA (пример: пользователь@пример.рф)
+func IsEmail(s string) bool {
+	if len(s) > 254 {
+		return false
+	}
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 || at == len(s)-1 {
+		return false
+	}
+	return isLocalPart(s[:at]) && isDomain(s[at+1:])
+}
+
+// Спецсимволы atext из RFC 5322
+const atextSpecials = "!#$%&'*+-/=?^_`{|}~"
+
+func isLocalPart(local string) bool {
+	if len(local) > 64 {
+		return false
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			switch {
+			case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			case r < utf8.RuneSelf && strings.ContainsRune(atextSpecials, r):
+			case r >= utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)):
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isDomain(domain string) bool {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil || len(ascii) > 253 {
+		return false
+	}
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+	}
+	// Домен верхнего уровня не бывает числовым, это отсекает IP-адреса
+	tld := labels[len(labels)-1]
+	if len(tld) < 2 || strings.Trim(tld, "0123456789") == "" {
+		return false
+	}
+	return true
+}
+
+// IsUsername: латинские буквы, цифры, '.', '_' и '-'; начинается и заканчивается буквой
+// или цифрой, разделители не идут подряд. Username используется в путях файлового сервиса
+func IsUsername(s string) bool {
+	if s == "" {
+		return false
+	}
+	separator := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			separator = false
+		case c == '.' || c == '_' || c == '-':
+			if separator {
+				return false
+			}
+			separator = true
+		default:
+			return false
+		}
+	}
+	return !separator
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"testing"
+
+	"homecloud-auth-service/internal/errdefs"
+	"homecloud-auth-service/internal/models"
+)
+
+func TestIsEmail(t *testing.T) {
+	valid := []string{
+		"user@example.com",
+		"first.last+tag@mail.example.co.uk",
+		"o'brien@example.org",
+		"пользователь@пример.рф",
+		"user@xn--e1afmkfd.xn--p1ai",
+		"user@bücher.de",
+	}
+	for _, email := range valid {
+		if !IsEmail(email) {
+			t.Errorf("IsEmail(%q) = false, want true", email)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"no-at-sign",
+		"@example.com",
+		"user@",
+		"user@localhost",
+		"user@example.c",
+		"user@10.0.0.1",
+		"user..dots@example.com",
+		".user@example.com",
+		"user.@example.com",
+		"us er@example.com",
+		"user@exa mple.com",
+		"user@-example.com",
+		"user@example..com",
+		"a\"b@example.com",
+		"user@example.com\n",
+	}
+	for _, email := range invalid {
+		if IsEmail(email) {
+			t.Errorf("IsEmail(%q) = true, want false", email)
+		}
+	}
+}
+
+func TestIsUsername(t *testing.T) {
+	for _, username := range []string{"bob", "john.doe", "j_doe-42", "A1"} {
+		if !IsUsername(username) {
+			t.Errorf("IsUsername(%q) = false, want true", username)
+		}
+	}
+	for _, username := range []string{"", "_bob", "bob-", "a..b", "a._b", "../etc", "jöhn", "bob smith", "bob@home"} {
+		if IsUsername(username) {
+			t.Errorf("IsUsername(%q) = true, want false", username)
+		}
+	}
+}
+
+func fieldCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var invalid *errdefs.ValidationError
+	if !errdefs.As(err, &invalid) {
+		t.Fatalf("expected *errdefs.ValidationError, got %v", err)
+	}
+	codes := make(map[string]string, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		codes[field.Field] = field.Code
+	}
+	return codes
+}
+
+func TestStructReportsEveryField(t *testing.T) {
+	err := Struct(models.RegisterRequest{Email: "user@", Username: "-x"})
+	if !errdefs.Is(err, errdefs.ErrInvalidInput) {
+		t.Fatalf("validation error must match ErrInvalidInput, got %v", err)
+	}
+
+	codes := fieldCodes(t, err)
+	want := map[string]string{"email": "invalid_format", "username": "too_short", "password": "required"}
+	for field, code := range want {
+		if codes[field] != code {
+			t.Errorf("%s: code = %q, want %q", field, codes[field], code)
+		}
+	}
+
+	if err := Struct(&models.RegisterRequest{Email: "user@example.com", Username: "user", Password: "secret"}); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+}
+
+func TestStructOptionalPointers(t *testing.T) {
+	if err := Struct(models.UpdateProfileRequest{}); err != nil {
+		t.Fatalf("empty update rejected: %v", err)
+	}
+
+	username := "bad name"
+	codes := fieldCodes(t, Struct(models.UpdateProfileRequest{Username: &username}))
+	if codes["username"] != "invalid_characters" {
+		t.Fatalf("username code = %q", codes["username"])
+	}
+}
+
+func TestStructNestedFieldNames(t *testing.T) {
+	err := Struct(models.WebAuthnRegisterFinishRequest{Name: "key"})
+	codes := fieldCodes(t, err)
+	for _, field := range []string{"challenge_id", "credential"} {
+		if codes[field] != "required" {
+			t.Errorf("%s: code = %q, want required (all: %v)", field, codes[field], codes)
+		}
+	}
+
+	var req models.WebAuthnRegisterFinishRequest
+	req.ChallengeID[0] = 1
+	req.Credential.ID = "id"
+	req.Credential.Type = "password"
+	codes = fieldCodes(t, Struct(req))
+	if codes["credential.type"] != "invalid_value" {
+		t.Fatalf("nested codes = %v", codes)
+	}
+	if codes["credential.response.clientDataJSON"] != "required" {
+		t.Fatalf("nested codes = %v", codes)
+	}
+}
+
+func TestStructRules(t *testing.T) {
+	codes := fieldCodes(t, Struct(models.MFALoginRequest{MFAToken: "t", Method: "sms", Code: "1"}))
+	if codes["method"] != "unsupported" {
+		t.Fatalf("codes = %v", codes)
+	}
+
+	codes = fieldCodes(t, Struct(models.TOTPCodeRequest{Code: "12a456"}))
+	if codes["code"] != "not_numeric" {
+		t.Fatalf("codes = %v", codes)
+	}
+
+	codes = fieldCodes(t, Struct(models.CreateWebhookRequest{URL: "ftp://example.com/hook"}))
+	if codes["url"] != "invalid_url" {
+		t.Fatalf("codes = %v", codes)
+	}
+}
+
+func TestStructPanicsOnUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("unknown rule must panic")
+		}
+	}()
+	Struct(struct {
+		Name string `validate:"required,shiny"`
+	}{Name: "x"})
+}
+
+func TestJoin(t *testing.T) {
+	if err := Join(nil, nil); err != nil {
+		t.Fatalf("Join(nil, nil) = %v", err)
+	}
+
+	err := Join(
+		errdefs.InvalidField("email", "required", "email is required"),
+		nil,
+		errdefs.InvalidField("password", "too_short", "password must be at least 8 characters"),
+	)
+	codes := fieldCodes(t, err)
+	if len(codes) != 2 || codes["email"] != "required" || codes["password"] != "too_short" {
+		t.Fatalf("codes = %v", codes)
+	}
+
+	internal := errdefs.ErrDB
+	if err := Join(errdefs.InvalidField("email", "required", "email is required"), internal); err != internal {
+		t.Fatalf("non-validation error must be returned as is, got %v", err)
+	}
+}